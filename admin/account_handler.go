@@ -0,0 +1,267 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type accountView struct {
+	ID          uint64    `json:"id"`
+	AccountName string    `json:"account_name"`
+	Timezone    *string   `json:"timezone"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func newAccountView(a *dto.Account) *accountView {
+	v := &accountView{
+		ID:          a.ID,
+		AccountName: a.AccountName,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+	if a.Timezone.Valid {
+		v.Timezone = &a.Timezone.String
+	}
+	return v
+}
+
+type accountRequest struct {
+	AccountName string  `json:"account_name"`
+	Timezone    *string `json:"timezone"`
+}
+
+func (req *accountRequest) validate() error {
+	if req.AccountName == "" || len(req.AccountName) > 255 {
+		return badRequest("account_name must be 1-255 characters")
+	}
+	if req.Timezone != nil {
+		if len(*req.Timezone) > 20 {
+			return badRequest("timezone must be at most 20 characters")
+		}
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			return badRequest("invalid timezone: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (req *accountRequest) timezone() sql.NullString {
+	if req.Timezone == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *req.Timezone, Valid: true}
+}
+
+func (h *Handler) HandleListAccounts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limit, offset := 100, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			writeError(w, badRequest("invalid limit"))
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, badRequest("invalid offset"))
+			return
+		}
+		offset = n
+	}
+
+	rows, err := h.dbConn.QueryContext(
+		ctx,
+		"SELECT id, account_name, timezone, created_at, updated_at FROM account ORDER BY id ASC LIMIT ? OFFSET ?",
+		limit,
+		offset,
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	accounts := make([]*accountView, 0, limit)
+	for rows.Next() {
+		var a dto.Account
+		if err := rows.Scan(
+			&a.ID,
+			&a.AccountName,
+			&a.Timezone,
+			&a.CreatedAt,
+			&a.UpdatedAt,
+		); err != nil {
+			writeError(w, err)
+			return
+		}
+		accounts = append(accounts, newAccountView(&a))
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"accounts": accounts})
+}
+
+func (h *Handler) HandleGetAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	a, err := dto.AccountByID(r.Context(), h.dbConn, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, newAccountView(a))
+}
+
+func (h *Handler) HandleCreateAccount(w http.ResponseWriter, r *http.Request) {
+	var req accountRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var created *dto.Account
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		t := now.FromContext(ctx)
+		created = &dto.Account{
+			AccountName: req.AccountName,
+			Timezone:    req.timezone(),
+			CreatedAt:   t,
+			UpdatedAt:   t,
+		}
+		if err := created.Insert(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "account.create",
+			Entity:   "account",
+			EntityId: created.ID,
+			After:    newAccountView(created),
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusCreated, newAccountView(created))
+}
+
+func (h *Handler) HandleUpdateAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req accountRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var updated *dto.Account
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		updated, err = dto.AccountByID(ctx, txn, id)
+		if err != nil {
+			return err
+		}
+		before := newAccountView(updated)
+
+		updated.AccountName = req.AccountName
+		updated.Timezone = req.timezone()
+		updated.UpdatedAt = now.FromContext(ctx)
+		if err := updated.Update(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "account.update",
+			Entity:   "account",
+			EntityId: id,
+			Before:   before,
+			After:    newAccountView(updated),
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, newAccountView(updated))
+}
+
+func (h *Handler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		a, err := dto.AccountByID(ctx, txn, id)
+		if err != nil {
+			return err
+		}
+		if err := a.Delete(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "account.delete",
+			Entity:   "account",
+			EntityId: id,
+			Before:   newAccountView(a),
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
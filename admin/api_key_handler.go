@@ -0,0 +1,101 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+func (h *Handler) HandleListApiKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rows, err := h.dbConn.QueryContext(
+		ctx,
+		"SELECT id, account_id, api_key, expired_at, created_at FROM active_api_key WHERE account_id = ? ORDER BY id ASC",
+		accountId,
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	keys := make([]*dto.ActiveAPIKey, 0)
+	for rows.Next() {
+		var k dto.ActiveAPIKey
+		if err := rows.Scan(
+			&k.ID,
+			&k.AccountID,
+			&k.APIKey,
+			&k.ExpiredAt,
+			&k.CreatedAt,
+		); err != nil {
+			writeError(w, err)
+			return
+		}
+		keys = append(keys, &k)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"api_keys": keys})
+}
+
+// HandleRevokeApiKey expires the key immediately. Provider instances may keep
+// accepting it until their api key cache entry expires.
+func (h *Handler) HandleRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var revoked *dto.ActiveAPIKey
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		revoked, err = dto.ActiveAPIKeyByID(ctx, txn, id)
+		if err != nil {
+			return err
+		}
+		before := *revoked
+
+		t := now.FromContext(ctx)
+		if revoked.ExpiredAt.Before(t) {
+			return badRequest("api key is already expired")
+		}
+		revoked.ExpiredAt = t
+		if err := revoked.Update(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "api_key.revoke",
+			Entity:   "active_api_key",
+			EntityId: id,
+			Before:   &before,
+			After:    revoked,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, revoked)
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+)
+
+// Tokens maps an admin bearer token to the actor name recorded in the audit log.
+type Tokens map[string]string
+
+// ParseTokens parses "actor1:token1,actor2:token2".
+func ParseTokens(s string) (Tokens, error) {
+	tokens := make(Tokens)
+	for pair := range strings.SplitSeq(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		actor, token, ok := strings.Cut(pair, ":")
+		if !ok || actor == "" || token == "" {
+			return nil, fmt.Errorf("invalid admin token entry %q", pair)
+		}
+		tokens[token] = actor
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no admin tokens configured")
+	}
+	return tokens, nil
+}
+
+func (t Tokens) lookup(token string) (string, bool) {
+	for k, actor := range t {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(token)) == 1 {
+			return actor, true
+		}
+	}
+	return "", false
+}
+
+func authenticate(tokens Tokens, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			httplib.WriteError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		actor, ok := tokens.lookup(token)
+		if !ok {
+			httplib.WriteError(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+
+		ctx := audit.WithActor(r.Context(), actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokens(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		arg     string
+		want    Tokens
+		wantErr bool
+	}{
+		{
+			arg:  "alice:token-a, bob:token-b",
+			want: Tokens{"token-a": "alice", "token-b": "bob"},
+		},
+		{
+			arg:     "",
+			wantErr: true,
+		},
+		{
+			arg:     "alice",
+			wantErr: true,
+		},
+		{
+			arg:     "alice:",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, err := ParseTokens(tt.arg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/szks-repo/usage-based-billing-sample/budget"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type budgetRequest struct {
+	BudgetLimit string `json:"budget_limit"`
+	Action      string `json:"action"`
+}
+
+func (req *budgetRequest) validate() error {
+	limit, ok := new(big.Rat).SetString(req.BudgetLimit)
+	if !ok || limit.Sign() < 0 {
+		return badRequest("budget_limit must be a non-negative decimal")
+	}
+	if _, err := budget.ParseAction(req.Action); err != nil {
+		return badRequest(err.Error())
+	}
+	return nil
+}
+
+// HandleGetBudget returns the budget together with the current billing status.
+func (h *Handler) HandleGetBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, err := dto.AccountBudgetByAccountID(ctx, h.dbConn, accountId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	status, err := dto.AccountBillingStatusByAccountID(ctx, h.dbConn, accountId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{
+		"budget":         b,
+		"billing_status": status,
+	})
+}
+
+// HandlePutBudget creates or replaces the budget. The status is recomputed by
+// budget.Evaluator on its next run, raising the budget lifts a suspension from then on.
+func (h *Handler) HandlePutBudget(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req budgetRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var after *dto.AccountBudget
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := dto.AccountByID(ctx, txn, accountId); err != nil {
+			return err
+		}
+
+		before, err := dto.AccountBudgetByAccountID(ctx, txn, accountId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		limit, err := strconv.ParseFloat(req.BudgetLimit, 64)
+		if err != nil {
+			return badRequest("invalid budget_limit: " + err.Error())
+		}
+		t := now.FromContext(ctx)
+		after = &dto.AccountBudget{
+			AccountID:   accountId,
+			BudgetLimit: limit,
+			Action:      req.Action,
+			CreatedAt:   t,
+			UpdatedAt:   t,
+		}
+		if before != nil {
+			after.CreatedAt = before.CreatedAt
+		}
+		if err := after.Upsert(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "budget.put",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   before,
+			After:    after,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, after)
+}
+
+// HandleDeleteBudget removes the budget and reactivates the account immediately.
+func (h *Handler) HandleDeleteBudget(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		b, err := dto.AccountBudgetByAccountID(ctx, txn, accountId)
+		if err != nil {
+			return err
+		}
+		if err := b.Delete(ctx, txn); err != nil {
+			return err
+		}
+
+		if _, err := txn.ExecContext(
+			ctx,
+			"UPDATE account_billing_status SET `status` = ?, `evaluated_at` = ? WHERE account_id = ?",
+			string(budget.StatusActive),
+			now.FromContext(ctx),
+			accountId,
+		); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "budget.delete",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   b,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type creditGrantRequest struct {
+	// Credit is the number of free usages granted. Negative values revoke credit,
+	// grants themselves are never updated or deleted.
+	Credit int `json:"credit"`
+}
+
+func (h *Handler) HandleListCredits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	grants, err := dto.AccountFreeCreditBalanceByAccountID(ctx, h.dbConn, accountId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var balance int
+	for _, g := range grants {
+		balance += g.Credit
+	}
+	if grants == nil {
+		grants = make([]*dto.AccountFreeCreditBalance, 0)
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{
+		"balance": balance,
+		"grants":  grants,
+	})
+}
+
+func (h *Handler) HandleGrantCredit(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req creditGrantRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Credit == 0 {
+		writeError(w, badRequest("credit must not be zero"))
+		return
+	}
+
+	var grant *dto.AccountFreeCreditBalance
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := dto.AccountByID(ctx, txn, accountId); err != nil {
+			return err
+		}
+
+		var balance int
+		if err := txn.QueryRowContext(
+			ctx,
+			"SELECT COALESCE(SUM(credit), 0) FROM account_free_credit_balance WHERE account_id = ? FOR UPDATE",
+			accountId,
+		).Scan(&balance); err != nil {
+			return err
+		}
+		if balance+req.Credit < 0 {
+			return badRequest("credit balance must not be negative")
+		}
+
+		grant = &dto.AccountFreeCreditBalance{
+			AccountID: accountId,
+			Credit:    req.Credit,
+			CreatedAt: now.FromContext(ctx),
+		}
+		if err := grant.Insert(ctx, txn); err != nil {
+			return err
+		}
+
+		if _, err := txn.ExecContext(
+			ctx,
+			"INSERT INTO account_free_credit_balance_snapshot "+
+				"(`account_id`, `credit`) VALUES (?, ?)",
+			accountId,
+			balance+req.Credit,
+		); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "credit.grant",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   map[string]int{"balance": balance},
+			After:    map[string]any{"balance": balance + req.Credit, "grant": grant},
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusCreated, grant)
+}
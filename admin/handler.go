@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+)
+
+type Handler struct {
+	dbConn *sql.DB
+}
+
+func NewHandler(dbConn *sql.DB) *Handler {
+	return &Handler{
+		dbConn: dbConn,
+	}
+}
+
+var errBadRequest = errors.New("bad request")
+
+type badRequestError struct {
+	msg string
+}
+
+func (e *badRequestError) Error() string {
+	return e.msg
+}
+
+func (e *badRequestError) Unwrap() error {
+	return errBadRequest
+}
+
+func badRequest(msg string) error {
+	return &badRequestError{msg: msg}
+}
+
+var errConflict = errors.New("conflict")
+
+type conflictError struct {
+	msg string
+}
+
+func (e *conflictError) Error() string {
+	return e.msg
+}
+
+func (e *conflictError) Unwrap() error {
+	return errConflict
+}
+
+func conflict(msg string) error {
+	return &conflictError{msg: msg}
+}
+
+func pathId(r *http.Request, name string) (uint64, error) {
+	id, err := strconv.ParseUint(r.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, badRequest("invalid " + name)
+	}
+	return id, nil
+}
+
+func decodeBody(r *http.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return badRequest("invalid request body: " + err.Error())
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errBadRequest):
+		httplib.WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errConflict):
+		httplib.WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		httplib.WriteError(w, http.StatusNotFound, "not found")
+	default:
+		slog.Error("admin api", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func auditReason(r *http.Request) string {
+	return r.Header.Get("x-audit-reason")
+}
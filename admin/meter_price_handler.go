@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice/model"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+// maxMeterLength is the length of account_meter_price.meter.
+const maxMeterLength = 64
+
+type meterPriceRequest struct {
+	PricePerUsage string `json:"price_per_usage"`
+}
+
+// validate checks the meter is one provider_api.meters can name and the price is a non-negative decimal.
+func (req *meterPriceRequest) validate(meter string) error {
+	if meter == "" || len(meter) > maxMeterLength || strings.ContainsAny(meter, ",= \t") {
+		return badRequest("meter must be a meter name of provider_api.meters of at most 64 bytes")
+	}
+	var builder model.MeterPriceBuilder
+	builder.Set(meter, req.PricePerUsage)
+	if _, err := builder.Build(); err != nil {
+		return badRequest(err.Error())
+	}
+	return nil
+}
+
+// HandleListMeterPrices returns the meter prices of the account in meter order.
+func (h *Handler) HandleListMeterPrices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rows, err := h.dbConn.QueryContext(
+		ctx,
+		"SELECT account_id, meter, price_per_usage, created_at, updated_at FROM account_meter_price WHERE account_id = ? ORDER BY meter ASC",
+		accountId,
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	prices := make([]*dto.AccountMeterPrice, 0)
+	for rows.Next() {
+		var p dto.AccountMeterPrice
+		if err := rows.Scan(
+			&p.AccountID,
+			&p.Meter,
+			&p.PricePerUsage,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		); err != nil {
+			writeError(w, err)
+			return
+		}
+		prices = append(prices, &p)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"meter_prices": prices})
+}
+
+// HandlePutMeterPrice creates or replaces the price of a meter, which applies to the
+// invoices made from then on instead of the base price.
+func (h *Handler) HandlePutMeterPrice(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	meter := r.PathValue("meter")
+	var req meterPriceRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(meter); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var after *dto.AccountMeterPrice
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := dto.AccountByID(ctx, txn, accountId); err != nil {
+			return err
+		}
+
+		before, err := dto.AccountMeterPriceByAccountIDMeter(ctx, txn, accountId, meter)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		price, err := strconv.ParseFloat(req.PricePerUsage, 64)
+		if err != nil {
+			return badRequest("invalid price_per_usage: " + err.Error())
+		}
+		t := now.FromContext(ctx)
+		after = &dto.AccountMeterPrice{
+			AccountID:     accountId,
+			Meter:         meter,
+			PricePerUsage: price,
+			CreatedAt:     t,
+			UpdatedAt:     t,
+		}
+		if before != nil {
+			after.CreatedAt = before.CreatedAt
+		}
+		if err := after.Upsert(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "meter_price.put",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   before,
+			After:    after,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, after)
+}
+
+// HandleDeleteMeterPrice removes the price of a meter, which is billed at the base price from then on.
+func (h *Handler) HandleDeleteMeterPrice(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	meter := r.PathValue("meter")
+
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		p, err := dto.AccountMeterPriceByAccountIDMeter(ctx, txn, accountId, meter)
+		if err != nil {
+			return err
+		}
+		if err := p.Delete(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "meter_price.delete",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   p,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeterPriceRequest_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		meter   string
+		price   string
+		wantErr bool
+	}{
+		{name: "valid", meter: "tokens", price: "0.00150"},
+		{name: "free", meter: "tokens", price: "0"},
+		{name: "negative price", meter: "tokens", price: "-1", wantErr: true},
+		{name: "not a decimal", meter: "tokens", price: "abc", wantErr: true},
+		{name: "empty meter", meter: "", price: "1", wantErr: true},
+		{name: "meter not in provider_api.meters syntax", meter: "a=b", price: "1", wantErr: true},
+		{name: "meter too long", meter: strings.Repeat("m", 65), price: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&meterPriceRequest{PricePerUsage: tt.price}).validate(tt.meter)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errBadRequest)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
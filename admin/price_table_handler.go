@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice/model"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type priceRange struct {
+	MinUsage      int    `json:"min_usage"`
+	MaxUsage      int    `json:"max_usage"`
+	PricePerUsage string `json:"price_per_usage"`
+}
+
+type priceTableRequest struct {
+	Ranges []priceRange `json:"ranges"`
+}
+
+func (req *priceTableRequest) validate() error {
+	var builder model.RangePriceBuilder
+	for _, rp := range req.Ranges {
+		builder.Set(rp.MinUsage, rp.MaxUsage, rp.PricePerUsage)
+	}
+	if _, err := builder.Build(); err != nil {
+		return badRequest(err.Error())
+	}
+	return nil
+}
+
+func listPriceTable(ctx context.Context, conn dto.DB, accountId uint64) ([]*dto.AccountPriceTable, error) {
+	items, err := dto.AccountPriceTableByAccountID(ctx, conn, accountId)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(items, func(a, b *dto.AccountPriceTable) int {
+		return a.MinUsage - b.MinUsage
+	})
+	if items == nil {
+		items = make([]*dto.AccountPriceTable, 0)
+	}
+	return items, nil
+}
+
+func (h *Handler) HandleGetPriceTable(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	items, err := listPriceTable(r.Context(), h.dbConn, accountId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"ranges": items})
+}
+
+// HandlePutPriceTable replaces the whole price table of the account.
+func (h *Handler) HandlePutPriceTable(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req priceTableRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	slices.SortFunc(req.Ranges, func(a, b priceRange) int {
+		return a.MinUsage - b.MinUsage
+	})
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var after []*dto.AccountPriceTable
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := dto.AccountByID(ctx, txn, accountId); err != nil {
+			return err
+		}
+
+		before, err := listPriceTable(ctx, txn, accountId)
+		if err != nil {
+			return err
+		}
+		for _, item := range before {
+			if err := item.Delete(ctx, txn); err != nil {
+				return err
+			}
+		}
+
+		t := now.FromContext(ctx)
+		after = make([]*dto.AccountPriceTable, 0, len(req.Ranges))
+		for _, rp := range req.Ranges {
+			price, err := strconv.ParseFloat(rp.PricePerUsage, 64)
+			if err != nil {
+				return badRequest("invalid price_per_usage: " + err.Error())
+			}
+			item := &dto.AccountPriceTable{
+				AccountID:     accountId,
+				MinUsage:      rp.MinUsage,
+				MaxUsage:      rp.MaxUsage,
+				PricePerUsage: price,
+				CreatedAt:     t,
+				UpdatedAt:     t,
+			}
+			if err := item.Insert(ctx, txn); err != nil {
+				return err
+			}
+			after = append(after, item)
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "price_table.replace",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   before,
+			After:    after,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"ranges": after})
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/report"
+)
+
+func parseReportQuery(r *http.Request) (*report.Query, report.Format, error) {
+	kind, err := report.ParseKind(r.PathValue("kind"))
+	if err != nil {
+		return nil, "", badRequest(err.Error())
+	}
+	params := r.URL.Query()
+	format := report.FormatCSV
+	if v := params.Get("format"); v != "" {
+		if format, err = report.ParseFormat(v); err != nil {
+			return nil, "", badRequest(err.Error())
+		}
+	}
+
+	q := &report.Query{Kind: kind}
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		t, err := time.Parse(time.DateOnly, params.Get(name))
+		if err != nil {
+			return nil, "", badRequest("invalid " + name + ", must be YYYY-MM-DD")
+		}
+		*dst = t
+	}
+	for _, v := range params["account_id"] {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, "", badRequest("invalid account_id")
+		}
+		q.AccountIds = append(q.AccountIds, id)
+	}
+	if err := q.Validate(); err != nil {
+		return nil, "", badRequest(err.Error())
+	}
+	return q, format, nil
+}
+
+// HandleExportReport streams the usage or invoice report of a period as an attachment.
+// Errors after the first bytes were sent abort the response, so a truncated report is not
+// mistaken for a complete one.
+func (h *Handler) HandleExportReport(w http.ResponseWriter, r *http.Request) {
+	q, format, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ww := httplib.NewResponseWriterWrapper(w)
+	ww.Header().Set("Content-Type", format.ContentType())
+	ww.Header().Set("Content-Disposition", fmt.Sprintf(
+		`attachment; filename="%s_%s_%s.%s"`,
+		q.Kind, q.From.Format(time.DateOnly), q.To.Format(time.DateOnly), format,
+	))
+	if err := report.NewExporter(h.dbConn).Export(r.Context(), q, format, ww); err != nil {
+		if ww.BytesWritten() == 0 {
+			ww.Header().Del("Content-Disposition")
+			writeError(ww, err)
+			return
+		}
+		slog.Error("Failed to export report", "kind", q.Kind, "format", format, "bytesWritten", ww.BytesWritten(), "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
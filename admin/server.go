@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"net/http"
+)
+
+func NewAdminServer(
+	port string,
+	tokens Tokens,
+	handler *Handler,
+) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/v1/accounts", handler.HandleListAccounts)
+	mux.HandleFunc("POST /admin/v1/accounts", handler.HandleCreateAccount)
+	mux.HandleFunc("GET /admin/v1/accounts/{id}", handler.HandleGetAccount)
+	mux.HandleFunc("PUT /admin/v1/accounts/{id}", handler.HandleUpdateAccount)
+	mux.HandleFunc("DELETE /admin/v1/accounts/{id}", handler.HandleDeleteAccount)
+
+	mux.HandleFunc("GET /admin/v1/accounts/{id}/subscriptions", handler.HandleListSubscriptions)
+	mux.HandleFunc("POST /admin/v1/accounts/{id}/subscriptions", handler.HandleCreateSubscription)
+	mux.HandleFunc("GET /admin/v1/subscriptions/{id}", handler.HandleGetSubscription)
+	mux.HandleFunc("PUT /admin/v1/subscriptions/{id}", handler.HandleUpdateSubscription)
+	mux.HandleFunc("DELETE /admin/v1/subscriptions/{id}", handler.HandleDeleteSubscription)
+
+	mux.HandleFunc("GET /admin/v1/accounts/{id}/price-table", handler.HandleGetPriceTable)
+	mux.HandleFunc("PUT /admin/v1/accounts/{id}/price-table", handler.HandlePutPriceTable)
+
+	mux.HandleFunc("GET /admin/v1/accounts/{id}/meter-prices", handler.HandleListMeterPrices)
+	mux.HandleFunc("PUT /admin/v1/accounts/{id}/meter-prices/{meter}", handler.HandlePutMeterPrice)
+	mux.HandleFunc("DELETE /admin/v1/accounts/{id}/meter-prices/{meter}", handler.HandleDeleteMeterPrice)
+
+	mux.HandleFunc("GET /admin/v1/accounts/{id}/credits", handler.HandleListCredits)
+	mux.HandleFunc("POST /admin/v1/accounts/{id}/credits", handler.HandleGrantCredit)
+
+	mux.HandleFunc("GET /admin/v1/accounts/{id}/budget", handler.HandleGetBudget)
+	mux.HandleFunc("PUT /admin/v1/accounts/{id}/budget", handler.HandlePutBudget)
+	mux.HandleFunc("DELETE /admin/v1/accounts/{id}/budget", handler.HandleDeleteBudget)
+
+	mux.HandleFunc("GET /admin/v1/accounts/{id}/api-keys", handler.HandleListApiKeys)
+	mux.HandleFunc("POST /admin/v1/api-keys/{id}/revoke", handler.HandleRevokeApiKey)
+
+	mux.HandleFunc("GET /admin/v1/reports/{kind}", handler.HandleExportReport)
+
+	server := &http.Server{
+		Addr:    port,
+		Handler: authenticate(tokens, mux),
+	}
+	return server
+}
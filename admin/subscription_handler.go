@@ -0,0 +1,268 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+type subscriptionRequest struct {
+	From        time.Time `json:"from"`
+	EstimatedTo time.Time `json:"estimated_to"`
+}
+
+func (req *subscriptionRequest) validate() error {
+	if req.From.IsZero() || req.EstimatedTo.IsZero() {
+		return badRequest("from and estimated_to are required")
+	}
+	if !req.From.Before(req.EstimatedTo) {
+		return badRequest("from must be before estimated_to")
+	}
+	return nil
+}
+
+// alignedTo checks that the period starts and ends at midnight in loc, the timezone of the
+// account. Invoices bill whole days of that timezone, so a day split between two periods
+// would be billed on both.
+func (req *subscriptionRequest) alignedTo(loc *time.Location) error {
+	for _, t := range []time.Time{req.From, req.EstimatedTo} {
+		if !usage.StartOfDay(t, loc).Equal(t) {
+			return badRequest("from and estimated_to must be midnight in the account timezone " + loc.String())
+		}
+	}
+	return nil
+}
+
+// accountLocation returns the timezone of the account.
+func accountLocation(ctx context.Context, conn dto.DB, accountId uint64) (*time.Location, error) {
+	account, err := dto.AccountByID(ctx, conn, accountId)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := usage.Location(account.Timezone)
+	if err != nil {
+		return nil, badRequest(err.Error())
+	}
+	return loc, nil
+}
+
+func (h *Handler) HandleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rows, err := h.dbConn.QueryContext(
+		ctx,
+		"SELECT s.id, s.account_id, s.from, s.estimated_to, s.created_at FROM subscription s WHERE s.account_id = ? ORDER BY s.from ASC",
+		accountId,
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*dto.Subscription, 0)
+	for rows.Next() {
+		var s dto.Subscription
+		if err := rows.Scan(
+			&s.ID,
+			&s.AccountID,
+			&s.From,
+			&s.EstimatedTo,
+			&s.CreatedAt,
+		); err != nil {
+			writeError(w, err)
+			return
+		}
+		subscriptions = append(subscriptions, &s)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+}
+
+func (h *Handler) HandleGetSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s, err := dto.SubscriptionByID(r.Context(), h.dbConn, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, s)
+}
+
+func (h *Handler) HandleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	accountId, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req subscriptionRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var created *dto.Subscription
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		loc, err := accountLocation(ctx, txn, accountId)
+		if err != nil {
+			return err
+		}
+		if err := req.alignedTo(loc); err != nil {
+			return err
+		}
+
+		created = &dto.Subscription{
+			AccountID:   accountId,
+			From:        req.From,
+			EstimatedTo: req.EstimatedTo,
+			CreatedAt:   now.FromContext(ctx),
+		}
+		if err := created.Insert(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "subscription.create",
+			Entity:   "subscription",
+			EntityId: created.ID,
+			After:    created,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusCreated, created)
+}
+
+// HandleUpdateSubscription changes the period of a subscription, which is refused with 409
+// once the subscription is closed, as its invoice is already made.
+func (h *Handler) HandleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req subscriptionRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var updated *dto.Subscription
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		updated, err = dto.SubscriptionByID(ctx, txn, id)
+		if err != nil {
+			return err
+		}
+		if updated.ClosedAt.Valid {
+			return conflict("the subscription is closed and its invoice is made")
+		}
+		before := *updated
+
+		loc, err := accountLocation(ctx, txn, updated.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := req.alignedTo(loc); err != nil {
+			return err
+		}
+
+		updated.From = req.From
+		updated.EstimatedTo = req.EstimatedTo
+		if err := updated.Update(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "subscription.update",
+			Entity:   "subscription",
+			EntityId: id,
+			Before:   &before,
+			After:    updated,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) HandleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := db.RunInTxn(r.Context(), h.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		s, err := dto.SubscriptionByID(ctx, txn, id)
+		if err != nil {
+			return err
+		}
+		if err := s.Delete(ctx, txn); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, &audit.Event{
+			Action:   "subscription.delete",
+			Entity:   "subscription",
+			EntityId: id,
+			Before:   s,
+			Reason:   auditReason(r),
+		})
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
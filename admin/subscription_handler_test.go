@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionRequest_alignedTo(t *testing.T) {
+	t.Parallel()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		req     subscriptionRequest
+		loc     *time.Location
+		wantErr bool
+	}{
+		{
+			name: "midnight in tokyo",
+			req: subscriptionRequest{
+				From:        time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo),
+				EstimatedTo: time.Date(2025, 8, 1, 0, 0, 0, 0, tokyo),
+			},
+			loc: tokyo,
+		},
+		{
+			name: "same instant in utc",
+			req: subscriptionRequest{
+				From:        time.Date(2025, 6, 30, 15, 0, 0, 0, time.UTC),
+				EstimatedTo: time.Date(2025, 7, 31, 15, 0, 0, 0, time.UTC),
+			},
+			loc: tokyo,
+		},
+		{
+			name: "utc midnight splits a tokyo day",
+			req: subscriptionRequest{
+				From:        time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+				EstimatedTo: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC),
+			},
+			loc:     tokyo,
+			wantErr: true,
+		},
+		{
+			name: "end not at midnight",
+			req: subscriptionRequest{
+				From:        time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+				EstimatedTo: time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC),
+			},
+			loc:     time.UTC,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.req.alignedTo(tt.loc)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errBadRequest)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// stubConnector answers every query with rows and records the statements executed.
+type stubConnector struct {
+	rows  [][]driver.Value
+	execs []string
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) { return c, nil }
+
+func (c *stubConnector) Driver() driver.Driver { return nil }
+
+func (c *stubConnector) Prepare(string) (driver.Stmt, error) { return nil, errors.ErrUnsupported }
+
+func (c *stubConnector) Close() error { return nil }
+
+func (c *stubConnector) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *stubConnector) Commit() error { return nil }
+
+func (c *stubConnector) Rollback() error { return nil }
+
+func (c *stubConnector) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &stubRows{rows: c.rows}, nil
+}
+
+func (c *stubConnector) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.execs = append(c.execs, query)
+	return driver.RowsAffected(1), nil
+}
+
+type stubRows struct {
+	rows [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *stubRows) Close() error { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestHandler_HandleUpdateSubscription_closed(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	conn := &stubConnector{rows: [][]driver.Value{{int64(3), int64(1), from, to, to.Add(time.Hour), from}}}
+	dbConn := sql.OpenDB(conn)
+	t.Cleanup(func() { dbConn.Close() })
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/v1/subscriptions/3", strings.NewReader(`{"from":"2025-06-01T00:00:00Z","estimated_to":"2025-08-01T00:00:00Z"}`))
+	req.SetPathValue("id", "3")
+	rec := httptest.NewRecorder()
+	NewHandler(dbConn).HandleUpdateSubscription(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, conn.execs, "a closed subscription is neither updated nor audited")
+}
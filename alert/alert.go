@@ -0,0 +1,78 @@
+package alert
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	// KindQuota compares the usage of the current period with limit_value.
+	KindQuota Kind = "quota"
+	// KindBudget compares the tax excluded price of the current period with limit_value.
+	KindBudget Kind = "budget"
+)
+
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(s); k {
+	case KindQuota, KindBudget:
+		return k, nil
+	}
+	return "", fmt.Errorf("invalid alert kind %q", s)
+}
+
+// ParseThresholds parses comma separated percentages such as "50,80,100".
+func ParseThresholds(s string) ([]uint16, error) {
+	var thresholds []uint16
+	for v := range strings.SplitSeq(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 16)
+		if err != nil || n == 0 || n > 1000 {
+			return nil, fmt.Errorf("invalid threshold %q", v)
+		}
+		thresholds = append(thresholds, uint16(n))
+	}
+	slices.Sort(thresholds)
+	return slices.Compact(thresholds), nil
+}
+
+func FormatThresholds(thresholds []uint16) string {
+	s := make([]string, len(thresholds))
+	for i, t := range thresholds {
+		s[i] = strconv.FormatUint(uint64(t), 10)
+	}
+	return strings.Join(s, ",")
+}
+
+// Crossed returns the thresholds that value has reached relative to limit.
+func Crossed(thresholds []uint16, value, limit *big.Rat) []uint16 {
+	if limit.Sign() <= 0 {
+		return nil
+	}
+	percent := new(big.Rat).Quo(new(big.Rat).Mul(value, big.NewRat(100, 1)), limit)
+
+	var crossed []uint16
+	for _, t := range thresholds {
+		if percent.Cmp(big.NewRat(int64(t), 1)) >= 0 {
+			crossed = append(crossed, t)
+		}
+	}
+	return crossed
+}
+
+// Event is published once per alert, subscription and threshold.
+// Consumers can use (alert_id, subscription_id, threshold) to deduplicate.
+type Event struct {
+	AlertId        uint64    `json:"alert_id"`
+	AccountId      uint64    `json:"account_id"`
+	SubscriptionId uint64    `json:"subscription_id"`
+	Kind           Kind      `json:"kind"`
+	Threshold      uint16    `json:"threshold"`
+	Limit          string    `json:"limit"`
+	Value          string    `json:"value"`
+	FiredAt        time.Time `json:"fired_at"`
+}
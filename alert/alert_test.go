@@ -0,0 +1,60 @@
+package alert
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossed(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		thresholds []uint16
+		value      *big.Rat
+		limit      *big.Rat
+	}
+
+	tests := []struct {
+		args args
+		want []uint16
+	}{
+		{
+			args: args{thresholds: []uint16{50, 80, 100}, value: big.NewRat(49, 1), limit: big.NewRat(100, 1)},
+			want: nil,
+		},
+		{
+			args: args{thresholds: []uint16{50, 80, 100}, value: big.NewRat(80, 1), limit: big.NewRat(100, 1)},
+			want: []uint16{50, 80},
+		},
+		{
+			args: args{thresholds: []uint16{50, 80, 100}, value: big.NewRat(1001, 10), limit: big.NewRat(100, 1)},
+			want: []uint16{50, 80, 100},
+		},
+		{
+			args: args{thresholds: []uint16{50}, value: big.NewRat(10, 1), limit: big.NewRat(0, 1)},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, tt.want, Crossed(tt.args.thresholds, tt.args.value, tt.args.limit))
+		})
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseThresholds("100, 50,80,50")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{50, 80, 100}, got)
+
+	_, err = ParseThresholds("50,abc")
+	assert.Error(t, err)
+
+	_, err = ParseThresholds("0")
+	assert.Error(t, err)
+}
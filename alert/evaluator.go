@@ -0,0 +1,185 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type InvoicePreviewer interface {
+	Preview(ctx context.Context, accountId uint64) (*invoice.Preview, error)
+}
+
+type Evaluator struct {
+	dbConn    *sql.DB
+	previewer InvoicePreviewer
+	notifier  Notifier
+	lastTick  atomic.Int64
+}
+
+func NewEvaluator(
+	dbConn *sql.DB,
+	previewer InvoicePreviewer,
+	notifier Notifier,
+) *Evaluator {
+	return &Evaluator{
+		dbConn:    dbConn,
+		previewer: previewer,
+		notifier:  notifier,
+	}
+}
+
+// OnFlushed implements worker.FlushListener.
+func (e *Evaluator) OnFlushed(ctx context.Context, accountIds []uint64) {
+	e.Evaluate(ctx, accountIds)
+}
+
+// Run evaluates accounts whose every_minute_api_usage changed since the previous tick.
+func (e *Evaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := now.FromContext(ctx).Add(-interval)
+	for {
+		e.lastTick.Store(time.Now().UnixNano())
+		tickAt := now.FromContext(ctx)
+		accountIds, err := e.listUpdatedAccountIds(ctx, since)
+		if err != nil {
+			slog.Error("Failed to listUpdatedAccountIds", "error", err)
+		} else {
+			e.Evaluate(ctx, accountIds)
+			since = tickAt
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Evaluator) listUpdatedAccountIds(ctx context.Context, since time.Time) ([]uint64, error) {
+	rows, err := e.dbConn.QueryContext(
+		ctx,
+		"SELECT DISTINCT u.account_id FROM every_minute_api_usage u "+
+			"JOIN usage_alert a ON a.account_id = u.account_id "+
+			"WHERE u.updated_at >= ?",
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accountIds []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		accountIds = append(accountIds, id)
+	}
+	return accountIds, rows.Err()
+}
+
+// LastTick returns when Run last started an evaluation, for liveness checks.
+func (e *Evaluator) LastTick() time.Time {
+	return time.Unix(0, e.lastTick.Load())
+}
+
+func (e *Evaluator) Evaluate(ctx context.Context, accountIds []uint64) {
+	for _, accountId := range accountIds {
+		if err := e.evaluateAccount(ctx, accountId); err != nil {
+			slog.Error("Failed to evaluate usage alerts", "accountId", accountId, "error", err)
+		}
+	}
+}
+
+func (e *Evaluator) evaluateAccount(ctx context.Context, accountId uint64) error {
+	alerts, err := dto.UsageAlertByAccountID(ctx, e.dbConn, accountId)
+	if err != nil || len(alerts) == 0 {
+		return err
+	}
+
+	preview, err := e.previewer.Preview(ctx, accountId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	for _, a := range alerts {
+		kind, err := ParseKind(a.Kind)
+		if err != nil {
+			return err
+		}
+		thresholds, err := ParseThresholds(a.Thresholds)
+		if err != nil {
+			return err
+		}
+
+		value := new(big.Rat).SetUint64(preview.ToDate.TotalUsage())
+		if kind == KindBudget {
+			value = preview.ToDate.TotalPrice()
+		}
+		limit := new(big.Rat).SetFloat64(a.LimitValue)
+
+		for _, threshold := range Crossed(thresholds, value, limit) {
+			if err := e.fire(ctx, &Event{
+				AlertId:        a.ID,
+				AccountId:      accountId,
+				SubscriptionId: preview.SubscriptionId,
+				Kind:           kind,
+				Threshold:      threshold,
+				Limit:          limit.FloatString(5),
+				Value:          value.FloatString(5),
+				FiredAt:        now.FromContext(ctx),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fire records the alert state and publishes the event in one transaction.
+// The state row makes each threshold fire once per period even across restarts,
+// a failed publish rolls the state back so that the next evaluation retries.
+func (e *Evaluator) fire(ctx context.Context, event *Event) error {
+	return db.RunInTxn(ctx, e.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		result, err := txn.ExecContext(
+			ctx,
+			"INSERT IGNORE INTO usage_alert_state (`alert_id`, `subscription_id`, `threshold`, `fired_at`) VALUES (?, ?, ?, ?)",
+			event.AlertId,
+			event.SubscriptionId,
+			event.Threshold,
+			event.FiredAt,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+
+		slog.Info("Usage alert fired", "alertId", event.AlertId, "accountId", event.AccountId, "threshold", event.Threshold)
+		return e.notifier.Notify(ctx, event)
+	})
+}
@@ -0,0 +1,69 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/streadway/amqp"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+)
+
+const QueueName = "usage_alert_queue"
+
+type Notifier interface {
+	Notify(ctx context.Context, event *Event) error
+}
+
+type rabbitMQNotifier struct {
+	mqConn *rabbitmq.Conn
+}
+
+func NewRabbitMQNotifier(mqConn *rabbitmq.Conn) (Notifier, error) {
+	if err := mqConn.Declare(rabbitmq.DurableQueue(QueueName)); err != nil {
+		return nil, err
+	}
+
+	return &rabbitMQNotifier{
+		mqConn: mqConn,
+	}, nil
+}
+
+func (n *rabbitMQNotifier) Notify(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return n.mqConn.Publish(
+		ctx,
+		"",        // exchange
+		QueueName, // routing key
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    event.FiredAt,
+		},
+	)
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier logs alert events instead of publishing them, for devServer.
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+func (logNotifier) Notify(ctx context.Context, event *Event) error {
+	slog.InfoContext(ctx, "Usage alert fired",
+		"alertId", event.AlertId,
+		"accountId", event.AccountId,
+		"kind", event.Kind,
+		"threshold", event.Threshold,
+		"limit", event.Limit,
+		"value", event.Value,
+	)
+	return nil
+}
@@ -0,0 +1,62 @@
+package budget
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Status is the per-account billing status enforced by the provider API.
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusThrottled Status = "throttled"
+	StatusSuspended Status = "suspended"
+)
+
+func ParseStatus(s string) (Status, error) {
+	switch Status(s) {
+	case StatusActive, StatusThrottled, StatusSuspended:
+		return Status(s), nil
+	}
+	return "", fmt.Errorf("unknown billing status: %q", s)
+}
+
+// Action is what happens to an account whose invoice exceeds its budget, see Decide.
+type Action string
+
+const (
+	ActionSuspend  Action = "suspend"
+	ActionThrottle Action = "throttle"
+)
+
+func ParseAction(s string) (Action, error) {
+	switch Action(s) {
+	case ActionSuspend, ActionThrottle:
+		return Action(s), nil
+	}
+	return "", fmt.Errorf("unknown budget action: %q", s)
+}
+
+// MinProjectionElapsed is the fraction of the period that must have elapsed before the
+// projected total price restricts an account. Earlier, a burst in the first hours of a
+// period extrapolates far beyond what the period will cost.
+const MinProjectionElapsed = 0.25
+
+// Decide returns the status of an account from the total price of its usage so far,
+// its projected total price and the fraction of the period elapsed.
+// A budget is exceeded only when the price is strictly greater than limit.
+//
+// The projection restricts the account like the price so far, but only once
+// MinProjectionElapsed of the period elapsed.
+func Decide(toDate, projected, limit *big.Rat, elapsed float64, action Action) Status {
+	exceeded := toDate.Cmp(limit) > 0 || (elapsed >= MinProjectionElapsed && projected.Cmp(limit) > 0)
+	switch {
+	case !exceeded:
+		return StatusActive
+	case action == ActionThrottle:
+		return StatusThrottled
+	default:
+		return StatusSuspended
+	}
+}
@@ -0,0 +1,101 @@
+package budget
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+)
+
+func TestDecide(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		toDate    *big.Rat
+		projected *big.Rat
+		limit     *big.Rat
+		elapsed   float64
+		action    Action
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want Status
+	}{
+		{
+			name: "within budget",
+			args: args{toDate: big.NewRat(50, 1), projected: big.NewRat(99, 1), limit: big.NewRat(100, 1), elapsed: 0.5, action: ActionSuspend},
+			want: StatusActive,
+		},
+		{
+			name: "projection equal to the limit",
+			args: args{toDate: big.NewRat(50, 1), projected: big.NewRat(100, 1), limit: big.NewRat(100, 1), elapsed: 0.5, action: ActionThrottle},
+			want: StatusActive,
+		},
+		{
+			name: "to date over the limit suspends",
+			args: args{toDate: big.NewRat(1001, 10), projected: big.NewRat(300, 1), limit: big.NewRat(100, 1), elapsed: 0.1, action: ActionSuspend},
+			want: StatusSuspended,
+		},
+		{
+			name: "to date over the limit throttles",
+			args: args{toDate: big.NewRat(1001, 10), projected: big.NewRat(300, 1), limit: big.NewRat(100, 1), elapsed: 0.1, action: ActionThrottle},
+			want: StatusThrottled,
+		},
+		{
+			name: "projection suspends once enough of the period elapsed",
+			args: args{toDate: big.NewRat(60, 1), projected: big.NewRat(120, 1), limit: big.NewRat(100, 1), elapsed: 0.5, action: ActionSuspend},
+			want: StatusSuspended,
+		},
+		{
+			name: "projection does not suspend early in the period",
+			args: args{toDate: big.NewRat(20, 1), projected: big.NewRat(2400, 1), limit: big.NewRat(100, 1), elapsed: 0.2, action: ActionSuspend},
+			want: StatusActive,
+		},
+		{
+			name: "projection throttles once enough of the period elapsed",
+			args: args{toDate: big.NewRat(60, 1), projected: big.NewRat(120, 1), limit: big.NewRat(100, 1), elapsed: 0.5, action: ActionThrottle},
+			want: StatusThrottled,
+		},
+		{
+			name: "burst early in the period is not extrapolated",
+			args: args{toDate: big.NewRat(20, 1), projected: big.NewRat(2400, 1), limit: big.NewRat(100, 1), elapsed: 0.01, action: ActionThrottle},
+			want: StatusActive,
+		},
+		{
+			name: "zero budget",
+			args: args{toDate: big.NewRat(1, 1), projected: big.NewRat(1, 1), limit: big.NewRat(0, 1), elapsed: 0, action: ActionThrottle},
+			want: StatusThrottled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Decide(tt.args.toDate, tt.args.projected, tt.args.limit, tt.args.elapsed, tt.args.action))
+		})
+	}
+}
+
+func TestElapsedFraction(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		asOf time.Time
+		want float64
+	}{
+		{asOf: from, want: 0},
+		{asOf: from.AddDate(0, 0, 15), want: 0.5},
+		{asOf: to.Add(time.Hour), want: 1},
+	}
+
+	for _, tt := range tests {
+		assert.InDelta(t, tt.want, elapsedFraction(&invoice.Preview{PeriodFrom: from, PeriodTo: to, AsOf: tt.asOf}), 1e-9)
+	}
+}
@@ -0,0 +1,195 @@
+package budget
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type InvoicePreviewer interface {
+	Preview(ctx context.Context, accountId uint64) (*invoice.Preview, error)
+}
+
+// Evaluator keeps account_billing_status up to date by comparing the invoice to date
+// and the projected invoice of each budgeted account with its budget.
+type Evaluator struct {
+	dbConn    *sql.DB
+	previewer InvoicePreviewer
+	lastTick  atomic.Int64
+}
+
+func NewEvaluator(
+	dbConn *sql.DB,
+	previewer InvoicePreviewer,
+) *Evaluator {
+	return &Evaluator{
+		dbConn:    dbConn,
+		previewer: previewer,
+	}
+}
+
+// OnFlushed implements worker.FlushListener.
+func (e *Evaluator) OnFlushed(ctx context.Context, accountIds []uint64) {
+	e.Evaluate(ctx, accountIds)
+}
+
+// Run evaluates every budgeted account on each tick. All of them are evaluated
+// so that a raised budget or a new subscription period lifts the restriction
+// even while the account has no traffic.
+func (e *Evaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.lastTick.Store(time.Now().UnixNano())
+		accountIds, err := e.listBudgetedAccountIds(ctx)
+		if err != nil {
+			slog.Error("Failed to listBudgetedAccountIds", "error", err)
+		} else {
+			e.Evaluate(ctx, accountIds)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Evaluator) listBudgetedAccountIds(ctx context.Context) ([]uint64, error) {
+	rows, err := e.dbConn.QueryContext(ctx, "SELECT account_id FROM account_budget ORDER BY account_id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accountIds []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		accountIds = append(accountIds, id)
+	}
+	return accountIds, rows.Err()
+}
+
+// LastTick returns when Run last started an evaluation, for liveness checks.
+func (e *Evaluator) LastTick() time.Time {
+	return time.Unix(0, e.lastTick.Load())
+}
+
+func (e *Evaluator) Evaluate(ctx context.Context, accountIds []uint64) {
+	for _, accountId := range accountIds {
+		if err := e.EvaluateAccount(ctx, accountId); err != nil {
+			slog.Error("Failed to evaluate budget", "accountId", accountId, "error", err)
+		}
+	}
+}
+
+// EvaluateAccount recomputes the billing status of the account.
+// An account without a current subscription is active.
+func (e *Evaluator) EvaluateAccount(ctx context.Context, accountId uint64) error {
+	b, err := dto.AccountBudgetByAccountID(ctx, e.dbConn, accountId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the admin api resets the status when it removes a budget
+			return nil
+		}
+		return err
+	}
+	action, err := ParseAction(b.Action)
+	if err != nil {
+		return err
+	}
+
+	status := StatusActive
+	projected := new(big.Rat)
+	preview, err := e.previewer.Preview(ctx, accountId)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return err
+	default:
+		projected = preview.Projected.TotalPrice()
+		status = Decide(preview.ToDate.TotalPrice(), projected, new(big.Rat).SetFloat64(b.BudgetLimit), elapsedFraction(preview), action)
+	}
+
+	return e.save(ctx, accountId, status, projected)
+}
+
+// elapsedFraction returns the fraction of the preview's period elapsed at its time.
+func elapsedFraction(preview *invoice.Preview) float64 {
+	period := preview.PeriodTo.Sub(preview.PeriodFrom)
+	if period <= 0 {
+		return 1
+	}
+	return min(max(float64(preview.AsOf.Sub(preview.PeriodFrom))/float64(period), 0), 1)
+}
+
+// save upserts the status row. Status transitions are recorded in the audit log.
+func (e *Evaluator) save(ctx context.Context, accountId uint64, status Status, projected *big.Rat) error {
+	return db.RunInTxn(ctx, e.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		projectedTotal, _ := projected.Float64()
+		after := &dto.AccountBillingStatus{
+			AccountID:      accountId,
+			Status:         string(status),
+			ProjectedTotal: projectedTotal,
+			EvaluatedAt:    now.FromContext(ctx),
+		}
+
+		before, err := dto.AccountBillingStatusByAccountID(ctx, txn, accountId)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if status == StatusActive {
+				// active is the default, there is nothing to record
+				return nil
+			}
+			before = &dto.AccountBillingStatus{AccountID: accountId, Status: string(StatusActive)}
+		case err != nil:
+			return err
+		}
+
+		if _, err := txn.ExecContext(
+			ctx,
+			"INSERT INTO account_billing_status (`account_id`, `status`, `projected_total`, `evaluated_at`) VALUES (?, ?, ?, ?) "+
+				"ON DUPLICATE KEY UPDATE `status` = VALUES(`status`), `projected_total` = VALUES(`projected_total`), `evaluated_at` = VALUES(`evaluated_at`)",
+			after.AccountID,
+			after.Status,
+			after.ProjectedTotal,
+			after.EvaluatedAt,
+		); err != nil {
+			return err
+		}
+
+		if before.Status == after.Status {
+			return nil
+		}
+
+		slog.Info("Billing status changed", "accountId", accountId, "from", before.Status, "to", after.Status, "projected", projected.FloatString(5))
+		return audit.Record(ctx, &audit.Event{
+			Action:   "billing_status.change",
+			Entity:   "account",
+			EntityId: accountId,
+			Before:   before,
+			After:    after,
+			Reason:   "invoice to date and projected invoice evaluated against budget",
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/admin"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+)
+
+// adminApiCmd represents the adminApi command
+var adminApiCmd = &cobra.Command{
+	Use:   "adminApi",
+	Short: "run admin API server for accounts, subscriptions, price tables and credits",
+	Long: `run admin API server for accounts, subscriptions, price tables and credits.
+
+Admin tokens are read from admin_api.tokens (env UBB_ADMIN_API_TOKENS)
+as "actor1:token1,actor2:token2".
+Clients send "Authorization: Bearer <token>" and may set "X-Audit-Reason".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		slog.Info("Starting admin API server")
+
+		tokens, err := admin.ParseTokens(cfg.AdminApi.Tokens)
+		if err != nil {
+			slog.Error("Failed to parse admin_api.tokens", "error", err)
+			return
+		}
+
+		ctx := cmd.Context()
+		nctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		checker := health.NewChecker(cfg.Health.CheckTimeout)
+		checker.AddReadiness("db", health.DBPing(db.Get()))
+		stopOps := serveOps(checker)
+
+		srv := admin.NewAdminServer(cfg.AdminApi.Addr, tokens, admin.NewHandler(db.Get()))
+		go func() {
+			if err := srv.ListenAndServe(); err != nil {
+				slog.Error("admin API server", "error", err)
+			}
+		}()
+
+		<-nctx.Done()
+		slog.Info("Received shutdown signal, stopping admin API server")
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+		defer cancel()
+		drain(ctx, checker)
+		srv.Shutdown(ctx)
+		stopOps(ctx)
+		slog.Info("Admin API server stopped gracefully")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adminApiCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+)
+
+// auditLogCmd represents the auditLog command
+var auditLogCmd = &cobra.Command{
+	Use:   "auditLog",
+	Short: "query audit events as NDJSON, newest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		flags := cmd.Flags()
+
+		var filter audit.Filter
+		filter.Actor, _ = flags.GetString("actor")
+		filter.Action, _ = flags.GetString("action")
+		filter.Entity, _ = flags.GetString("entity")
+		filter.EntityId, _ = flags.GetUint64("entity-id")
+		filter.Limit, _ = flags.GetInt("limit")
+		for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			v, _ := flags.GetString(name)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return err
+			}
+			*dst = t
+		}
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		events, err := audit.List(ctx, db.Get(), &filter)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range events {
+			if err := enc.Encode(map[string]any{
+				"id":         e.ID,
+				"actor":      e.Actor,
+				"action":     e.Action,
+				"entity":     e.Entity,
+				"entity_id":  e.EntityID,
+				"before":     json.RawMessage(nullIfEmpty(e.BeforeValue)),
+				"after":      json.RawMessage(nullIfEmpty(e.AfterValue)),
+				"reason":     e.Reason,
+				"created_at": e.CreatedAt,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func nullIfEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return []byte("null")
+	}
+	return b
+}
+
+func init() {
+	flags := auditLogCmd.Flags()
+	flags.String("actor", "", "filter by actor")
+	flags.String("action", "", "filter by action e.g. price_table.replace")
+	flags.String("entity", "", "filter by entity e.g. account")
+	flags.Uint64("entity-id", 0, "filter by entity id")
+	flags.String("from", "", "created_at lower bound (RFC3339, inclusive)")
+	flags.String("to", "", "created_at upper bound (RFC3339, exclusive)")
+	flags.Int("limit", 1000, "max number of events, the most recent ones")
+	rootCmd.AddCommand(auditLogCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/logstore"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+// backfillUsageCmd represents the backfillUsage command
+var backfillUsageCmd = &cobra.Command{
+	Use:   "backfillUsage",
+	Short: "recompute the usage tables of closed days from the Parquet access log archives and print the differences as NDJSON",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		accountIds, _ := flags.GetUintSlice("account-id")
+		dir, _ := flags.GetString("dir")
+		overwrite, _ := flags.GetBool("overwrite")
+
+		var dates [2]time.Time
+		for i, name := range []string{"from", "to"} {
+			v, _ := flags.GetString(name)
+			t, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", name, err)
+			}
+			dates[i] = t
+		}
+		if dates[1].Before(dates[0]) {
+			return fmt.Errorf("--to %s is before --from %s", dates[1].Format(time.DateOnly), dates[0].Format(time.DateOnly))
+		}
+
+		ctx := audit.WithActor(cmd.Context(), audit.SystemActor+":backfillUsage")
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		source := logstore.NewDirSource(dir)
+		if dir == "" {
+			s3Client, err := newS3Client(ctx)
+			if err != nil {
+				return err
+			}
+			source = logstore.NewS3Source(s3Client, cfg.S3.Bucket)
+		}
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		ids := make([]uint64, len(accountIds))
+		for i, id := range accountIds {
+			ids[i] = uint64(id)
+		}
+		backfiller := usage.NewBackfiller(db.Get(), source)
+
+		enc := json.NewEncoder(os.Stdout)
+		for date := dates[0]; !date.After(dates[1]); date = date.AddDate(0, 0, 1) {
+			diffs, err := backfiller.Backfill(ctx, date, ids, overwrite)
+			if err != nil {
+				return fmt.Errorf("%s: %w", date.Format(time.DateOnly), err)
+			}
+			for _, diff := range diffs {
+				if err := enc.Encode(diff); err != nil {
+					return err
+				}
+			}
+		}
+
+		pushMetrics(ctx, "backfillUsage")
+		return nil
+	},
+}
+
+func init() {
+	flags := backfillUsageCmd.Flags()
+	flags.String("from", "", "first day to recompute in the timezone of each account e.g. 2025-07-01")
+	flags.String("to", "", "last day to recompute, inclusive")
+	flags.UintSlice("account-id", nil, "accounts to recompute, all accounts when omitted")
+	flags.String("dir", "", "read the archives from a local copy of the bucket instead of S3")
+	flags.Bool("overwrite", false, "replace the rows that differ instead of only printing the differences")
+	backfillUsageCmd.MarkFlagRequired("from")
+	backfillUsageCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(backfillUsageCmd)
+}
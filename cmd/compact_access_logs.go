@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/logstore"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+// compactAccessLogsCmd represents the compactAccessLogs command
+var compactAccessLogsCmd = &cobra.Command{
+	Use:   "compactAccessLogs",
+	Short: "merge the small Parquet objects of completed hours into one object per hour",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hours, _ := cmd.Flags().GetInt("hours")
+		grace, _ := cmd.Flags().GetDuration("grace")
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		s3Client, err := newS3Client(ctx)
+		if err != nil {
+			return err
+		}
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+		compactor := logstore.NewCompactor(s3Client, cfg.S3.Bucket, logstore.NewDBCatalog(db.Get()))
+
+		// Only hours that ended grace ago are compacted, so that flushes still
+		// writing to the hour do not race with the compaction.
+		last := logstore.PartitionOf(now.FromContext(ctx).Add(-grace).Add(-time.Hour))
+		var errs []error
+		for i := range hours {
+			p := logstore.PartitionOf(last.Hour().Add(-time.Duration(i) * time.Hour))
+			if _, err := compactor.Compact(ctx, p); err != nil {
+				slog.Error("Failed to compact access logs", "partition", p, "error", err)
+				errs = append(errs, err)
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		pushMetrics(ctx, "compactAccessLogs")
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	compactAccessLogsCmd.Flags().Int("hours", 24, "number of completed hours to compact, newest first")
+	compactAccessLogsCmd.Flags().Duration("grace", 10*time.Minute, "time after the end of an hour before it is compacted")
+	rootCmd.AddCommand(compactAccessLogsCmd)
+}
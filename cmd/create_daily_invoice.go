@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
 )
 
@@ -15,16 +16,17 @@ var createDailyInvoiceCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		slog.Info("Starting daily invoice maker")
 
-		ctx := cmd.Context()
+		ctx := audit.WithActor(cmd.Context(), audit.SystemActor+":createDailyInvoice")
 
-		db.MustInit()
+		db.MustInit(&cfg.DB)
 		defer db.Close()
 
 		maker := invoice.NewInvoiceMaker(
 			db.Get(),
 			invoice.NewUsageReconciler(),
 		)
-		maker.CreateInvoiceDaily(ctx)
+		maker.CreateInvoiceDaily(ctx, cfg.Invoice.CloseGrace)
+		pushMetrics(ctx, "createDailyInvoice")
 	},
 }
 
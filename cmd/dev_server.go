@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/alert"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+)
+
+// devServerCmd represents the devServer command
+var devServerCmd = &cobra.Command{
+	Use:   "devServer",
+	Short: "Run the provider API and the receiver worker in one process",
+	Long: `Run the provider API and the receiver worker in one process.
+
+Usage alerts are logged instead of published. With queue.transport memory
+access logs are passed in process, so only MySQL and S3 are needed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		slog.Info("Starting dev server", "transport", cfg.Queue.Transport)
+
+		ctx := cmd.Context()
+		nctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		stopTracing := initTracing(ctx, "devServer")
+		defer stopTracing(ctx)
+
+		var usageQueue usageQueue
+		var memory *queue.Memory
+		if cfg.Queue.Transport == "memory" {
+			memory = queue.NewMemory(cfg.Queue.MemorySize)
+			usageQueue = memory
+		} else {
+			transport, err := openUsageTransport()
+			if err != nil {
+				slog.Error("Failed to open access log transport", "error", err)
+				return
+			}
+			defer transport.close()
+			usageQueue = transport.queue
+		}
+
+		checker := health.NewChecker(cfg.Health.CheckTimeout)
+		checker.AddReadiness("db", health.DBPing(db.Get()))
+		worker, err := newReceiverWorker(ctx, usageQueue, alert.NewLogNotifier(), checker)
+		if err != nil {
+			slog.Error("Failed to create receiver worker", "error", err)
+			return
+		}
+		stopOps := serveOps(checker)
+		defer stopOps(ctx)
+
+		stopApi, err := startProviderApi(usageQueue, checker)
+		if err != nil {
+			slog.Error("Failed to start provider API server", "error", err)
+			return
+		}
+
+		workerCtx, stopWorker := context.WithCancel(context.WithoutCancel(ctx))
+		workerDone := make(chan struct{})
+		go func() {
+			defer close(workerDone)
+			worker.Run(workerCtx, cfg.Worker.ShutdownTimeout)
+		}()
+
+		<-nctx.Done()
+		slog.Info("Received shutdown signal, stopping dev server")
+
+		stopCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+		defer cancel()
+		drain(stopCtx, checker)
+		// the worker consumes until the provider API has published its last access logs
+		stopApi(stopCtx)
+		for memory != nil && memory.Len() > 0 && stopCtx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		stopWorker()
+		<-workerDone
+		slog.Info("Dev server stopped gracefully")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devServerCmd)
+}
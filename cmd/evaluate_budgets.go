@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/budget"
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+)
+
+// evaluateBudgetsCmd represents the evaluateBudgets command
+var evaluateBudgetsCmd = &cobra.Command{
+	Use:   "evaluateBudgets",
+	Short: "periodically suspend or throttle accounts whose invoice exceeds their budget",
+	Run: func(cmd *cobra.Command, args []string) {
+		slog.Info("Starting budget evaluator")
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		ctx := audit.WithActor(cmd.Context(), audit.SystemActor+":evaluateBudgets")
+		nctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		evaluator := budget.NewEvaluator(
+			db.Get(),
+			invoice.NewInvoiceMaker(db.Get(), invoice.NewUsageReconciler()),
+		)
+
+		checker := health.NewChecker(cfg.Health.CheckTimeout)
+		checker.AddReadiness("db", health.DBPing(db.Get()))
+		checker.AddLiveness("evaluator", health.Fresh(evaluator.LastTick, 3*interval))
+		stopOps := serveOps(checker)
+		defer stopOps(ctx)
+
+		evaluator.Run(nctx, interval)
+
+		slog.Info("Budget evaluator stopped")
+	},
+}
+
+func init() {
+	evaluateBudgetsCmd.Flags().Duration("interval", time.Minute, "evaluation interval")
+	rootCmd.AddCommand(evaluateBudgetsCmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/alert"
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+)
+
+// evaluateUsageAlertsCmd represents the evaluateUsageAlerts command
+var evaluateUsageAlertsCmd = &cobra.Command{
+	Use:   "evaluateUsageAlerts",
+	Short: "periodically evaluate usage alerts over every_minute_api_usage",
+	Run: func(cmd *cobra.Command, args []string) {
+		slog.Info("Starting usage alert evaluator")
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		ctx := cmd.Context()
+		nctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		mqConn, err := rabbitmq.NewConn(
+			cfg.RabbitMQ.URL,
+			rabbitmq.WithPublisherPoolSize(cfg.RabbitMQ.PublisherPoolSize),
+		)
+		if err != nil {
+			slog.Error("Failed to connect to RabbitMQ", "error", err)
+			return
+		}
+		defer mqConn.Close()
+
+		notifier, err := alert.NewRabbitMQNotifier(mqConn)
+		if err != nil {
+			slog.Error("Failed to declare alert queue", "error", err)
+			return
+		}
+
+		evaluator := alert.NewEvaluator(
+			db.Get(),
+			invoice.NewInvoiceMaker(db.Get(), invoice.NewUsageReconciler()),
+			notifier,
+		)
+
+		checker := health.NewChecker(cfg.Health.CheckTimeout)
+		checker.AddReadiness("db", health.DBPing(db.Get()))
+		checker.AddReadiness("amqp", health.AMQPChannel(mqConn))
+		checker.AddLiveness("evaluator", health.Fresh(evaluator.LastTick, 3*interval))
+		stopOps := serveOps(checker)
+		defer stopOps(ctx)
+
+		evaluator.Run(nctx, interval)
+
+		slog.Info("Usage alert evaluator stopped")
+	},
+}
+
+func init() {
+	evaluateUsageAlertsCmd.Flags().Duration("interval", time.Minute, "evaluation interval")
+	rootCmd.AddCommand(evaluateUsageAlertsCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/report"
+)
+
+// exportReportCmd represents the exportReport command
+var exportReportCmd = &cobra.Command{
+	Use:   "exportReport",
+	Short: "export the daily usage or the invoices of a period as CSV, NDJSON or Parquet",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		flags := cmd.Flags()
+
+		kindName, _ := flags.GetString("kind")
+		kind, err := report.ParseKind(kindName)
+		if err != nil {
+			return err
+		}
+		formatName, _ := flags.GetString("format")
+		format, err := report.ParseFormat(formatName)
+		if err != nil {
+			return err
+		}
+		accountIds, _ := flags.GetUintSlice("account-id")
+		output, _ := flags.GetString("output")
+
+		q := &report.Query{Kind: kind}
+		for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+			v, _ := flags.GetString(name)
+			t, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", name, err)
+			}
+			*dst = t
+		}
+		for _, id := range accountIds {
+			q.AccountIds = append(q.AccountIds, uint64(id))
+		}
+		if err := q.Validate(); err != nil {
+			return err
+		}
+
+		out := os.Stdout
+		if output != "" {
+			if out, err = os.Create(output); err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+		w := bufio.NewWriter(out)
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		if err := report.NewExporter(db.Get()).Export(ctx, q, format, w); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if output != "" {
+			return out.Close()
+		}
+		return nil
+	},
+}
+
+func init() {
+	flags := exportReportCmd.Flags()
+	flags.String("kind", "usage", "report to export: usage (per account and day) or invoices (created in the period)")
+	flags.String("from", "", "first day of the period in the timezone of each account e.g. 2025-07-01")
+	flags.String("to", "", "last day of the period, inclusive")
+	flags.UintSlice("account-id", nil, "accounts to export, all accounts when omitted")
+	flags.String("format", "csv", "csv, ndjson or parquet")
+	flags.StringP("output", "o", "", "file to write, stdout when omitted")
+	exportReportCmd.MarkFlagRequired("from")
+	exportReportCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(exportReportCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/logstore"
+)
+
+// migrateAccessLogsCmd represents the migrateAccessLogs command
+var migrateAccessLogsCmd = &cobra.Command{
+	Use:   "migrateAccessLogs",
+	Short: "move the access logs flushed before the hourly layout, logs/YYYY/MM/DD/, into the hour partitions of their logs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		var dates [2]time.Time
+		for i, name := range []string{"from", "to"} {
+			v, _ := flags.GetString(name)
+			t, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", name, err)
+			}
+			dates[i] = t
+		}
+		if dates[1].Before(dates[0]) {
+			return fmt.Errorf("--to %s is before --from %s", dates[1].Format(time.DateOnly), dates[0].Format(time.DateOnly))
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		s3Client, err := newS3Client(ctx)
+		if err != nil {
+			return err
+		}
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+		migrator := logstore.NewMigrator(s3Client, cfg.S3.Bucket, logstore.NewDBCatalog(db.Get()))
+
+		for date := dates[0]; !date.After(dates[1]); date = date.AddDate(0, 0, 1) {
+			if _, err := migrator.Migrate(ctx, date); err != nil {
+				return fmt.Errorf("%s: %w", logstore.LegacyPrefix(date), err)
+			}
+		}
+
+		pushMetrics(ctx, "migrateAccessLogs")
+		return nil
+	},
+}
+
+func init() {
+	flags := migrateAccessLogsCmd.Flags()
+	flags.String("from", "", "first flush date of the legacy objects to migrate e.g. 2025-07-01")
+	flags.String("to", "", "last flush date to migrate, inclusive")
+	migrateAccessLogsCmd.MarkFlagRequired("from")
+	migrateAccessLogsCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(migrateAccessLogsCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/metrics"
+)
+
+// serveOps starts the server for /metrics and the health probes of checker on
+// metrics.addr and returns a function that stops it.
+func serveOps(checker *health.Checker) func(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	checker.Register(mux)
+
+	srv := &http.Server{
+		Addr:    cfg.Metrics.Addr,
+		Handler: mux,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("ops server", "error", err)
+		}
+	}()
+	return func(ctx context.Context) {
+		srv.Shutdown(ctx)
+	}
+}
+
+// drain fails readiness and waits health.drain_delay so that load balancers
+// stop routing new requests before the server shuts down.
+func drain(ctx context.Context, checker *health.Checker) {
+	checker.Drain()
+	slog.Info("Draining", "delay", cfg.Health.DrainDelay)
+	select {
+	case <-ctx.Done():
+	case <-time.After(cfg.Health.DrainDelay):
+	}
+}
+
+// pushMetrics sends the metrics of a batch command to the Pushgateway if configured.
+func pushMetrics(ctx context.Context, job string) {
+	if cfg.Metrics.PushgatewayURL == "" {
+		return
+	}
+	if err := metrics.Push(ctx, cfg.Metrics.PushgatewayURL, job); err != nil {
+		slog.Error("Failed to push metrics", "error", err)
+	}
+}
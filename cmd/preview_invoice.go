@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+)
+
+// previewInvoiceCmd represents the previewInvoice command
+var previewInvoiceCmd = &cobra.Command{
+	Use:   "previewInvoice",
+	Short: "preview the upcoming invoice of an account without persisting it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		accountId, err := cmd.Flags().GetUint64("account-id")
+		if err != nil {
+			return err
+		}
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		maker := invoice.NewInvoiceMaker(
+			db.Get(),
+			invoice.NewUsageReconciler(),
+		)
+		preview, err := maker.Preview(ctx, accountId)
+		if err != nil {
+			slog.Error("Failed to preview invoice", "accountId", accountId, "error", err)
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(preview)
+	},
+}
+
+func init() {
+	previewInvoiceCmd.Flags().Uint64("account-id", 0, "target account id")
+	previewInvoiceCmd.MarkFlagRequired("account-id")
+	rootCmd.AddCommand(previewInvoiceCmd)
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,9 +14,15 @@ import (
 	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/spf13/cobra"
 
+	"github.com/szks-repo/usage-based-billing-sample/budget"
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
-	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/spool"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
 	"github.com/szks-repo/usage-based-billing-sample/provider"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
 )
 
 // providerApiCmd represents the providerApi command
@@ -33,56 +42,120 @@ to quickly create a Cobra application.`,
 		nctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
-		db.MustInit()
+		db.MustInit(&cfg.DB)
 		defer db.Close()
 
-		mqConn, err := rabbitmq.NewConn("amqp://localhost:5672")
-		defer mqConn.Close()
+		stopTracing := initTracing(ctx, "providerApi")
+
+		transport, err := openUsageTransport()
 		if err != nil {
-			slog.Error("Failed to connect to RabbitMQ", "error", err)
+			slog.Error("Failed to open access log transport", "error", err)
 			return
 		}
-		queue, err := mqConn.Channel.QueueDeclare(
-			"api1_queue",
-			true,
-			false,
-			false,
-			false,
-			nil,
-		)
-		if err != nil {
-			slog.Error("Failed to declare queue", "error", err)
-			panic(err)
-		}
+		defer transport.close()
 
-		// todo: install github.com/mazrean/kessoku
-		cacheExpries := time.Minute * 30
+		// the broker is not a readiness check, access logs are spooled while it is down
+		checker := health.NewChecker(cfg.Health.CheckTimeout)
+		checker.AddReadiness("db", health.DBPing(db.Get()))
+		stopOps := serveOps(checker)
 
-		srv := provider.NewApiServer(":8080", provider.NewMiddleware(
-			provider.NewApiKeyChecker(
-				db.Get(),
-				expirable.NewLRU[string, int64](2000, nil, cacheExpries),
-				cacheExpries,
-			),
-			mqConn,
-			queue,
-		))
-		go func() {
-			if err := srv.ListenAndServe(); err != nil {
-				slog.Error("provider API server", "error", err)
-			}
-		}()
+		stopApi, err := startProviderApi(transport.queue, checker)
+		if err != nil {
+			slog.Error("Failed to start provider API server", "error", err)
+			return
+		}
 
 		<-nctx.Done()
 		slog.Info("Received shutdown signal, stopping worker")
 
 		ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 		defer cancel()
-		srv.Shutdown(ctx)
+		drain(ctx, checker)
+		stopApi(ctx)
+		stopOps(ctx)
+		stopTracing(ctx)
 		slog.Info("Worker stopped gracefully")
 	},
 }
 
+// startProviderApi serves the provider API and publishes its access logs to
+// publisher. The returned function shuts the server down and flushes the logs.
+func startProviderApi(publisher queue.UsagePublisher, checker *health.Checker) (func(ctx context.Context), error) {
+	meters, err := provider.ParseMeters(cfg.ProviderApi.Meters)
+	if err != nil {
+		return nil, err
+	}
+
+	accessLogSpool, err := spool.Open(
+		cfg.ProviderApi.SpoolDir,
+		int64(cfg.ProviderApi.SpoolSegmentMB)<<20,
+		int64(cfg.ProviderApi.SpoolMaxMB)<<20,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("open access log spool: %w", err)
+	}
+
+	backpressure, err := provider.ParseBackpressurePolicy(cfg.ProviderApi.PublishBackpressure)
+	if err != nil {
+		accessLogSpool.Close()
+		return nil, err
+	}
+
+	forwarderCtx, stopForwarder := context.WithCancel(context.Background())
+	go provider.NewSpoolForwarder(accessLogSpool, publisher).Run(forwarderCtx, cfg.ProviderApi.SpoolReplayInterval)
+
+	accessLogPublisher := provider.NewAccessLogPublisher(publisher, types.AccessLogQueue, accessLogSpool, provider.AccessLogPublisherOptions{
+		QueueSize:     cfg.ProviderApi.PublishQueueSize,
+		BatchSize:     cfg.ProviderApi.PublishBatchSize,
+		FlushInterval: cfg.ProviderApi.PublishFlushInterval,
+		Backpressure:  backpressure,
+	})
+	accessLogPublisher.Start()
+
+	// todo: install github.com/mazrean/kessoku
+	cacheExpries := cfg.ProviderApi.ApiKeyCacheTTL
+
+	srv := provider.NewApiServer(
+		cfg.ProviderApi.Addr,
+		provider.NewMiddleware(
+			provider.NewApiKeyChecker(
+				db.Get(),
+				expirable.NewLRU[string, provider.ApiKey](2000, nil, cacheExpries),
+				cacheExpries,
+			),
+			provider.NewBillingStatusChecker(
+				db.Get(),
+				expirable.NewLRU[int64, budget.Status](2000, nil, cfg.ProviderApi.BillingStatusCacheTTL),
+			),
+			cfg.ProviderApi.ThrottleLimit,
+			cfg.ProviderApi.ThrottleWindow,
+			cfg.ProviderApi.Region,
+			accessLogPublisher,
+		),
+		meters,
+		usage.NewReader(db.Get()),
+		invoice.NewInvoiceMaker(db.Get(), invoice.NewUsageReconciler()),
+		newInvoiceRenderer(),
+		db.Get(),
+		checker,
+	)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("provider API server", "error", err)
+		}
+	}()
+
+	return func(ctx context.Context) {
+		srv.Shutdown(ctx)
+		// after Shutdown no request enqueues anymore
+		if err := accessLogPublisher.Stop(ctx); err != nil {
+			slog.Error("Failed to publish access logs before shutdown, the rest was spooled", "error", err)
+		}
+		stopForwarder()
+		accessLogSpool.Close()
+	}, nil
+}
+
 func init() {
 	rootCmd.AddCommand(providerApiCmd)
 }
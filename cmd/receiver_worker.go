@@ -5,15 +5,15 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
-	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/spf13/cobra"
+	"github.com/szks-repo/usage-based-billing-sample/alert"
+	"github.com/szks-repo/usage-based-billing-sample/budget"
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
-	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
 	"github.com/szks-repo/usage-based-billing-sample/worker"
 )
 
@@ -30,59 +30,93 @@ to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		slog.Info("Starting receiver worker")
 
-		var (
-			awsRegion = "ap-northeast-1"
-			s3Url     = "http://localhost:9000"
-			s3Bucket  = "api-access-log"
-			queueUrl  = "amqp://localhost:5672"
-		)
-
 		ctx := cmd.Context()
 		nctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
-		db.MustInit()
+		db.MustInit(&cfg.DB)
 		defer db.Close()
 
-		mqConn, err := rabbitmq.NewConn(queueUrl)
+		stopTracing := initTracing(ctx, "receiverWorker")
+		defer stopTracing(ctx)
+
+		transport, err := openUsageTransport()
 		if err != nil {
-			slog.Error("Failed to connect to RabbitMQ", "error", err)
+			slog.Error("Failed to open access log transport", "error", err)
 			return
 		}
-		defer mqConn.Close()
+		defer transport.close()
 
-		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(awsRegion))
+		// usage alerts are published to RabbitMQ whichever transport access logs use
+		mqConn := transport.mqConn
+		if mqConn == nil {
+			if mqConn, err = openRabbitMQ(); err != nil {
+				slog.Error("Failed to connect to RabbitMQ", "error", err)
+				return
+			}
+			defer mqConn.Close()
+		}
+		alertNotifier, err := alert.NewRabbitMQNotifier(mqConn)
 		if err != nil {
-			panic(err)
+			slog.Error("Failed to declare alert queue", "error", err)
+			return
 		}
-		s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-			o.BaseEndpoint = &s3Url
-			if strings.Contains(s3Url, "localhost") {
-				o.UsePathStyle = true
-			}
-		})
 
-		worker := worker.NewWorker(
-			mqConn,
-			worker.NewAccessLogRecorder(
-				s3Client,
-				s3Bucket,
-				1024<<10*5,
-				time.Second*30,
-				db.Get(),
-			),
-		)
-		go worker.Run(ctx)
-
-		<-nctx.Done()
-		slog.Info("Received shutdown signal, stopping worker")
+		checker := health.NewChecker(cfg.Health.CheckTimeout)
+		checker.AddReadiness("db", health.DBPing(db.Get()))
+		checker.AddReadiness("queue", transport.queue.Ready)
+		if transport.mqConn == nil {
+			checker.AddReadiness("amqp", health.AMQPChannel(mqConn))
+		}
+		worker, err := newReceiverWorker(ctx, transport.queue, alertNotifier, checker)
+		if err != nil {
+			slog.Error("Failed to create receiver worker", "error", err)
+			return
+		}
+		stopOps := serveOps(checker)
+		defer stopOps(ctx)
 
-		ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
-		defer cancel()
+		// Run returns after the signal once the received logs are flushed and acked
+		worker.Run(nctx, cfg.Worker.ShutdownTimeout)
 		slog.Info("Worker stopped gracefully")
 	},
 }
 
+// newReceiverWorker creates the worker consuming access logs from consumer and
+// adds its S3 readiness and liveness checks to checker.
+func newReceiverWorker(ctx context.Context, consumer queue.UsageConsumer, alertNotifier alert.Notifier, checker *health.Checker) (*worker.Worker, error) {
+	s3Client, err := newS3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w := worker.NewWorker(
+		consumer,
+		worker.NewAccessLogRecorder(
+			s3Client,
+			cfg.S3.Bucket,
+			cfg.Worker.FlushLogs,
+			cfg.Worker.FlushInterval,
+			db.Get(),
+			worker.LateUsagePolicy(cfg.Worker.LateUsagePolicy),
+			cfg.Worker.DedupRetention,
+			alert.NewEvaluator(
+				db.Get(),
+				invoice.NewInvoiceMaker(db.Get(), invoice.NewUsageReconciler()),
+				alertNotifier,
+			),
+			budget.NewEvaluator(
+				db.Get(),
+				invoice.NewInvoiceMaker(db.Get(), invoice.NewUsageReconciler()),
+			),
+		),
+	)
+
+	checker.AddReadiness("s3", health.S3Bucket(s3Client, cfg.S3.Bucket))
+	checker.AddLiveness("worker", w.Liveness)
+	return w, nil
+}
+
 func init() {
 	rootCmd.AddCommand(receiverWorkerCmd)
 }
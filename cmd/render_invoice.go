@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice/render"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+)
+
+// renderInvoiceCmd represents the renderInvoice command
+var renderInvoiceCmd = &cobra.Command{
+	Use:   "renderInvoice",
+	Short: "render a persisted invoice as a PDF or HTML document",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		flags := cmd.Flags()
+
+		invoiceId, _ := flags.GetUint64("invoice-id")
+		formatName, _ := flags.GetString("format")
+		format, err := render.ParseFormat(formatName)
+		if err != nil {
+			return err
+		}
+		output, _ := flags.GetString("output")
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		doc, err := render.Load(ctx, db.Get(), invoiceId)
+		if err != nil {
+			return err
+		}
+
+		out := os.Stdout
+		if output != "" {
+			if out, err = os.Create(output); err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+		w := bufio.NewWriter(out)
+		if err := newInvoiceRenderer().Render(w, doc, format); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if output != "" {
+			return out.Close()
+		}
+		return nil
+	},
+}
+
+func newInvoiceRenderer() *render.Renderer {
+	return render.NewRenderer(render.Issuer{
+		Name:               cfg.Invoice.IssuerName,
+		Address:            cfg.Invoice.IssuerAddress,
+		Email:              cfg.Invoice.IssuerEmail,
+		RegistrationNumber: cfg.Invoice.IssuerRegistrationNumber,
+	}, cfg.Invoice.Currency)
+}
+
+func init() {
+	flags := renderInvoiceCmd.Flags()
+	flags.Uint64("invoice-id", 0, "invoice to render")
+	flags.String("format", "pdf", "pdf or html")
+	flags.StringP("output", "o", "", "file to write, stdout when omitted")
+	renderInvoiceCmd.MarkFlagRequired("invoice-id")
+	rootCmd.AddCommand(renderInvoiceCmd)
+}
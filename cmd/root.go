@@ -4,8 +4,13 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/config"
 )
 
+// cfg is loaded before any subcommand runs.
+var cfg *config.Config
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "usage-based-billing-sample",
@@ -16,6 +21,15 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		c, err := config.Load(cmd.Flags(), os.LookupEnv)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		cfg = c
+		return nil
+	},
 }
 
 func Execute() {
@@ -26,5 +40,6 @@ func Execute() {
 }
 
 func init() {
+	config.BindFlags(rootCmd.PersistentFlags())
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
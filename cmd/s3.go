@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// newS3Client creates the client of the access log bucket from cfg.S3.
+func newS3Client(ctx context.Context) (*s3.Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.S3.Region))
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3.Endpoint != "" {
+			o.BaseEndpoint = &cfg.S3.Endpoint
+		}
+		o.UsePathStyle = cfg.S3.UsePathStyle
+	}), nil
+}
@@ -21,7 +21,7 @@ to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		slog.Info("Starting seed db")
 
-		db.MustInit()
+		db.MustInit(&cfg.DB)
 		defer db.Close()
 
 		seed.Exec(cmd.Context(), db.Get())
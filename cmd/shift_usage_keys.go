@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+// shiftUsageKeysCmd represents the shiftUsageKeys command
+var shiftUsageKeysCmd = &cobra.Command{
+	Use:   "shiftUsageKeys",
+	Short: "re-key the minutes and hours stored by workers of a local zone to UTC and print the rows that could not be shifted as NDJSON",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		zone, _ := flags.GetString("zone")
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return fmt.Errorf("invalid --zone: %w", err)
+		}
+		v, _ := flags.GetString("before")
+		before, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return fmt.Errorf("invalid --before: %w", err)
+		}
+
+		ctx := audit.WithActor(cmd.Context(), audit.SystemActor+":shiftUsageKeys")
+
+		db.MustInit(&cfg.DB)
+		defer db.Close()
+
+		mixed, err := usage.ShiftLocalKeys(ctx, db.Get(), loc, before)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, k := range slices.SortedFunc(maps.Keys(mixed), func(a, b usage.BucketKey) int {
+			return cmp.Or(
+				strings.Compare(string(a.Granularity), string(b.Granularity)),
+				cmp.Compare(a.AccountId, b.AccountId),
+				strings.Compare(a.Bucket, b.Bucket),
+				strings.Compare(a.Meter, b.Meter),
+			)
+		}) {
+			if err := enc.Encode(map[string]any{
+				"granularity": k.Granularity,
+				"account_id":  k.AccountId,
+				"meter":       k.Meter,
+				"bucket":      k.Bucket,
+				"usage":       mixed[k].Usage,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	flags := shiftUsageKeysCmd.Flags()
+	flags.String("zone", "", "zone the workers keying minutes in local time ran in e.g. Asia/Tokyo")
+	flags.String("before", "", "start of the first worker keying minutes in UTC e.g. 2025-07-01T00:00:00Z")
+	shiftUsageKeysCmd.MarkFlagRequired("zone")
+	shiftUsageKeysCmd.MarkFlagRequired("before")
+	rootCmd.AddCommand(shiftUsageKeysCmd)
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
+)
+
+// initTracing sets up OpenTelemetry and returns a function that flushes pending spans.
+func initTracing(ctx context.Context, serviceName string) func(ctx context.Context) {
+	shutdown, err := tracing.Init(ctx, &cfg.Tracing, serviceName)
+	if err != nil {
+		slog.Error("Failed to init tracing, spans are not exported", "error", err)
+		return func(ctx context.Context) {}
+	}
+	return func(ctx context.Context) {
+		if err := shutdown(ctx); err != nil {
+			slog.Error("Failed to shutdown tracing", "error", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// consumerGroup names the RabbitMQ consumer and the Kafka consumer group of receiverWorker.
+const consumerGroup = "receiverWorker"
+
+type usageQueue interface {
+	queue.UsagePublisher
+	queue.UsageConsumer
+}
+
+// usageTransport is the configured queue.transport of access logs.
+type usageTransport struct {
+	queue usageQueue
+	// mqConn is set for the rabbitmq transport, other users of RabbitMQ share it
+	mqConn *rabbitmq.Conn
+	close  func()
+}
+
+// openUsageTransport connects to the broker of queue.transport. The memory
+// transport only connects the provider API and the worker of devServer.
+func openUsageTransport() (*usageTransport, error) {
+	switch cfg.Queue.Transport {
+	case "rabbitmq":
+		mqConn, err := openRabbitMQ()
+		if err != nil {
+			return nil, err
+		}
+		q, err := queue.NewRabbitMQ(mqConn, types.AccessLogQueue, consumerGroup)
+		if err != nil {
+			mqConn.Close()
+			return nil, err
+		}
+		return &usageTransport{queue: q, mqConn: mqConn, close: mqConn.Close}, nil
+	case "kafka":
+		k := queue.NewKafka(strings.Split(cfg.Kafka.Brokers, ","), types.AccessLogQueue, consumerGroup)
+		return &usageTransport{queue: k, close: func() {
+			if err := k.Close(); err != nil {
+				slog.Error("Failed to close Kafka transport", "error", err)
+			}
+		}}, nil
+	default:
+		return nil, fmt.Errorf("queue.transport %s is only supported by devServer", cfg.Queue.Transport)
+	}
+}
+
+func openRabbitMQ() (*rabbitmq.Conn, error) {
+	return rabbitmq.NewConn(
+		cfg.RabbitMQ.URL,
+		rabbitmq.WithPublisherPoolSize(cfg.RabbitMQ.PublisherPoolSize),
+	)
+}
@@ -29,7 +29,7 @@ to quickly create a Cobra application.`,
 
 		ctx := cmd.Context()
 
-		db.MustInit()
+		db.MustInit(&cfg.DB)
 		defer db.Close()
 
 		dbConn := db.Get()
@@ -55,7 +55,7 @@ to quickly create a Cobra application.`,
 		}
 
 		var (
-			apiUrl     = "http://localhost:8080/api/v1/one"
+			apiUrl     = cfg.UserClient.ApiURL
 			httpClient = &http.Client{
 				Transport: &http.Transport{
 					MaxIdleConnsPerHost: 30,
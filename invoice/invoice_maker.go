@@ -4,16 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/szks-repo/gopipeline"
 
 	"github.com/szks-repo/usage-based-billing-sample/invoice/model"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/tax"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
 )
 
 type InvoiceMaker struct {
@@ -31,10 +35,18 @@ func NewInvoiceMaker(
 	}
 }
 
-func (i *InvoiceMaker) CreateInvoiceDaily(ctx context.Context) {
+// dueSubscription is a subscription to invoice with the timezone of its account.
+type dueSubscription struct {
+	*dto.Subscription
+	loc *time.Location
+}
+
+// CreateInvoiceDaily invoices and closes the subscription periods that ended at least grace ago.
+// Access logs of a closed period arriving later are handled by the worker's late usage policy.
+func (i *InvoiceMaker) CreateInvoiceDaily(ctx context.Context, grace time.Duration) {
 	baseDate := now.FromContext(ctx).AddDate(0, 0, -1)
 
-	subscriptions, err := i.listSubscriptions(ctx, baseDate)
+	subscriptions, err := i.listSubscriptions(ctx, grace)
 	if err != nil {
 		slog.Error("Failed to listAccountIds", "error", err)
 	}
@@ -47,11 +59,18 @@ func (i *InvoiceMaker) CreateInvoiceDaily(ctx context.Context) {
 	gopipeline.New3(
 		ctx,
 		gopipeline.From(subscriptions),
-		gopipeline.ForEach(func(subscription *dto.Subscription) {
-			i.reconciler.Do(ctx, baseDate, subscription)
+		gopipeline.ForEach(func(subscription *dueSubscription) {
+			i.reconciler.Do(ctx, baseDate, subscription.Subscription)
 		}),
-		gopipeline.Map(func(subscription *dto.Subscription) (*model.Invoice, error) {
-			return i.createInvoice(ctx, subscription)
+		gopipeline.Map(func(subscription *dueSubscription) (*model.Invoice, error) {
+			var invoice *model.Invoice
+			err := db.RunInTxn(ctx, i.dbConn, func(ctx context.Context) error {
+				var err error
+				invoice, err = i.createInvoice(ctx, subscription.Subscription, subscription.loc)
+				return err
+			})
+			observeInvoice(invoice, err)
+			return invoice, err
 		}),
 		gopipeline.ForEach(func(invoice *model.Invoice) {
 			i.publishNotifyQueue(ctx, invoice)
@@ -85,19 +104,57 @@ func (i *InvoiceMaker) getPriceTable(ctx context.Context, accountId uint64) (*mo
 		builder.Set(minUsage, maxUsage, pricePerUsage)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	rangePrices, err := builder.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	return model.NewPriceTable(rangePrices), nil
+	meterPrices, err := i.getMeterPrices(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.NewPriceTable(rangePrices, meterPrices), nil
+}
+
+// getMeterPrices returns the prices of the meters the account is billed for apart from the base price.
+func (i *InvoiceMaker) getMeterPrices(ctx context.Context, accountId uint64) (model.MeterPrices, error) {
+	rows, err := i.dbConn.QueryContext(ctx, "SELECT `meter`, `price_per_usage` FROM account_meter_price WHERE account_id = ?", accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var builder model.MeterPriceBuilder
+	for rows.Next() {
+		var meter string
+		var pricePerUsage string
+		if err := rows.Scan(&meter, &pricePerUsage); err != nil {
+			return nil, err
+		}
+		builder.Set(meter, pricePerUsage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return builder.Build()
 }
 
-func (i *InvoiceMaker) listSubscriptionDailyApiUsages(ctx context.Context, subscription *dto.Subscription) ([]*model.DailyApiUsage, error) {
-	rows, err := i.dbConn.QueryContext(ctx, "SELECT `date`, `usage` FROM daily_api_usage WHERE account_id = ? AND date >= ? AND date <= ?",
+// listSubscriptionDailyApiUsages returns the daily_api_usage of the days in loc that overlap the period.
+func (i *InvoiceMaker) listSubscriptionDailyApiUsages(ctx context.Context, conn dto.DB, subscription *dto.Subscription, loc *time.Location) ([]*model.DailyApiUsage, error) {
+	days := usage.Days(subscription.From, subscription.EstimatedTo, loc)
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT `date`, `meter`, `usage` FROM daily_api_usage WHERE account_id = ? AND date >= ? AND date <= ? ORDER BY `date` ASC, `meter` ASC",
 		subscription.AccountID,
-		subscription.From.Format("20060102"),
-		subscription.EstimatedTo.Format("20060102"),
+		usage.GranularityDay.Key(days[0], loc),
+		usage.GranularityDay.Key(days[len(days)-1], loc),
 	)
 	if err != nil {
 		return nil, err
@@ -106,28 +163,34 @@ func (i *InvoiceMaker) listSubscriptionDailyApiUsages(ctx context.Context, subsc
 
 	var result []*model.DailyApiUsage
 	for rows.Next() {
-		var date time.Time
-		var usage uint64
+		var key string
+		var meter string
+		var dailyUsage uint64
 		if err := rows.Scan(
-			&date,
-			&usage,
+			&key,
+			&meter,
+			&dailyUsage,
 		); err != nil {
 			return nil, err
 		}
-		result = append(result, model.NewDailyApiUsage(date, usage))
+		date, err := usage.GranularityDay.Parse(key, loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, model.NewDailyApiUsage(date, meter, dailyUsage))
 	}
 
-	return result, nil
+	return result, rows.Err()
 }
 
 func (i *InvoiceMaker) getFreeCreditBalanceByAccountId(ctx context.Context, accountId uint64) (uint64, error) {
 	row := i.dbConn.QueryRowContext(
 		ctx,
-		"SELECT balance FROM account_free_credit_balance_snapshot WHERE account_id = ? ORDER BY created_at DESC LIMIT 1",
+		"SELECT credit FROM account_free_credit_balance_snapshot WHERE account_id = ? ORDER BY created_at DESC LIMIT 1",
 		accountId,
 	)
 	var balance uint64
-	if err := row.Scan(&balance); err != nil && errors.Is(err, sql.ErrNoRows) {
+	if err := row.Scan(&balance); err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return 0, err
 	}
 
@@ -137,8 +200,25 @@ func (i *InvoiceMaker) getFreeCreditBalanceByAccountId(ctx context.Context, acco
 func (i *InvoiceMaker) createInvoice(
 	ctx context.Context,
 	subscription *dto.Subscription,
+	loc *time.Location,
 ) (*model.Invoice, error) {
-	dailyUsages, err := i.listSubscriptionDailyApiUsages(ctx, subscription)
+	txn, err := db.GetTxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Locking the account serializes the close with the worker flushes, which lock it in share mode
+	// before deciding whether their logs are late.
+	var accountId uint64
+	if err := txn.QueryRowContext(ctx, "SELECT id FROM account WHERE id = ? FOR UPDATE", subscription.AccountID).Scan(&accountId); err != nil {
+		return nil, err
+	}
+
+	// The days are rolled up in the invoice transaction, so daily_api_usage matches the invoice.
+	if err := usage.RollUpDays(ctx, txn, subscription.AccountID, loc, subscription.From, subscription.EstimatedTo); err != nil {
+		return nil, err
+	}
+	dailyUsages, err := i.listSubscriptionDailyApiUsages(ctx, txn, subscription, loc)
 	if err != nil {
 		return nil, err
 	}
@@ -153,7 +233,7 @@ func (i *InvoiceMaker) createInvoice(
 		return nil, err
 	}
 
-	txn, err := db.GetTxn(ctx)
+	adjustmentIds, adjustments, err := i.listPendingUsageAdjustments(ctx, txn, subscription.AccountID, true)
 	if err != nil {
 		return nil, err
 	}
@@ -165,28 +245,57 @@ func (i *InvoiceMaker) createInvoice(
 		dailyUsages,
 		tax.DefaultTaxRate,
 		priceTable,
+		adjustments...,
 	)
 
 	query := "INSERT INTO invoice " +
-		"(account_id, subscription_id, total_usage, free_credit_discount, subtotal, tax_rate, tax_amount, total_price, tax_included_total_price) " +
-		"VALUES (?,?,?,?,?,?,?,?,?)"
+		"(account_id, subscription_id, total_usage, adjustment_usage, free_credit_discount, subtotal, tax_rate, tax_amount, total_price, total_price_tax_included) " +
+		"VALUES (?,?,?,?,?,?,?,?,?,?)"
 
-	if _, err := txn.ExecContext(
+	result, err := txn.ExecContext(
 		ctx,
 		query,
 		subscription.AccountID,
 		subscription.ID,
 		uint(invoice.TotalUsage()),
+		uint(invoice.AdjustmentUsage()),
 		uint(invoice.FreeCreditUsage()),
 		invoice.SubtotalString(),
 		invoice.TaxRate().Uint8(),
 		invoice.TaxAmountString(),
 		invoice.TotalPriceString(),
 		uint(invoice.TaxIncludedTotalPrice()),
-	); err != nil {
+	)
+	if err != nil {
+		return nil, err
+	}
+	invoiceId, err := result.LastInsertId()
+	if err != nil {
 		return nil, err
 	}
 
+	if len(adjustmentIds) > 0 {
+		args := append([]any{invoiceId}, adjustmentIds...)
+		if _, err := txn.ExecContext(
+			ctx,
+			"UPDATE usage_adjustment SET invoice_id = ? WHERE id IN (?"+strings.Repeat(", ?", len(adjustmentIds)-1)+")",
+			args...,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	closedAt := now.FromContext(ctx).UTC()
+	closed, err := txn.ExecContext(ctx, "UPDATE subscription SET closed_at = ? WHERE id = ? AND closed_at IS NULL", closedAt, subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := closed.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, fmt.Errorf("subscription %d is already closed", subscription.ID)
+	}
+
 	if invoice.FreeCreditUsage() > 0 {
 		reduceBalance := &dto.AccountFreeCreditBalance{
 			AccountID: subscription.AccountID,
@@ -207,36 +316,100 @@ func (i *InvoiceMaker) createInvoice(
 		}
 	}
 
+	if err := audit.Record(ctx, &audit.Event{
+		Action:   "invoice.create",
+		Entity:   "invoice",
+		EntityId: uint64(invoiceId),
+		After: map[string]any{
+			"account_id":      subscription.AccountID,
+			"subscription_id": subscription.ID,
+			"invoice":         invoice,
+			"closed_at":       closedAt,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
 	return invoice, nil
 }
 
-func (i *InvoiceMaker) publishNotifyQueue(ctx context.Context, invoice *model.Invoice) { /* todo */ }
+// listPendingUsageAdjustments returns the ids of the rows of the late usage of the account's
+// closed periods that is not invoiced yet and its usage per period and meter. With lock the
+// rows are locked for the invoice transaction.
+func (i *InvoiceMaker) listPendingUsageAdjustments(ctx context.Context, conn dto.DB, accountId uint64, lock bool) ([]any, []*model.UsageAdjustment, error) {
+	query := "SELECT id, subscription_id, `meter`, `usage` FROM usage_adjustment WHERE account_id = ? AND invoice_id IS NULL ORDER BY id"
+	if lock {
+		query += " FOR UPDATE"
+	}
+	rows, err := conn.QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
 
-func (i *InvoiceMaker) listSubscriptions(ctx context.Context, t time.Time) ([]*dto.Subscription, error) {
-	cutoff := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	type key struct {
+		subscriptionId uint64
+		meter          string
+	}
+	var ids []any
+	var keys []key
+	usages := make(map[key]uint64)
+	for rows.Next() {
+		var id, adjustmentUsage uint64
+		var k key
+		if err := rows.Scan(&id, &k.subscriptionId, &k.meter, &adjustmentUsage); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		if _, ok := usages[k]; !ok {
+			keys = append(keys, k)
+		}
+		usages[k] += adjustmentUsage
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
 
-	query := "SELECT s.id, s.account_id, s.from, s.estimated_to " +
+	adjustments := make([]*model.UsageAdjustment, len(keys))
+	for n, k := range keys {
+		adjustments[n] = model.NewUsageAdjustment(k.subscriptionId, k.meter, usages[k])
+	}
+	return ids, adjustments, nil
+}
+
+func (i *InvoiceMaker) publishNotifyQueue(ctx context.Context, invoice *model.Invoice) { /* todo */ }
+
+// listSubscriptions returns the open subscription periods that ended at least grace ago.
+func (i *InvoiceMaker) listSubscriptions(ctx context.Context, grace time.Duration) ([]*dueSubscription, error) {
+	query := "SELECT s.id, s.account_id, s.from, s.estimated_to, a.timezone " +
 		"FROM account a JOIN subscription s ON a.id = s.account_id " +
-		"WHERE s.estimated_to = ?"
-	rows, err := i.dbConn.QueryContext(ctx, query, cutoff)
+		"WHERE s.closed_at IS NULL AND s.estimated_to <= ?"
+	rows, err := i.dbConn.QueryContext(ctx, query, now.FromContext(ctx).Add(-grace))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var subscriptions []*dto.Subscription
+	var subscriptions []*dueSubscription
 	for rows.Next() {
 		var dst dto.Subscription
+		var timezone sql.NullString
 		if err := rows.Scan(
 			&dst.ID,
 			&dst.AccountID,
 			&dst.From,
 			&dst.EstimatedTo,
+			&timezone,
 		); err != nil {
 			return nil, err
 		}
-		subscriptions = append(subscriptions, &dst)
+		loc, err := usage.Location(timezone)
+		if err != nil {
+			slog.Error("Skip subscription of account with invalid timezone", "subscriptionId", dst.ID, "accountId", dst.AccountID, "error", err)
+			continue
+		}
+		subscriptions = append(subscriptions, &dueSubscription{Subscription: &dst, loc: loc})
 	}
 
-	return subscriptions, nil
+	return subscriptions, rows.Err()
 }
@@ -0,0 +1,137 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+// stubConnector answers a query with the rows of the first result it contains the match of,
+// and with no rows otherwise. It records the last query and its args.
+type stubConnector struct {
+	results []stubResult
+	query   string
+	args    []driver.NamedValue
+}
+
+type stubResult struct {
+	match string
+	rows  [][]driver.Value
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) { return c, nil }
+
+func (c *stubConnector) Driver() driver.Driver { return nil }
+
+func (c *stubConnector) Prepare(string) (driver.Stmt, error) { return nil, errors.ErrUnsupported }
+
+func (c *stubConnector) Close() error { return nil }
+
+func (c *stubConnector) Begin() (driver.Tx, error) { return nil, errors.ErrUnsupported }
+
+func (c *stubConnector) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.query, c.args = query, args
+	for _, r := range c.results {
+		if strings.Contains(query, r.match) {
+			return &stubRows{rows: r.rows}, nil
+		}
+	}
+	return &stubRows{}, nil
+}
+
+type stubRows struct {
+	rows [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *stubRows) Close() error { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestInvoiceMaker_listSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	conn := &stubConnector{results: []stubResult{{match: "JOIN subscription", rows: [][]driver.Value{
+		{int64(1), int64(10), from, to, nil},
+		{int64(2), int64(20), from, to, "Asia/Tokyo"},
+		{int64(3), int64(30), from, to, "Mars/Olympus"},
+	}}}}
+	dbConn := sql.OpenDB(conn)
+	t.Cleanup(func() { dbConn.Close() })
+
+	ctx := now.WithContext(context.Background(), time.Date(2025, 7, 2, 1, 0, 0, 0, time.UTC))
+	got, err := NewInvoiceMaker(dbConn, nil).listSubscriptions(ctx, 24*time.Hour)
+	require.NoError(t, err)
+
+	assert.Contains(t, conn.query, "s.closed_at IS NULL", "closed periods are not invoiced again")
+	assert.Contains(t, conn.query, "s.estimated_to <= ?")
+	require.Len(t, conn.args, 1)
+	assert.Equal(t, time.Date(2025, 7, 1, 1, 0, 0, 0, time.UTC), conn.args[0].Value, "periods are due grace after they ended")
+
+	require.Len(t, got, 2, "the subscription of an invalid timezone is skipped")
+	assert.Equal(t, uint64(1), got[0].ID)
+	assert.Equal(t, uint64(10), got[0].AccountID)
+	assert.Equal(t, time.UTC, got[0].loc)
+	assert.True(t, to.Equal(got[0].EstimatedTo))
+	assert.Equal(t, uint64(2), got[1].ID)
+	assert.Equal(t, "Asia/Tokyo", got[1].loc.String())
+}
+
+func TestInvoiceMaker_Preview(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 11, 0, 0, 0, 0, time.UTC)
+	dbConn := sql.OpenDB(&stubConnector{results: []stubResult{
+		{match: "FROM subscription", rows: [][]driver.Value{{int64(3), int64(1), from, to}}},
+		{match: "FROM usage_based_billing.account", rows: [][]driver.Value{{int64(1), "acme", nil, from, from}}},
+		{match: "FROM every_minute_api_usage", rows: [][]driver.Value{
+			{"202507010000", "", int64(1000)},
+			{"202507011200", "", int64(1000)},
+		}},
+		// late usage of the closed period 2
+		{match: "FROM usage_adjustment", rows: [][]driver.Value{
+			{int64(7), int64(2), "", int64(300)},
+			{int64(8), int64(2), "", int64(200)},
+		}},
+	}})
+	t.Cleanup(func() { dbConn.Close() })
+
+	// a fifth of the period has passed
+	ctx := now.WithContext(context.Background(), time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC))
+	got, err := NewInvoiceMaker(dbConn, nil).Preview(ctx, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2500), got.ToDate.TotalUsage(), "pending adjustments are billed by the next invoice")
+	assert.Equal(t, uint64(500), got.ToDate.AdjustmentUsage())
+	assert.Equal(t, "2.50000", got.ToDate.SubtotalString())
+	assert.Equal(t, uint64(10500), got.ProjectedUsage, "adjustments are not extrapolated")
+	assert.Equal(t, got.ProjectedUsage, got.Projected.TotalUsage())
+	assert.Equal(t, uint64(500), got.Projected.AdjustmentUsage())
+}
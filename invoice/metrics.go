@@ -0,0 +1,37 @@
+package invoice
+
+import (
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice/model"
+)
+
+var (
+	invoicesCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "invoice_created_total",
+		Help: "Invoices created by InvoiceMaker.",
+	})
+
+	invoicesFailedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "invoice_failed_total",
+		Help: "Subscriptions whose invoice could not be created.",
+	})
+
+	billedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "invoice_billed_total",
+		Help: "Sum of the tax included totals of created invoices.",
+	})
+)
+
+func observeInvoice(invoice *model.Invoice, err error) {
+	if err != nil {
+		slog.Error("Failed to createInvoice", "error", err)
+		invoicesFailedTotal.Inc()
+		return
+	}
+	invoicesCreatedTotal.Inc()
+	billedTotal.Add(float64(invoice.TaxIncludedTotalPrice()))
+}
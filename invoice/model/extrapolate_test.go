@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtrapolateUsage(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		usage   uint64
+		elapsed time.Duration
+		period  time.Duration
+	}
+
+	tests := []struct {
+		args args
+		want uint64
+	}{
+		{
+			args: args{usage: 1000, elapsed: 24 * time.Hour * 10, period: 24 * time.Hour * 30},
+			want: 3000,
+		},
+		{
+			args: args{usage: 1000, elapsed: 24 * time.Hour * 7, period: 24 * time.Hour * 31},
+			want: 4428,
+		},
+		{
+			args: args{usage: 1000, elapsed: 0, period: 24 * time.Hour * 30},
+			want: 1000,
+		},
+		{
+			args: args{usage: 1000, elapsed: 24 * time.Hour * 31, period: 24 * time.Hour * 30},
+			want: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtrapolateUsage(tt.args.usage, tt.args.elapsed, tt.args.period))
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"math/big"
+	"slices"
 	"time"
 
 	"github.com/samber/lo"
@@ -17,6 +20,7 @@ import (
 type (
 	PriceTable struct {
 		item                          *PriceTableItem
+		meterPrices                   MeterPrices
 		additionalRangePricesPerUsage RangePrices
 	}
 
@@ -32,18 +36,58 @@ type (
 	}
 
 	RangePrices []*RangePrice
+
+	// MeterPrices are the prices per usage of the meters priced apart from the base price.
+	MeterPrices map[string]*big.Rat
 )
 
-func NewPriceTable(rangePrices RangePrices) *PriceTable {
+func NewPriceTable(rangePrices RangePrices, meterPrices MeterPrices) *PriceTable {
 	return &PriceTable{
 		item: &PriceTableItem{
 			applyStartedAt:    time.Time{},
 			basePricePerUsage: take.Left(new(big.Rat).SetString("0.001")),
 		},
+		meterPrices:                   meterPrices,
 		additionalRangePricesPerUsage: rangePrices,
 	}
 }
 
+// PricePerUsage returns the price of one unit of meter.
+func (pt *PriceTable) PricePerUsage(meter string) *big.Rat {
+	if price, ok := pt.meterPrices[meter]; ok {
+		return price
+	}
+	return pt.item.basePricePerUsage
+}
+
+type MeterPriceBuilder struct {
+	prices MeterPrices
+	errs   []error
+}
+
+func (b *MeterPriceBuilder) Set(meter string, pricePerUsage string) {
+	rat, err := parser.NewRatFromString(pricePerUsage)
+	if err != nil {
+		b.errs = append(b.errs, err)
+		return
+	}
+	if rat.Sign() < 0 {
+		b.errs = append(b.errs, fmt.Errorf("negative price %s for meter %q", rat.FloatString(5), meter))
+		return
+	}
+	if b.prices == nil {
+		b.prices = make(MeterPrices)
+	}
+	b.prices[meter] = rat
+}
+
+func (b *MeterPriceBuilder) Build() (MeterPrices, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	return b.prices, nil
+}
+
 type RangePriceBuilder struct {
 	items []*RangePrice
 	errs  []error
@@ -62,9 +106,22 @@ func (b *RangePriceBuilder) Set(minUsage, maxUsage int, pricePerUsage string) {
 	})
 }
 
+// Build validates that ranges are added in ascending order without overlapping.
 func (b *RangePriceBuilder) Build() (RangePrices, error) {
-	if len(b.errs) > 0 {
-		return nil, errors.Join(b.errs...)
+	errs := b.errs
+	for i, item := range b.items {
+		if item.minUsage < 0 || item.maxUsage <= item.minUsage {
+			errs = append(errs, fmt.Errorf("invalid range [%d, %d)", item.minUsage, item.maxUsage))
+		}
+		if item.price.Sign() < 0 {
+			errs = append(errs, fmt.Errorf("negative price %s for range [%d, %d)", item.price.FloatString(5), item.minUsage, item.maxUsage))
+		}
+		if i > 0 && item.minUsage < b.items[i-1].maxUsage {
+			errs = append(errs, fmt.Errorf("range [%d, %d) overlaps [%d, %d)", item.minUsage, item.maxUsage, b.items[i-1].minUsage, b.items[i-1].maxUsage))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 	return b.items, nil
 }
@@ -77,10 +134,28 @@ type CalculateResult struct {
 }
 
 func (pt *PriceTable) MustCalculate(dailyUsages []*DailyApiUsage, freeCredit uint64) *CalculateResult {
-	totalUsage := lo.SumBy(dailyUsages, func(du *DailyApiUsage) uint64 {
-		return du.Usage()
-	})
+	return pt.mustCalculateTotal(usagesByMeter(dailyUsages, nil), freeCredit)
+}
+
+// usagesByMeter sums the usage of the days and the adjustments per meter.
+func usagesByMeter(dailyUsages []*DailyApiUsage, adjustments []*UsageAdjustment) map[string]uint64 {
+	usages := make(map[string]uint64)
+	for _, du := range dailyUsages {
+		usages[du.meter] += du.usage
+	}
+	for _, a := range adjustments {
+		usages[a.meter] += a.usage
+	}
+	return usages
+}
 
+// mustCalculateTotal prices the usage of each meter at its price per usage.
+// The free credit covers the usage of the meters in the order of their names.
+func (pt *PriceTable) mustCalculateTotal(usages map[string]uint64, freeCredit uint64) *CalculateResult {
+	var totalUsage uint64
+	for _, u := range usages {
+		totalUsage += u
+	}
 	freeCreditUsage := int64(freeCredit)
 	totalUsageAfterCerditApplied := int64(totalUsage) - int64(freeCredit)
 	if totalUsageAfterCerditApplied < 0 {
@@ -90,14 +165,23 @@ func (pt *PriceTable) MustCalculate(dailyUsages []*DailyApiUsage, freeCredit uin
 
 	subtotal := new(big.Rat).SetInt64(0)
 	if totalUsageAfterCerditApplied > 0 {
-		var err error
-		subtotal, err = parser.NewRatFromString(fmt.Sprintf(
-			"%d * (%s)",
-			totalUsageAfterCerditApplied,
-			pt.item.basePricePerUsage.RatString(),
-		))
-		if err != nil {
-			panic(err)
+		credit := freeCredit
+		for _, meter := range slices.Sorted(maps.Keys(usages)) {
+			covered := min(usages[meter], credit)
+			credit -= covered
+			billed := usages[meter] - covered
+			if billed == 0 {
+				continue
+			}
+			amount, err := parser.NewRatFromString(fmt.Sprintf(
+				"%d * (%s)",
+				billed,
+				pt.PricePerUsage(meter).RatString(),
+			))
+			if err != nil {
+				panic(err)
+			}
+			subtotal.Add(subtotal, amount)
 		}
 
 		// for _, additional := range pt.additionalRangePricesPerUsage {
@@ -112,14 +196,17 @@ func (pt *PriceTable) MustCalculate(dailyUsages []*DailyApiUsage, freeCredit uin
 	}
 }
 
+// DailyApiUsage is the usage of a meter on a day.
 type DailyApiUsage struct {
 	date  time.Time
+	meter string
 	usage uint64
 }
 
-func NewDailyApiUsage(date time.Time, usage uint64) *DailyApiUsage {
+func NewDailyApiUsage(date time.Time, meter string, usage uint64) *DailyApiUsage {
 	return &DailyApiUsage{
 		date:  date,
+		meter: meter,
 		usage: usage,
 	}
 }
@@ -128,12 +215,53 @@ func (du *DailyApiUsage) Date() time.Time {
 	return du.date
 }
 
+func (du *DailyApiUsage) Meter() string {
+	return du.meter
+}
+
 func (du *DailyApiUsage) Usage() uint64 {
 	return du.usage
 }
 
+// UsageAdjustment is late usage of a meter in an earlier, already invoiced period billed on the next invoice.
+type UsageAdjustment struct {
+	subscriptionId uint64
+	meter          string
+	usage          uint64
+}
+
+func NewUsageAdjustment(subscriptionId uint64, meter string, usage uint64) *UsageAdjustment {
+	return &UsageAdjustment{
+		subscriptionId: subscriptionId,
+		meter:          meter,
+		usage:          usage,
+	}
+}
+
+func (a *UsageAdjustment) SubscriptionId() uint64 {
+	return a.subscriptionId
+}
+
+func (a *UsageAdjustment) Meter() string {
+	return a.meter
+}
+
+func (a *UsageAdjustment) Usage() uint64 {
+	return a.usage
+}
+
+func (a *UsageAdjustment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"subscription_id": a.subscriptionId,
+		"meter":           a.meter,
+		"usage":           a.usage,
+	})
+}
+
 type Invoice struct {
 	totalUsage            uint64
+	adjustmentUsage       uint64
+	adjustments           []*UsageAdjustment
 	freeCreditUsage       uint64
 	subtotal              *big.Rat
 	totalPrice            *big.Rat
@@ -149,8 +277,12 @@ func NewInvoice(
 	dailyUsages []*DailyApiUsage,
 	taxRate tax.TaxRate,
 	priceTable *PriceTable,
+	adjustments ...*UsageAdjustment,
 ) *Invoice {
-	result := priceTable.MustCalculate(dailyUsages, freeCreditBalance)
+	adjustmentUsage := lo.SumBy(adjustments, func(a *UsageAdjustment) uint64 {
+		return a.Usage()
+	})
+	result := priceTable.mustCalculateTotal(usagesByMeter(dailyUsages, adjustments), freeCreditBalance)
 
 	taxIncludedPriceRat := take.Left(parser.NewRatFromString(fmt.Sprintf(
 		"(%s) * ((%s+100)/100)",
@@ -167,6 +299,8 @@ func NewInvoice(
 
 	return &Invoice{
 		totalUsage:            result.TotalUsage,
+		adjustmentUsage:       adjustmentUsage,
+		adjustments:           adjustments,
 		freeCreditUsage:       result.FreeCreditUsage,
 		subtotal:              result.Subtotal,
 		totalPrice:            result.TotalPrice,
@@ -176,14 +310,28 @@ func NewInvoice(
 	}
 }
 
+// TotalUsage returns the billed usage including AdjustmentUsage.
 func (i *Invoice) TotalUsage() uint64 {
 	return i.totalUsage
 }
 
+// AdjustmentUsage returns the late usage of earlier periods billed on this invoice.
+func (i *Invoice) AdjustmentUsage() uint64 {
+	return i.adjustmentUsage
+}
+
+func (i *Invoice) Adjustments() []*UsageAdjustment {
+	return i.adjustments
+}
+
 func (i *Invoice) FreeCreditUsage() uint64 {
 	return i.freeCreditUsage
 }
 
+func (i *Invoice) TotalPrice() *big.Rat {
+	return new(big.Rat).Set(i.totalPrice)
+}
+
 func (i *Invoice) TotalPriceString() string {
 	return i.totalPrice.FloatString(5)
 }
@@ -203,3 +351,33 @@ func (i *Invoice) TaxAmountString() string {
 func (i *Invoice) SubtotalString() string {
 	return i.subtotal.FloatString(5)
 }
+
+func (i *Invoice) MarshalJSON() ([]byte, error) {
+	v := map[string]any{
+		"total_usage":              i.totalUsage,
+		"free_credit_usage":        i.freeCreditUsage,
+		"subtotal":                 i.SubtotalString(),
+		"total_price":              i.TotalPriceString(),
+		"tax_rate":                 i.taxRate.Uint8(),
+		"tax_amount":               i.TaxAmountString(),
+		"tax_included_total_price": i.taxIncludedTotalPrice,
+	}
+	if len(i.adjustments) > 0 {
+		v["adjustment_usage"] = i.adjustmentUsage
+		v["adjustments"] = i.adjustments
+	}
+	return json.Marshal(v)
+}
+
+// ExtrapolateUsage projects usage observed over elapsed onto the whole period
+// assuming the current run rate continues.
+func ExtrapolateUsage(usage uint64, elapsed, period time.Duration) uint64 {
+	if elapsed <= 0 || elapsed >= period {
+		return usage
+	}
+	projected := new(big.Rat).Mul(
+		new(big.Rat).SetUint64(usage),
+		big.NewRat(int64(period), int64(elapsed)),
+	)
+	return new(big.Int).Quo(projected.Num(), projected.Denom()).Uint64()
+}
@@ -18,6 +18,7 @@ func TestNewInvoice(t *testing.T) {
 		dailyUsages       []*DailyApiUsage
 		freeCreditBalance uint64
 		priceTable        *PriceTable
+		adjustments       []*UsageAdjustment
 	}
 
 	tests := []struct {
@@ -37,7 +38,7 @@ func TestNewInvoice(t *testing.T) {
 					},
 				},
 				freeCreditBalance: 0,
-				priceTable:        NewPriceTable(nil),
+				priceTable:        NewPriceTable(nil, nil),
 			},
 			want: &Invoice{
 				totalUsage:            20000,
@@ -62,7 +63,7 @@ func TestNewInvoice(t *testing.T) {
 					},
 				},
 				freeCreditBalance: 0,
-				priceTable:        NewPriceTable(nil),
+				priceTable:        NewPriceTable(nil, nil),
 			},
 			want: &Invoice{
 				totalUsage:            200000,
@@ -91,7 +92,7 @@ func TestNewInvoice(t *testing.T) {
 					},
 				},
 				freeCreditBalance: 100000,
-				priceTable:        NewPriceTable(nil),
+				priceTable:        NewPriceTable(nil, nil),
 			},
 			want: &Invoice{
 				totalUsage:            300000,
@@ -116,7 +117,7 @@ func TestNewInvoice(t *testing.T) {
 					},
 				},
 				freeCreditBalance: 0,
-				priceTable:        NewPriceTable(nil),
+				priceTable:        NewPriceTable(nil, nil),
 			},
 			want: &Invoice{
 				totalUsage:            256783,
@@ -128,6 +129,76 @@ func TestNewInvoice(t *testing.T) {
 				taxAmount:             take.Left(new(big.Rat).SetString("25.21700")),
 			},
 		},
+		{
+			args: args{
+				dailyUsages: []*DailyApiUsage{
+					{
+						date:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+						usage: 10000,
+					},
+				},
+				freeCreditBalance: 5000,
+				priceTable:        NewPriceTable(nil, nil),
+				adjustments: []*UsageAdjustment{
+					NewUsageAdjustment(2, "", 3000),
+					NewUsageAdjustment(3, "", 2000),
+				},
+			},
+			want: &Invoice{
+				totalUsage:      15000,
+				adjustmentUsage: 5000,
+				adjustments: []*UsageAdjustment{
+					{subscriptionId: 2, usage: 3000},
+					{subscriptionId: 3, usage: 2000},
+				},
+				freeCreditUsage:       5000,
+				subtotal:              take.Left(new(big.Rat).SetString("10.00000")),
+				totalPrice:            take.Left(new(big.Rat).SetString("10.00000")),
+				taxIncludedTotalPrice: 11,
+				taxRate:               tax.DefaultTaxRate,
+				taxAmount:             take.Left(new(big.Rat).SetString("1.00000")),
+			},
+		},
+		{
+			// the free credit covers api1 first, download is priced apart and llm at the base price
+			args: args{
+				dailyUsages: []*DailyApiUsage{
+					{
+						date:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+						meter: "api1",
+						usage: 1000,
+					},
+					{
+						date:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+						meter: "download",
+						usage: 4000,
+					},
+					{
+						date:  time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+						meter: "llm",
+						usage: 2000,
+					},
+				},
+				freeCreditBalance: 1500,
+				priceTable:        NewPriceTable(nil, MeterPrices{"download": big.NewRat(1, 200)}),
+				adjustments: []*UsageAdjustment{
+					NewUsageAdjustment(2, "download", 1000),
+				},
+			},
+			want: &Invoice{
+				totalUsage:      8000,
+				adjustmentUsage: 1000,
+				adjustments: []*UsageAdjustment{
+					{subscriptionId: 2, meter: "download", usage: 1000},
+				},
+				freeCreditUsage:       1500,
+				subtotal:              take.Left(new(big.Rat).SetString("24.50000")),
+				totalPrice:            take.Left(new(big.Rat).SetString("24.50000")),
+				taxIncludedTotalPrice: 26,
+				taxRate:               tax.DefaultTaxRate,
+				taxAmount:             take.Left(new(big.Rat).SetString("1.50000")),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,6 +210,7 @@ func TestNewInvoice(t *testing.T) {
 				tt.args.dailyUsages,
 				tax.DefaultTaxRate,
 				tt.args.priceTable,
+				tt.args.adjustments...,
 			)
 			if !assert.Equal(t, tt.want, got) {
 				t.Log(got.TotalUsage())
@@ -150,3 +222,82 @@ func TestNewInvoice(t *testing.T) {
 		})
 	}
 }
+
+func TestMeterPriceBuilder_Build(t *testing.T) {
+	t.Parallel()
+
+	var builder MeterPriceBuilder
+	builder.Set("api1", "0.002")
+	builder.Set("download", "0.0005")
+	prices, err := builder.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, MeterPrices{"api1": big.NewRat(1, 500), "download": big.NewRat(1, 2000)}, prices)
+
+	pt := NewPriceTable(nil, prices)
+	assert.Equal(t, big.NewRat(1, 500), pt.PricePerUsage("api1"))
+	assert.Equal(t, big.NewRat(1, 1000), pt.PricePerUsage("llm"), "meters without a price use the base price")
+
+	builder.Set("llm", "-0.001")
+	builder.Set("lookup", "abc")
+	_, err = builder.Build()
+	assert.Error(t, err)
+}
+
+func TestRangePriceBuilder_Build(t *testing.T) {
+	t.Parallel()
+
+	type rangePrice struct {
+		minUsage      int
+		maxUsage      int
+		pricePerUsage string
+	}
+
+	tests := []struct {
+		args    []rangePrice
+		wantErr bool
+	}{
+		{
+			args: []rangePrice{
+				{minUsage: 0, maxUsage: 10000, pricePerUsage: "0.001"},
+				{minUsage: 10000, maxUsage: 100000, pricePerUsage: "0.0008"},
+			},
+			wantErr: false,
+		},
+		{
+			args: []rangePrice{
+				{minUsage: 0, maxUsage: 10000, pricePerUsage: "0.001"},
+				{minUsage: 9999, maxUsage: 100000, pricePerUsage: "0.0008"},
+			},
+			wantErr: true,
+		},
+		{
+			args: []rangePrice{
+				{minUsage: 100, maxUsage: 100, pricePerUsage: "0.001"},
+			},
+			wantErr: true,
+		},
+		{
+			args: []rangePrice{
+				{minUsage: 0, maxUsage: 100, pricePerUsage: "-0.001"},
+			},
+			wantErr: true,
+		},
+		{
+			args: []rangePrice{
+				{minUsage: 0, maxUsage: 100, pricePerUsage: "abc"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			var builder RangePriceBuilder
+			for _, rp := range tt.args {
+				builder.Set(rp.minUsage, rp.maxUsage, rp.pricePerUsage)
+			}
+			_, err := builder.Build()
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
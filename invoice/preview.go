@@ -0,0 +1,190 @@
+package invoice
+
+import (
+	"context"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice/model"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tax"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+type Preview struct {
+	AccountId      uint64         `json:"account_id"`
+	SubscriptionId uint64         `json:"subscription_id"`
+	PeriodFrom     time.Time      `json:"period_from"`
+	PeriodTo       time.Time      `json:"period_to"`
+	AsOf           time.Time      `json:"as_of"`
+	ToDate         *model.Invoice `json:"to_date"`
+	ProjectedUsage uint64         `json:"projected_usage"`
+	Projected      *model.Invoice `json:"projected"`
+}
+
+// Preview calculates the invoice of the account's current subscription from the usage so far
+// and the pending late usage of closed periods, which the invoice bills as well. Only the usage
+// of the period is extrapolated. Nothing is persisted.
+func (i *InvoiceMaker) Preview(ctx context.Context, accountId uint64) (*Preview, error) {
+	asOf := now.FromContext(ctx)
+
+	subscription, err := i.getCurrentSubscription(ctx, accountId, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := usage.AccountLocation(ctx, i.dbConn, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	dailyUsages, err := i.listSubscriptionDailyApiUsagesToDate(ctx, subscription, loc, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	freeCredit, err := i.getFreeCreditBalanceByAccountId(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	priceTable, err := i.getPriceTable(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the next invoice also bills the late usage of closed periods
+	_, adjustments, err := i.listPendingUsageAdjustments(ctx, i.dbConn, accountId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	toDate := model.NewInvoice(
+		accountId,
+		subscription.ID,
+		freeCredit,
+		dailyUsages,
+		tax.DefaultTaxRate,
+		priceTable,
+		adjustments...,
+	)
+
+	// each meter is extrapolated on its own, they may be priced differently
+	var meters []string
+	toDateUsages := make(map[string]uint64)
+	for _, du := range dailyUsages {
+		if _, ok := toDateUsages[du.Meter()]; !ok {
+			meters = append(meters, du.Meter())
+		}
+		toDateUsages[du.Meter()] += du.Usage()
+	}
+	projectedUsage := toDate.AdjustmentUsage()
+	projectedUsages := make([]*model.DailyApiUsage, len(meters))
+	for n, meter := range meters {
+		meterUsage := model.ExtrapolateUsage(
+			toDateUsages[meter],
+			asOf.Sub(subscription.From),
+			subscription.EstimatedTo.Sub(subscription.From),
+		)
+		projectedUsage += meterUsage
+		projectedUsages[n] = model.NewDailyApiUsage(subscription.EstimatedTo, meter, meterUsage)
+	}
+	projected := model.NewInvoice(
+		accountId,
+		subscription.ID,
+		freeCredit,
+		projectedUsages,
+		tax.DefaultTaxRate,
+		priceTable,
+		adjustments...,
+	)
+
+	return &Preview{
+		AccountId:      accountId,
+		SubscriptionId: subscription.ID,
+		PeriodFrom:     subscription.From,
+		PeriodTo:       subscription.EstimatedTo,
+		AsOf:           asOf,
+		ToDate:         toDate,
+		ProjectedUsage: projectedUsage,
+		Projected:      projected,
+	}, nil
+}
+
+func (i *InvoiceMaker) getCurrentSubscription(ctx context.Context, accountId uint64, t time.Time) (*dto.Subscription, error) {
+	query := "SELECT s.id, s.account_id, s.from, s.estimated_to " +
+		"FROM subscription s " +
+		"WHERE s.account_id = ? AND s.from <= ? AND s.estimated_to > ? " +
+		"ORDER BY s.from DESC LIMIT 1"
+
+	var dst dto.Subscription
+	if err := i.dbConn.QueryRowContext(ctx, query, accountId, t, t).Scan(
+		&dst.ID,
+		&dst.AccountID,
+		&dst.From,
+		&dst.EstimatedTo,
+	); err != nil {
+		return nil, err
+	}
+
+	return &dst, nil
+}
+
+// listSubscriptionDailyApiUsagesToDate sums every_minute_api_usage per day in loc and meter
+// because daily_api_usage is only complete for closed days.
+func (i *InvoiceMaker) listSubscriptionDailyApiUsagesToDate(ctx context.Context, subscription *dto.Subscription, loc *time.Location, t time.Time) ([]*model.DailyApiUsage, error) {
+	rows, err := i.dbConn.QueryContext(
+		ctx,
+		"SELECT `minute`, `meter`, `usage` FROM every_minute_api_usage "+
+			"WHERE account_id = ? AND `minute` >= ? AND `minute` <= ? "+
+			"ORDER BY `minute` ASC",
+		subscription.AccountID,
+		usage.GranularityMinute.Key(subscription.From, time.UTC),
+		usage.GranularityMinute.Key(t, time.UTC),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type key struct {
+		date  string
+		meter string
+	}
+	var keys []key
+	sums := make(map[key]uint64)
+	for rows.Next() {
+		var minute string
+		var meter string
+		var minuteUsage uint64
+		if err := rows.Scan(
+			&minute,
+			&meter,
+			&minuteUsage,
+		); err != nil {
+			return nil, err
+		}
+		m, err := usage.GranularityMinute.Parse(minute, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		k := key{date: usage.GranularityDay.Key(m, loc), meter: meter}
+		if _, ok := sums[k]; !ok {
+			keys = append(keys, k)
+		}
+		sums[k] += minuteUsage
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.DailyApiUsage, len(keys))
+	for n, k := range keys {
+		d, err := usage.GranularityDay.Parse(k.date, loc)
+		if err != nil {
+			return nil, err
+		}
+		result[n] = model.NewDailyApiUsage(d, k.meter, sums[k])
+	}
+	return result, nil
+}
@@ -0,0 +1,210 @@
+// Package render turns persisted invoices into PDF and HTML documents for customers.
+package render
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+// Issuer is the seller printed on the documents.
+type Issuer struct {
+	Name               string
+	Address            string
+	Email              string
+	RegistrationNumber string
+}
+
+// Period is a billing period, To is exclusive.
+type Period struct {
+	From, To time.Time
+}
+
+// Adjustment is late usage of an earlier period billed on the invoice.
+type Adjustment struct {
+	Period Period
+	Usage  uint64
+}
+
+// Document is a persisted invoice with what its documents show besides it.
+type Document struct {
+	InvoiceId             uint64
+	AccountId             uint64
+	AccountName           string
+	Location              *time.Location
+	Period                Period
+	TotalUsage            uint64
+	AdjustmentUsage       uint64
+	Adjustments           []*Adjustment
+	FreeCreditDiscount    uint64
+	Subtotal              *big.Rat
+	TaxRate               uint8
+	TaxAmount             *big.Rat
+	TotalPrice            *big.Rat
+	TotalPriceTaxIncluded *big.Rat
+	CreatedAt             time.Time
+}
+
+// Load reads the invoice and the late usage billed on it. It returns sql.ErrNoRows when the
+// invoice does not exist.
+func Load(ctx context.Context, conn dto.DB, invoiceId uint64) (*Document, error) {
+	var (
+		doc                                                    Document
+		timezone                                               sql.NullString
+		subtotal, taxAmount, totalPrice, totalPriceTaxIncluded string
+	)
+	if err := conn.QueryRowContext(
+		ctx,
+		"SELECT i.id, i.account_id, a.account_name, a.timezone, s.from, s.estimated_to, i.total_usage, i.adjustment_usage, "+
+			"i.free_credit_discount, i.subtotal, i.tax_rate, i.tax_amount, i.total_price, i.total_price_tax_included, i.created_at "+
+			"FROM invoice i JOIN account a ON a.id = i.account_id JOIN subscription s ON s.id = i.subscription_id "+
+			"WHERE i.id = ?",
+		invoiceId,
+	).Scan(
+		&doc.InvoiceId,
+		&doc.AccountId,
+		&doc.AccountName,
+		&timezone,
+		&doc.Period.From,
+		&doc.Period.To,
+		&doc.TotalUsage,
+		&doc.AdjustmentUsage,
+		&doc.FreeCreditDiscount,
+		&subtotal,
+		&doc.TaxRate,
+		&taxAmount,
+		&totalPrice,
+		&totalPriceTaxIncluded,
+		&doc.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if doc.Location, err = usage.Location(timezone); err != nil {
+		return nil, err
+	}
+	for _, v := range []struct {
+		dst **big.Rat
+		s   string
+	}{
+		{&doc.Subtotal, subtotal},
+		{&doc.TaxAmount, taxAmount},
+		{&doc.TotalPrice, totalPrice},
+		{&doc.TotalPriceTaxIncluded, totalPriceTaxIncluded},
+	} {
+		r, ok := new(big.Rat).SetString(v.s)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal %q of invoice %d", v.s, invoiceId)
+		}
+		*v.dst = r
+	}
+
+	rows, err := conn.QueryContext(
+		ctx,
+		"SELECT s.from, s.estimated_to, SUM(ua.usage) FROM usage_adjustment ua JOIN subscription s ON s.id = ua.subscription_id "+
+			"WHERE ua.invoice_id = ? GROUP BY s.id, s.from, s.estimated_to ORDER BY s.from",
+		invoiceId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a Adjustment
+		if err := rows.Scan(&a.Period.From, &a.Period.To, &a.Usage); err != nil {
+			return nil, err
+		}
+		doc.Adjustments = append(doc.Adjustments, &a)
+	}
+	return &doc, rows.Err()
+}
+
+// Number returns the invoice number printed on the documents.
+func (d *Document) Number() string {
+	return fmt.Sprintf("INV-%08d", d.InvoiceId)
+}
+
+// Line is a line item. Quantity is negative for discounts. Amount is nil for the lines
+// that only count usage.
+type Line struct {
+	Description string
+	Quantity    int64
+	Amount      *big.Rat
+}
+
+// Lines returns the usage of the period, the late usage of earlier periods and the free credit
+// as line items counting usage, followed by the billed usage with the persisted subtotal as its
+// amount. Usage is priced by tiers and meters, so there is no single unit price, and amounts
+// split over the lines would be rounded and might not add up to the subtotal.
+func (d *Document) Lines() []*Line {
+	// free credit beyond the usage is not billed, see model.PriceTable
+	credit := min(d.FreeCreditDiscount, d.TotalUsage)
+
+	lines := []*Line{
+		{Description: "API usage " + d.formatPeriod(d.Period), Quantity: int64(d.TotalUsage - d.AdjustmentUsage)},
+	}
+	for _, a := range d.Adjustments {
+		lines = append(lines, &Line{Description: "Late usage of " + d.formatPeriod(a.Period), Quantity: int64(a.Usage)})
+	}
+	if credit > 0 {
+		lines = append(lines, &Line{Description: "Free credit", Quantity: -int64(credit)})
+	}
+	return append(lines, &Line{Description: "Billed usage", Quantity: int64(d.TotalUsage - credit), Amount: d.Subtotal})
+}
+
+// IssuedOn returns the date of issue in the account's timezone.
+func (d *Document) IssuedOn() string {
+	return d.CreatedAt.In(d.Location).Format(time.DateOnly)
+}
+
+// formatPeriod returns the first and the last day of p in the account's timezone.
+func (d *Document) formatPeriod(p Period) string {
+	return p.From.In(d.Location).Format(time.DateOnly) + " - " + p.To.Add(-time.Nanosecond).In(d.Location).Format(time.DateOnly)
+}
+
+// formatQuantity formats n with thousands separators.
+func formatQuantity(n int64) string {
+	s := fmt.Sprint(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	return sign + group(s)
+}
+
+// formatAmount formats r with thousands separators and at most 5 decimals, the scale of the
+// invoice columns, without trailing zeros.
+func formatAmount(r *big.Rat) string {
+	s := r.FloatString(5)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	if fraction != "" {
+		fraction = "." + fraction
+	}
+	if integer == "0" && fraction == "" {
+		sign = ""
+	}
+	return sign + group(integer) + fraction
+}
+
+func group(digits string) string {
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
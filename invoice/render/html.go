@@ -0,0 +1,17 @@
+package render
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed invoice.html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("invoice").Parse(htmlTemplateText))
+
+// HTML writes doc as a standalone HTML page.
+func (r *Renderer) HTML(w io.Writer, doc *Document) error {
+	return htmlTemplate.Execute(w, r.newPage(doc))
+}
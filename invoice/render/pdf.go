@@ -0,0 +1,125 @@
+package render
+
+import (
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// A4 portrait in millimeters.
+const (
+	pdfMargin = 15.0
+	pdfWidth  = 210.0 - 2*pdfMargin
+	pdfLine   = 6.0
+)
+
+// PDF writes doc as an A4 PDF. It uses the core Helvetica font, so characters outside
+// Windows-1252, e.g. Japanese account names, are not printable.
+func (r *Renderer) PDF(w io.Writer, doc *Document) error {
+	p := r.newPage(doc)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	// the documents of an invoice are reproducible, see the golden files
+	pdf.SetCreationDate(doc.CreatedAt)
+	pdf.SetModificationDate(doc.CreatedAt)
+	pdf.SetCatalogSort(true)
+	pdf.SetTitle(p.Title, true)
+	pdf.SetAuthor(r.issuer.Name, true)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// header: title and invoice details on the left, issuer on the right
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(pdfWidth/2, 10, "INVOICE", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, kv := range [][2]string{
+		{"Invoice No.", p.Number},
+		{"Issued on", p.IssuedOn},
+		{"Billing period", p.Period},
+	} {
+		pdf.CellFormat(30, pdfLine, kv[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(pdfWidth/2-30, pdfLine, tr(kv[1]), "", 1, "L", false, 0, "")
+	}
+	leftY := pdf.GetY()
+
+	pdf.SetY(pdfMargin)
+	for i, line := range p.Issuer {
+		style := ""
+		if i == 0 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.SetX(pdfMargin + pdfWidth/2)
+		pdf.CellFormat(pdfWidth/2, pdfLine-1, tr(line), "", 1, "R", false, 0, "")
+	}
+	pdf.SetY(max(leftY, pdf.GetY()) + 8)
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(pdfWidth, pdfLine, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range p.BillTo {
+		pdf.CellFormat(pdfWidth, pdfLine-1, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(8)
+
+	// line items
+	widths := []float64{115, 30, 35}
+	table(pdf, widths, []string{"Description", "Quantity", "Amount"})
+	for _, l := range p.Lines {
+		pdf.CellFormat(widths[0], pdfLine+1, tr(l.Description), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], pdfLine+1, l.Quantity, "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], pdfLine+1, l.Amount, "B", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	// totals
+	for i, kv := range [][2]string{
+		{"Subtotal", p.Subtotal},
+		{p.TaxLabel, p.TaxAmount},
+		{p.TotalLabel, p.TaxIncluded},
+	} {
+		border := ""
+		if i == 2 {
+			border = "T"
+			pdf.SetFont("Helvetica", "B", 11)
+		}
+		pdf.SetX(pdfMargin + pdfWidth - 75)
+		pdf.CellFormat(40, pdfLine+1, kv[0], border, 0, "L", false, 0, "")
+		pdf.CellFormat(35, pdfLine+1, kv[1], border, 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.Ln(8)
+
+	// tax breakdown
+	widths = []float64{60, 60, 60}
+	table(pdf, widths, []string{"Tax rate", "Taxable amount", "Tax"})
+	pdf.CellFormat(widths[0], pdfLine+1, p.TaxRate, "B", 0, "L", false, 0, "")
+	pdf.CellFormat(widths[1], pdfLine+1, p.Taxable, "B", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[2], pdfLine+1, p.TaxAmount, "B", 1, "R", false, 0, "")
+	pdf.Ln(8)
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(102, 102, 102)
+	pdf.CellFormat(pdfWidth, pdfLine, "Amounts in "+p.Currency+".", "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+// table writes the header row of a table, the first column left aligned and the others right aligned.
+func table(pdf *fpdf.Fpdf, widths []float64, headers []string) {
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, h := range headers {
+		align, ln := "R", 0
+		if i == 0 {
+			align = "L"
+		}
+		if i == len(headers)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(widths[i], pdfLine+1, h, "B", ln, align, true, 0, "")
+	}
+	pdf.SetFont("Helvetica", "", 10)
+}
@@ -0,0 +1,120 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatPDF  Format = "pdf"
+	FormatHTML Format = "html"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatPDF, FormatHTML:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, must be pdf or html", s)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "application/pdf"
+}
+
+type Renderer struct {
+	issuer   Issuer
+	currency string
+}
+
+func NewRenderer(issuer Issuer, currency string) *Renderer {
+	return &Renderer{
+		issuer:   issuer,
+		currency: currency,
+	}
+}
+
+// Render writes the document of doc as format to w.
+func (r *Renderer) Render(w io.Writer, doc *Document, format Format) error {
+	switch format {
+	case FormatPDF:
+		return r.PDF(w, doc)
+	case FormatHTML:
+		return r.HTML(w, doc)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// page is what both formats print, formatted.
+type page struct {
+	Title       string
+	Currency    string
+	Number      string
+	IssuedOn    string
+	Period      string
+	Issuer      []string
+	BillTo      []string
+	Lines       []pageLine
+	Subtotal    string
+	TaxLabel    string
+	TaxRate     string
+	TaxAmount   string
+	Taxable     string
+	TotalLabel  string
+	TaxIncluded string
+}
+
+type pageLine struct {
+	Description string
+	Quantity    string
+	Amount      string
+}
+
+func (r *Renderer) newPage(doc *Document) *page {
+	p := &page{
+		Title:       "Invoice " + doc.Number(),
+		Currency:    r.currency,
+		Number:      doc.Number(),
+		IssuedOn:    doc.IssuedOn(),
+		Period:      doc.formatPeriod(doc.Period),
+		BillTo:      []string{doc.AccountName, fmt.Sprintf("Account ID: %d", doc.AccountId)},
+		Subtotal:    formatAmount(doc.Subtotal),
+		TaxLabel:    fmt.Sprintf("Tax (%d%%)", doc.TaxRate),
+		TaxRate:     fmt.Sprintf("%d%%", doc.TaxRate),
+		TaxAmount:   formatAmount(doc.TaxAmount),
+		Taxable:     formatAmount(doc.TotalPrice),
+		TotalLabel:  "Total (" + r.currency + ")",
+		TaxIncluded: formatAmount(doc.TotalPriceTaxIncluded),
+	}
+
+	p.Issuer = append(p.Issuer, r.issuer.Name)
+	for line := range strings.Lines(r.issuer.Address) {
+		if line = strings.TrimSpace(line); line != "" {
+			p.Issuer = append(p.Issuer, line)
+		}
+	}
+	if r.issuer.Email != "" {
+		p.Issuer = append(p.Issuer, r.issuer.Email)
+	}
+	if r.issuer.RegistrationNumber != "" {
+		p.Issuer = append(p.Issuer, "Registration No. "+r.issuer.RegistrationNumber)
+	}
+
+	for _, l := range doc.Lines() {
+		line := pageLine{
+			Description: l.Description,
+			Quantity:    formatQuantity(l.Quantity),
+		}
+		if l.Amount != nil {
+			line.Amount = formatAmount(l.Amount)
+		}
+		p.Lines = append(p.Lines, line)
+	}
+	return p
+}
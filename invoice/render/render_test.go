@@ -0,0 +1,143 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func testDocument(t *testing.T) *Document {
+	t.Helper()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	rat := func(s string) *big.Rat {
+		r, ok := new(big.Rat).SetString(s)
+		require.True(t, ok, s)
+		return r
+	}
+
+	// 120,000 calls of July and 5,000 late calls of June at 0.001 with 25,000 free calls
+	return &Document{
+		InvoiceId:   42,
+		AccountId:   7,
+		AccountName: "Acme Corp.",
+		Location:    tokyo,
+		Period: Period{
+			From: time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo),
+			To:   time.Date(2025, 8, 1, 0, 0, 0, 0, tokyo),
+		},
+		TotalUsage:      125000,
+		AdjustmentUsage: 5000,
+		Adjustments: []*Adjustment{
+			{
+				Period: Period{
+					From: time.Date(2025, 6, 1, 0, 0, 0, 0, tokyo),
+					To:   time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo),
+				},
+				Usage: 5000,
+			},
+		},
+		FreeCreditDiscount:    25000,
+		Subtotal:              rat("100.00000"),
+		TaxRate:               10,
+		TaxAmount:             rat("10.00000"),
+		TotalPrice:            rat("100.00000"),
+		TotalPriceTaxIncluded: rat("110.00000"),
+		CreatedAt:             time.Date(2025, 8, 1, 15, 30, 0, 0, time.UTC),
+	}
+}
+
+func testRenderer() *Renderer {
+	return NewRenderer(Issuer{
+		Name:               "Usage Based Billing Sample",
+		Address:            "1-2-3 Marunouchi\nChiyoda-ku, Tokyo 100-0005\n",
+		Email:              "billing@example.com",
+		RegistrationNumber: "T1234567890123",
+	}, "JPY")
+}
+
+func TestRenderer_Golden(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []Format{FormatPDF, FormatHTML} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			require.NoError(t, testRenderer().Render(&buf, testDocument(t), format))
+
+			golden := filepath.Join("testdata", "invoice.golden."+string(format))
+			if *update {
+				require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(want, buf.Bytes()), "%s differs, rerun with -update and review the diff", golden)
+		})
+	}
+}
+
+func TestDocument_Lines(t *testing.T) {
+	t.Parallel()
+
+	doc := testDocument(t)
+	lines := doc.Lines()
+
+	require.Len(t, lines, 4)
+	assert.Equal(t, "API usage 2025-07-01 - 2025-07-31", lines[0].Description)
+	assert.Equal(t, int64(120000), lines[0].Quantity)
+	assert.Equal(t, "Late usage of 2025-06-01 - 2025-06-30", lines[1].Description)
+	assert.Equal(t, int64(5000), lines[1].Quantity)
+	assert.Equal(t, "Free credit", lines[2].Description)
+	assert.Equal(t, int64(-25000), lines[2].Quantity)
+	for _, l := range lines[:3] {
+		assert.Nil(t, l.Amount, "usage is priced by tiers, not per line")
+	}
+	assert.Equal(t, "Billed usage", lines[3].Description)
+	assert.Equal(t, int64(100000), lines[3].Quantity)
+	assert.Equal(t, doc.Subtotal, lines[3].Amount)
+
+	// free credit beyond the usage
+	doc.TotalUsage, doc.AdjustmentUsage, doc.Adjustments, doc.FreeCreditDiscount = 1000, 0, nil, 5000
+	doc.Subtotal = new(big.Rat)
+	lines = doc.Lines()
+	require.Len(t, lines, 3)
+	assert.Equal(t, int64(-1000), lines[1].Quantity)
+	assert.Equal(t, int64(0), lines[2].Quantity)
+	assert.Equal(t, "0", formatAmount(lines[2].Amount))
+}
+
+func TestFormatAmount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0"},
+		{"20.00000", "20"},
+		{"256.78300", "256.783"},
+		{"1234567.5", "1,234,567.5"},
+		{"-25", "-25"},
+		{"-0.000001", "0"},
+		{"1/3", "0.33333"},
+	}
+	for _, tt := range tests {
+		r, ok := new(big.Rat).SetString(tt.in)
+		require.True(t, ok)
+		assert.Equal(t, tt.want, formatAmount(r), tt.in)
+	}
+	assert.Equal(t, "-1,000", formatQuantity(-1000))
+	assert.Equal(t, "999", formatQuantity(999))
+}
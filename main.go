@@ -1,6 +1,11 @@
 package main
 
-import "github.com/szks-repo/usage-based-billing-sample/cmd"
+import (
+	// Account timezones are loaded even where the image has no zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/szks-repo/usage-based-billing-sample/cmd"
+)
 
 func main() {
 	cmd.Execute()
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+// SystemActor is recorded when no actor is set on the context, e.g. batch jobs.
+const SystemActor = "system"
+
+type Event struct {
+	Action   string
+	Entity   string
+	EntityId uint64
+	Before   any
+	After    any
+	Reason   string
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ctxkey.Actor{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(ctxkey.Actor{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+// Record appends the event to audit_event. It must be called inside db.RunInTxn
+// so that the event is committed or rolled back together with the mutation.
+func Record(ctx context.Context, e *Event) error {
+	txn, err := db.GetTxn(ctx)
+	if err != nil {
+		return fmt.Errorf("audit.Record: %w", err)
+	}
+
+	before, err := marshal(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshal(e.After)
+	if err != nil {
+		return err
+	}
+
+	row := &dto.AuditEvent{
+		Actor:       ActorFromContext(ctx),
+		Action:      e.Action,
+		Entity:      e.Entity,
+		EntityID:    e.EntityId,
+		BeforeValue: before,
+		AfterValue:  after,
+		Reason:      e.Reason,
+		CreatedAt:   now.FromContext(ctx),
+	}
+	return row.Insert(ctx, txn)
+}
+
+func marshal(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit value: %w", err)
+	}
+	return b, nil
+}
+
+type Filter struct {
+	Actor    string
+	Action   string
+	Entity   string
+	EntityId uint64
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// List returns the events matching f, newest first, so a limit keeps the most recent ones.
+func List(ctx context.Context, conn dto.DB, f *Filter) ([]*dto.AuditEvent, error) {
+	query, args := f.query()
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*dto.AuditEvent
+	for rows.Next() {
+		var e dto.AuditEvent
+		if err := rows.Scan(
+			&e.ID,
+			&e.Actor,
+			&e.Action,
+			&e.Entity,
+			&e.EntityID,
+			&e.BeforeValue,
+			&e.AfterValue,
+			&e.Reason,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, &e)
+	}
+
+	return result, rows.Err()
+}
+
+func (f *Filter) query() (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Entity != "" {
+		where = append(where, "entity = ?")
+		args = append(args, f.Entity)
+	}
+	if f.EntityId != 0 {
+		where = append(where, "entity_id = ?")
+		args = append(args, f.EntityId)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To)
+	}
+
+	query := "SELECT id, actor, action, entity, entity_id, before_value, after_value, reason, created_at FROM audit_event"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	return query, args
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+// recordingTxn records the statements executed on it.
+type recordingTxn struct {
+	db.DBConnection
+	queries []string
+	args    [][]any
+}
+
+func (t *recordingTxn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	t.queries = append(t.queries, query)
+	t.args = append(t.args, args)
+	return driverResult(int64(len(t.queries))), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r driverResult) RowsAffected() (int64, error) { return 1, nil }
+
+func TestActorFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, SystemActor, ActorFromContext(context.Background()))
+	assert.Equal(t, SystemActor, ActorFromContext(WithActor(context.Background(), "")))
+	assert.Equal(t, "admin@example.com", ActorFromContext(WithActor(context.Background(), "admin@example.com")))
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	t.Run("outside a transaction", func(t *testing.T) {
+		err := Record(context.Background(), &Event{Action: "account.update", Entity: "account", EntityId: 1})
+		assert.ErrorContains(t, err, "txn not set")
+	})
+
+	t.Run("inside a transaction", func(t *testing.T) {
+		txn := &recordingTxn{}
+		createdAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+		ctx := context.WithValue(context.Background(), ctxkey.Txn{}, db.DBConnection(txn))
+		ctx = now.WithContext(WithActor(ctx, "admin@example.com"), createdAt)
+
+		require.NoError(t, Record(ctx, &Event{
+			Action:   "account.update",
+			Entity:   "account",
+			EntityId: 1,
+			Before:   map[string]string{"name": "old"},
+			After:    map[string]string{"name": "new"},
+			Reason:   "renamed",
+		}))
+		require.NoError(t, Record(ctx, &Event{Action: "invoice.create", Entity: "invoice", EntityId: 2}))
+
+		require.Len(t, txn.queries, 2)
+		for _, q := range txn.queries {
+			assert.Regexp(t, `^INSERT INTO \S+\.audit_event `, q)
+		}
+		assert.Equal(t, []any{
+			"admin@example.com", "account.update", "account", uint64(1),
+			[]byte(`{"name":"old"}`), []byte(`{"name":"new"}`), "renamed", createdAt,
+		}, txn.args[0])
+		// events without values store NULL
+		assert.Nil(t, txn.args[1][4])
+		assert.Nil(t, txn.args[1][5])
+	})
+
+	t.Run("unmarshalable value", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxkey.Txn{}, db.DBConnection(&recordingTxn{}))
+		err := Record(ctx, &Event{Action: "a", Entity: "e", After: make(chan int)})
+		assert.ErrorContains(t, err, "failed to marshal audit value")
+	})
+}
+
+func TestFilter_query(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	const columns = "SELECT id, actor, action, entity, entity_id, before_value, after_value, reason, created_at FROM audit_event"
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name:      "no filter",
+			filter:    Filter{},
+			wantQuery: columns + " ORDER BY id DESC",
+		},
+		{
+			name:      "every filter",
+			filter:    Filter{Actor: "system", Action: "invoice.create", Entity: "invoice", EntityId: 3, From: from, To: to, Limit: 10},
+			wantQuery: columns + " WHERE actor = ? AND action = ? AND entity = ? AND entity_id = ? AND created_at >= ? AND created_at < ? ORDER BY id DESC LIMIT ?",
+			wantArgs:  []any{"system", "invoice.create", "invoice", uint64(3), from, to, 10},
+		},
+		{
+			name:      "entity",
+			filter:    Filter{Entity: "account", EntityId: 1},
+			wantQuery: columns + " WHERE entity = ? AND entity_id = ? ORDER BY id DESC",
+			wantArgs:  []any{"account", uint64(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := tt.filter.query()
+			assert.Equal(t, tt.wantQuery, query)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+// TestAppendOnly guards the append-only guarantee: the only statement the application has for
+// audit_event is an INSERT, and the migration rejects UPDATE and DELETE in MySQL.
+func TestAppendOnly(t *testing.T) {
+	t.Parallel()
+
+	dto, err := os.ReadFile("../db/dto/auditevent.dbtpl.go")
+	require.NoError(t, err)
+	assert.NotRegexp(t, regexp.MustCompile(`(?i)\b(UPDATE|DELETE FROM)\s+\S*audit_event\b`), string(dto))
+
+	migration, err := os.ReadFile("../db/migration/000022_make_audit_event_append_only.up.sql")
+	require.NoError(t, err)
+	assert.Contains(t, string(migration), "BEFORE UPDATE ON `audit_event`")
+	assert.Contains(t, string(migration), "BEFORE DELETE ON `audit_event`")
+}
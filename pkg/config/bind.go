@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setting is a leaf field of Config, e.g. db.password.
+type setting struct {
+	path  []string
+	usage string
+	value reflect.Value
+}
+
+func (s *setting) key() string {
+	return strings.Join(s.path, ".")
+}
+
+func (s *setting) flag() string {
+	return strings.ReplaceAll(strings.Join(s.path, "-"), "_", "-")
+}
+
+func (s *setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(s.path, "_"))
+}
+
+func (s *setting) set(raw string) error {
+	var err error
+	switch {
+	case s.value.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(raw)
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Int:
+		var n int
+		n, err = strconv.Atoi(raw)
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(raw)
+		s.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config type %s", s.value.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", s.key(), err)
+	}
+	return nil
+}
+
+func settings(c *Config) []*setting {
+	var result []*setting
+	var walk func(v reflect.Value, path []string)
+	walk = func(v reflect.Value, path []string) {
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			p := append(append([]string{}, path...), field.Tag.Get("yaml"))
+			if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+				walk(v.Field(i), p)
+				continue
+			}
+			result = append(result, &setting{path: p, usage: field.Tag.Get("usage"), value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), nil)
+	return result
+}
+
+// BindFlags registers --config and a flag for every setting, defaulting to Default.
+func BindFlags(fs *pflag.FlagSet) {
+	fs.String(ConfigFlag, "", "path to a YAML config file (env "+ConfigEnv+")")
+	for _, s := range settings(Default()) {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env())
+		switch {
+		case s.value.Type() == durationType:
+			fs.Duration(s.flag(), time.Duration(s.value.Int()), usage)
+		case s.value.Kind() == reflect.String:
+			fs.String(s.flag(), s.value.String(), usage)
+		case s.value.Kind() == reflect.Int:
+			fs.Int(s.flag(), int(s.value.Int()), usage)
+		case s.value.Kind() == reflect.Bool:
+			fs.Bool(s.flag(), s.value.Bool(), usage)
+		}
+	}
+}
+
+// Load resolves the config from fs, registered by BindFlags, and lookupEnv,
+// usually os.LookupEnv, then validates it.
+func Load(fs *pflag.FlagSet, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := Default()
+
+	path, _ := lookupEnv(ConfigEnv)
+	if f := fs.Lookup(ConfigFlag); f != nil && f.Changed {
+		path = f.Value.String()
+	}
+	if path != "" {
+		if err := readFile(path, c); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings(c) {
+		if raw, ok := lookupEnv(s.env()); ok {
+			if err := s.set(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env(), err)
+			}
+		}
+		if f := fs.Lookup(s.flag()); f != nil && f.Changed {
+			if err := s.set(f.Value.String()); err != nil {
+				return nil, fmt.Errorf("--%s: %w", s.flag(), err)
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
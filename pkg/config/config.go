@@ -0,0 +1,314 @@
+// Package config loads the settings shared by every command.
+//
+// Each setting is resolved in the following order, later ones win:
+//
+//  1. the default in Default
+//  2. the YAML file given by --config or UBB_CONFIG
+//  3. the environment variable, e.g. UBB_DB_PASSWORD for db.password
+//  4. the command line flag, e.g. --db-password
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvPrefix  = "UBB_"
+	ConfigFlag = "config"
+	ConfigEnv  = EnvPrefix + "CONFIG"
+)
+
+type Config struct {
+	DB          DB          `yaml:"db"`
+	Queue       Queue       `yaml:"queue"`
+	RabbitMQ    RabbitMQ    `yaml:"rabbitmq"`
+	Kafka       Kafka       `yaml:"kafka"`
+	S3          S3          `yaml:"s3"`
+	ProviderApi ProviderApi `yaml:"provider_api"`
+	AdminApi    AdminApi    `yaml:"admin_api"`
+	Worker      Worker      `yaml:"worker"`
+	Invoice     Invoice     `yaml:"invoice"`
+	UserClient  UserClient  `yaml:"user_client"`
+	Metrics     Metrics     `yaml:"metrics"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Health      Health      `yaml:"health"`
+}
+
+type DB struct {
+	User         string `yaml:"user" usage:"MySQL user"`
+	Password     string `yaml:"password" usage:"MySQL password"`
+	Address      string `yaml:"address" usage:"MySQL host:port"`
+	Name         string `yaml:"name" usage:"MySQL database name"`
+	MaxOpenConns int    `yaml:"max_open_conns" usage:"maximum number of open MySQL connections"`
+}
+
+func (c *DB) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=true", c.User, c.Password, c.Address, c.Name)
+}
+
+// Queue selects the transport of access logs from the provider API to the worker.
+type Queue struct {
+	Transport  string `yaml:"transport" usage:"access log transport: rabbitmq, kafka or memory (devServer only)"`
+	MemorySize int    `yaml:"memory_size" usage:"capacity of the in-process queue of the memory transport"`
+}
+
+type RabbitMQ struct {
+	URL               string `yaml:"url" usage:"RabbitMQ url"`
+	PublisherPoolSize int    `yaml:"publisher_pool_size" usage:"number of RabbitMQ channels publishing concurrently"`
+}
+
+type Kafka struct {
+	Brokers string `yaml:"brokers" usage:"comma separated Kafka broker addresses"`
+}
+
+type S3 struct {
+	Region       string `yaml:"region" usage:"AWS region of the access log bucket"`
+	Endpoint     string `yaml:"endpoint" usage:"S3 endpoint, empty for AWS"`
+	Bucket       string `yaml:"bucket" usage:"access log bucket"`
+	UsePathStyle bool   `yaml:"use_path_style" usage:"use path style S3 addressing (MinIO)"`
+}
+
+type ProviderApi struct {
+	Addr                  string        `yaml:"addr" usage:"provider API listen address"`
+	Region                string        `yaml:"region" usage:"region recorded in the access logs of this server"`
+	ApiKeyCacheTTL        time.Duration `yaml:"api_key_cache_ttl" usage:"how long an api key is cached"`
+	BillingStatusCacheTTL time.Duration `yaml:"billing_status_cache_ttl" usage:"how long a billing status is cached"`
+	ThrottleLimit         int           `yaml:"throttle_limit" usage:"billed requests allowed per window for throttled accounts"`
+	ThrottleWindow        time.Duration `yaml:"throttle_window" usage:"throttle window for throttled accounts"`
+	Meters                string        `yaml:"meters" usage:"usage units of the billed handlers as name=unit[/per][*weight][@classes], comma separated; unit is calls, bytes, compute or custom, classes the billable status classes, e.g. 2xx+4xx"`
+	PublishQueueSize      int           `yaml:"publish_queue_size" usage:"access logs buffered in memory before the backpressure policy applies"`
+	PublishBatchSize      int           `yaml:"publish_batch_size" usage:"access logs published per batch"`
+	PublishFlushInterval  time.Duration `yaml:"publish_flush_interval" usage:"maximum time an access log waits for its batch"`
+	PublishBackpressure   string        `yaml:"publish_backpressure" usage:"what to do when the publish queue is full: block, drop or spool"`
+	SpoolDir              string        `yaml:"spool_dir" usage:"directory spooling access logs while RabbitMQ is unavailable"`
+	SpoolSegmentMB        int           `yaml:"spool_segment_mb" usage:"size of a spool segment file in MiB"`
+	SpoolMaxMB            int           `yaml:"spool_max_mb" usage:"disk budget of the spool in MiB, access logs beyond it are dropped"`
+	SpoolReplayInterval   time.Duration `yaml:"spool_replay_interval" usage:"how often spooled access logs are republished"`
+}
+
+type AdminApi struct {
+	Addr   string `yaml:"addr" usage:"admin API listen address"`
+	Tokens string `yaml:"tokens" usage:"admin API tokens as \"actor1:token1,actor2:token2\""`
+}
+
+type Worker struct {
+	FlushLogs     int           `yaml:"flush_logs" usage:"number of access logs buffered before flushing to S3"`
+	FlushInterval time.Duration `yaml:"flush_interval" usage:"maximum interval between access log flushes"`
+	// ShutdownTimeout bounds the final flush, unflushed messages stay unacked and are redelivered.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" usage:"time to flush and ack received logs on shutdown"`
+	LateUsagePolicy string        `yaml:"late_usage_policy" usage:"what to do with usage of a closed billing period: adjust bills it on the next invoice, reject drops it"`
+	// DedupRetention bounds processed_access_log, a log redelivered after it is counted again.
+	DedupRetention time.Duration `yaml:"dedup_retention" usage:"how long request ids of flushed access logs are kept to skip redelivered logs"`
+}
+
+type Invoice struct {
+	// CloseGrace is how long access logs of a period may still arrive before createDailyInvoice closes it.
+	CloseGrace time.Duration `yaml:"close_grace" usage:"time after the end of a billing period before it is invoiced and closed"`
+
+	// The issuer and the currency are printed on the PDF and HTML invoice documents.
+	IssuerName               string `yaml:"issuer_name" usage:"issuer name printed on invoice documents"`
+	IssuerAddress            string `yaml:"issuer_address" usage:"issuer address printed on invoice documents, may span several lines"`
+	IssuerEmail              string `yaml:"issuer_email" usage:"issuer contact email printed on invoice documents"`
+	IssuerRegistrationNumber string `yaml:"issuer_registration_number" usage:"tax registration number of the issuer printed on invoice documents"`
+	Currency                 string `yaml:"currency" usage:"ISO 4217 currency code of invoice amounts"`
+}
+
+type UserClient struct {
+	ApiURL string `yaml:"api_url" usage:"provider API url called by userClient"`
+}
+
+type Metrics struct {
+	Addr           string `yaml:"addr" usage:"listen address of /metrics for long-running commands"`
+	PushgatewayURL string `yaml:"pushgateway_url" usage:"Pushgateway url batch commands push to, empty to disable"`
+}
+
+type Tracing struct {
+	Enabled  bool   `yaml:"enabled" usage:"export traces with OTLP/HTTP"`
+	Endpoint string `yaml:"endpoint" usage:"OTLP/HTTP collector host:port"`
+	Insecure bool   `yaml:"insecure" usage:"send traces over plain HTTP"`
+}
+
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" usage:"timeout of readiness and liveness checks"`
+	DrainDelay   time.Duration `yaml:"drain_delay" usage:"how long readiness fails before the server shuts down"`
+}
+
+// Default returns the settings for the local compose environment.
+func Default() *Config {
+	return &Config{
+		DB: DB{
+			User:         "user",
+			Password:     "password",
+			Address:      "localhost:3306",
+			Name:         "usage_based_billing",
+			MaxOpenConns: 100,
+		},
+		Queue: Queue{
+			Transport:  "rabbitmq",
+			MemorySize: 10000,
+		},
+		RabbitMQ: RabbitMQ{
+			URL:               "amqp://localhost:5672",
+			PublisherPoolSize: 8,
+		},
+		Kafka: Kafka{
+			Brokers: "localhost:9092",
+		},
+		S3: S3{
+			Region:       "ap-northeast-1",
+			Endpoint:     "http://localhost:9000",
+			Bucket:       "api-access-log",
+			UsePathStyle: true,
+		},
+		ProviderApi: ProviderApi{
+			Addr:                  ":8080",
+			Region:                "ap-northeast-1",
+			ApiKeyCacheTTL:        30 * time.Minute,
+			BillingStatusCacheTTL: 30 * time.Second,
+			ThrottleLimit:         60,
+			ThrottleWindow:        time.Minute,
+			Meters:                "api1=calls,api2=calls",
+			PublishQueueSize:      10000,
+			PublishBatchSize:      100,
+			PublishFlushInterval:  100 * time.Millisecond,
+			PublishBackpressure:   "spool",
+			SpoolDir:              "data/spool",
+			SpoolSegmentMB:        16,
+			SpoolMaxMB:            1024,
+			SpoolReplayInterval:   10 * time.Second,
+		},
+		AdminApi: AdminApi{
+			Addr: ":8082",
+		},
+		Worker: Worker{
+			FlushLogs:       10000,
+			FlushInterval:   30 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+			LateUsagePolicy: "adjust",
+			DedupRetention:  7 * 24 * time.Hour,
+		},
+		Invoice: Invoice{
+			CloseGrace:  24 * time.Hour,
+			IssuerName:  "Usage Based Billing Sample",
+			IssuerEmail: "billing@example.com",
+			Currency:    "JPY",
+		},
+		UserClient: UserClient{
+			ApiURL: "http://localhost:8080/api/v1/one",
+		},
+		Metrics: Metrics{
+			Addr: ":9100",
+		},
+		Tracing: Tracing{
+			Enabled:  false,
+			Endpoint: "localhost:4318",
+			Insecure: true,
+		},
+		Health: Health{
+			CheckTimeout: 2 * time.Second,
+			DrainDelay:   5 * time.Second,
+		},
+	}
+}
+
+func readFile(path string, dst *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the settings of every section so that a misconfigured
+// deployment fails at startup instead of at first use.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, msg string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s %s", key, msg))
+		}
+	}
+
+	check(c.DB.User != "", "db.user", "is required")
+	check(c.DB.Address != "", "db.address", "is required")
+	check(c.DB.Name != "", "db.name", "is required")
+	check(c.DB.MaxOpenConns > 0, "db.max_open_conns", "must be positive")
+
+	check(slices.Contains([]string{"rabbitmq", "kafka", "memory"}, c.Queue.Transport), "queue.transport", "must be rabbitmq, kafka or memory")
+	check(c.Queue.MemorySize > 0, "queue.memory_size", "must be positive")
+	check(c.Queue.Transport != "kafka" || c.Kafka.Brokers != "", "kafka.brokers", "is required for the kafka transport")
+
+	check(isURL(c.RabbitMQ.URL, "amqp", "amqps"), "rabbitmq.url", "must be an amqp(s) url")
+	check(c.RabbitMQ.PublisherPoolSize > 0, "rabbitmq.publisher_pool_size", "must be positive")
+
+	check(c.S3.Region != "", "s3.region", "is required")
+	check(c.S3.Bucket != "", "s3.bucket", "is required")
+	check(c.S3.Endpoint == "" || isURL(c.S3.Endpoint, "http", "https"), "s3.endpoint", "must be an http(s) url")
+
+	check(c.ProviderApi.Addr != "", "provider_api.addr", "is required")
+	check(c.ProviderApi.ApiKeyCacheTTL > 0, "provider_api.api_key_cache_ttl", "must be positive")
+	check(c.ProviderApi.BillingStatusCacheTTL > 0, "provider_api.billing_status_cache_ttl", "must be positive")
+	check(c.ProviderApi.ThrottleLimit > 0, "provider_api.throttle_limit", "must be positive")
+	check(c.ProviderApi.ThrottleWindow >= time.Second, "provider_api.throttle_window", "must be at least 1s")
+	check(c.ProviderApi.PublishQueueSize > 0, "provider_api.publish_queue_size", "must be positive")
+	check(c.ProviderApi.PublishBatchSize > 0, "provider_api.publish_batch_size", "must be positive")
+	check(c.ProviderApi.PublishFlushInterval > 0, "provider_api.publish_flush_interval", "must be positive")
+	check(slices.Contains([]string{"block", "drop", "spool"}, c.ProviderApi.PublishBackpressure), "provider_api.publish_backpressure", "must be block, drop or spool")
+	check(c.ProviderApi.SpoolDir != "", "provider_api.spool_dir", "is required")
+	check(c.ProviderApi.SpoolSegmentMB > 0, "provider_api.spool_segment_mb", "must be positive")
+	check(c.ProviderApi.SpoolMaxMB >= c.ProviderApi.SpoolSegmentMB, "provider_api.spool_max_mb", "must be at least spool_segment_mb")
+	check(c.ProviderApi.SpoolReplayInterval > 0, "provider_api.spool_replay_interval", "must be positive")
+
+	check(c.AdminApi.Addr != "", "admin_api.addr", "is required")
+
+	// messages stay unacked until flushed, the AMQP prefetch count is 16 bits
+	check(c.Worker.FlushLogs > 0 && c.Worker.FlushLogs <= 65535, "worker.flush_logs", "must be 1-65535")
+	check(c.Worker.ShutdownTimeout > 0, "worker.shutdown_timeout", "must be positive")
+	check(c.Worker.FlushInterval > 0, "worker.flush_interval", "must be positive")
+	check(slices.Contains([]string{"adjust", "reject"}, c.Worker.LateUsagePolicy), "worker.late_usage_policy", "must be adjust or reject")
+	check(c.Worker.DedupRetention > 0, "worker.dedup_retention", "must be positive")
+
+	check(c.Invoice.CloseGrace >= 0, "invoice.close_grace", "must not be negative")
+	check(c.Invoice.IssuerName != "", "invoice.issuer_name", "is required")
+	check(len(c.Invoice.Currency) == 3 && strings.ToUpper(c.Invoice.Currency) == c.Invoice.Currency, "invoice.currency", "must be an ISO 4217 code e.g. JPY")
+
+	check(isURL(c.UserClient.ApiURL, "http", "https"), "user_client.api_url", "must be an http(s) url")
+
+	check(c.Metrics.Addr != "", "metrics.addr", "is required")
+	check(c.Metrics.PushgatewayURL == "" || isURL(c.Metrics.PushgatewayURL, "http", "https"), "metrics.pushgateway_url", "must be an http(s) url")
+
+	check(!c.Tracing.Enabled || c.Tracing.Endpoint != "", "tracing.endpoint", "is required when tracing is enabled")
+
+	check(c.Health.CheckTimeout > 0, "health.check_timeout", "must be positive")
+	check(c.Health.DrainDelay >= 0, "health.drain_delay", "must not be negative")
+
+	return errors.Join(errs...)
+}
+
+func isURL(s string, schemes ...string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+db:
+  address: mysql.staging:3306
+  password: from-file
+provider_api:
+  api_key_cache_ttl: 10m
+`), 0o600))
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	BindFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--config", path, "--db-password", "from-flag"}))
+
+	env := map[string]string{
+		"UBB_DB_PASSWORD":       "from-env",
+		"UBB_RABBITMQ_URL":      "amqps://mq.staging:5671",
+		"UBB_S3_ENDPOINT":       "",
+		"UBB_WORKER_FLUSH_LOGS": "1024",
+	}
+	c, err := Load(fs, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "mysql.staging:3306", c.DB.Address)
+	assert.Equal(t, "from-flag", c.DB.Password)
+	assert.Equal(t, "amqps://mq.staging:5671", c.RabbitMQ.URL)
+	assert.Equal(t, "", c.S3.Endpoint)
+	assert.Equal(t, 1024, c.Worker.FlushLogs)
+	assert.Equal(t, 10*time.Minute, c.ProviderApi.ApiKeyCacheTTL)
+	assert.Equal(t, Default().DB.User, c.DB.User)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		env map[string]string
+	}{
+		{env: map[string]string{"UBB_RABBITMQ_URL": "localhost:5672"}},
+		{env: map[string]string{"UBB_PROVIDER_API_THROTTLE_LIMIT": "0"}},
+		{env: map[string]string{"UBB_PROVIDER_API_API_KEY_CACHE_TTL": "soon"}},
+		{env: map[string]string{"UBB_CONFIG": "/nonexistent/config.yaml"}},
+	}
+
+	for _, tt := range tests {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		BindFlags(fs)
+		_, err := Load(fs, func(key string) (string, bool) {
+			v, ok := tt.env[key]
+			return v, ok
+		})
+		assert.Error(t, err, tt.env)
+	}
+}
+
+func TestLoad_Example(t *testing.T) {
+	t.Parallel()
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	BindFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--config", "../../config.example.yaml"}))
+
+	c, err := Load(fs, func(string) (string, bool) { return "", false })
+	require.NoError(t, err)
+	assert.Equal(t, Default(), c)
+}
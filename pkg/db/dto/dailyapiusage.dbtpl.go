@@ -10,6 +10,7 @@ import (
 // DailyAPIUsage represents a row from 'usage_based_billing.daily_api_usage'.
 type DailyAPIUsage struct {
 	AccountID uint64    `json:"account_id"` // account_id
+	Meter     string    `json:"meter"`      // meter
 	Date      string    `json:"date"`       // date
 	Usage     uint64    `json:"usage"`      // usage
 	CreatedAt time.Time `json:"created_at"` // created_at
@@ -39,13 +40,13 @@ func (dau *DailyAPIUsage) Insert(ctx context.Context, db DB) error {
 	}
 	// insert (manual)
 	const sqlstr = `INSERT INTO usage_based_billing.daily_api_usage (` +
-		`account_id, date, usage, created_at, updated_at` +
+		`account_id, meter, date, usage, created_at, updated_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?` +
 		`)`
 	// run
-	logf(sqlstr, dau.AccountID, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, dau.AccountID, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt); err != nil {
+	logf(sqlstr, dau.AccountID, dau.Meter, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, dau.AccountID, dau.Meter, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -64,10 +65,10 @@ func (dau *DailyAPIUsage) Update(ctx context.Context, db DB) error {
 	// update with primary key
 	const sqlstr = `UPDATE usage_based_billing.daily_api_usage SET ` +
 		`usage = ?, created_at = ?, updated_at = ? ` +
-		`WHERE account_id = ? AND date = ?`
+		`WHERE account_id = ? AND date = ? AND meter = ?`
 	// run
-	logf(sqlstr, dau.Usage, dau.CreatedAt, dau.UpdatedAt, dau.AccountID, dau.Date)
-	if _, err := db.ExecContext(ctx, sqlstr, dau.Usage, dau.CreatedAt, dau.UpdatedAt, dau.AccountID, dau.Date); err != nil {
+	logf(sqlstr, dau.Usage, dau.CreatedAt, dau.UpdatedAt, dau.AccountID, dau.Date, dau.Meter)
+	if _, err := db.ExecContext(ctx, sqlstr, dau.Usage, dau.CreatedAt, dau.UpdatedAt, dau.AccountID, dau.Date, dau.Meter); err != nil {
 		return logerror(err)
 	}
 	return nil
@@ -89,15 +90,15 @@ func (dau *DailyAPIUsage) Upsert(ctx context.Context, db DB) error {
 	}
 	// upsert
 	const sqlstr = `INSERT INTO usage_based_billing.daily_api_usage (` +
-		`account_id, date, usage, created_at, updated_at` +
+		`account_id, meter, date, usage, created_at, updated_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?` +
 		`)` +
 		` ON DUPLICATE KEY UPDATE ` +
-		`account_id = VALUES(account_id), date = VALUES(date), usage = VALUES(usage), created_at = VALUES(created_at), updated_at = VALUES(updated_at)`
+		`account_id = VALUES(account_id), meter = VALUES(meter), date = VALUES(date), usage = VALUES(usage), created_at = VALUES(created_at), updated_at = VALUES(updated_at)`
 	// run
-	logf(sqlstr, dau.AccountID, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, dau.AccountID, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt); err != nil {
+	logf(sqlstr, dau.AccountID, dau.Meter, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, dau.AccountID, dau.Meter, dau.Date, dau.Usage, dau.CreatedAt, dau.UpdatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -115,10 +116,10 @@ func (dau *DailyAPIUsage) Delete(ctx context.Context, db DB) error {
 	}
 	// delete with composite primary key
 	const sqlstr = `DELETE FROM usage_based_billing.daily_api_usage ` +
-		`WHERE account_id = ? AND date = ?`
+		`WHERE account_id = ? AND date = ? AND meter = ?`
 	// run
-	logf(sqlstr, dau.AccountID, dau.Date)
-	if _, err := db.ExecContext(ctx, sqlstr, dau.AccountID, dau.Date); err != nil {
+	logf(sqlstr, dau.AccountID, dau.Date, dau.Meter)
+	if _, err := db.ExecContext(ctx, sqlstr, dau.AccountID, dau.Date, dau.Meter); err != nil {
 		return logerror(err)
 	}
 	// set deleted
@@ -126,21 +127,21 @@ func (dau *DailyAPIUsage) Delete(ctx context.Context, db DB) error {
 	return nil
 }
 
-// DailyAPIUsageByAccountIDDate retrieves a row from 'usage_based_billing.daily_api_usage' as a [DailyAPIUsage].
+// DailyAPIUsageByAccountIDDateMeter retrieves a row from 'usage_based_billing.daily_api_usage' as a [DailyAPIUsage].
 //
-// Generated from index 'daily_api_usage_account_id_date_pkey'.
-func DailyAPIUsageByAccountIDDate(ctx context.Context, db DB, accountID uint64, date string) (*DailyAPIUsage, error) {
+// Generated from index 'daily_api_usage_account_id_date_meter_pkey'.
+func DailyAPIUsageByAccountIDDateMeter(ctx context.Context, db DB, accountID uint64, date, meter string) (*DailyAPIUsage, error) {
 	// query
 	const sqlstr = `SELECT ` +
-		`account_id, date, usage, created_at, updated_at ` +
+		`account_id, meter, date, usage, created_at, updated_at ` +
 		`FROM usage_based_billing.daily_api_usage ` +
-		`WHERE account_id = ? AND date = ?`
+		`WHERE account_id = ? AND date = ? AND meter = ?`
 	// run
-	logf(sqlstr, accountID, date)
+	logf(sqlstr, accountID, date, meter)
 	dau := DailyAPIUsage{
 		_exists: true,
 	}
-	if err := db.QueryRowContext(ctx, sqlstr, accountID, date).Scan(&dau.AccountID, &dau.Date, &dau.Usage, &dau.CreatedAt, &dau.UpdatedAt); err != nil {
+	if err := db.QueryRowContext(ctx, sqlstr, accountID, date, meter).Scan(&dau.AccountID, &dau.Meter, &dau.Date, &dau.Usage, &dau.CreatedAt, &dau.UpdatedAt); err != nil {
 		return nil, logerror(err)
 	}
 	return &dau, nil
@@ -9,11 +9,14 @@ import (
 
 // EveryMinuteAPIUsage represents a row from 'usage_based_billing.every_minute_api_usage'.
 type EveryMinuteAPIUsage struct {
-	AccountID uint64    `json:"account_id"` // account_id
-	Minute    string    `json:"minute"`     // minute
-	Usage     uint64    `json:"usage"`      // usage
-	CreatedAt time.Time `json:"created_at"` // created_at
-	UpdatedAt time.Time `json:"updated_at"` // updated_at
+	AccountID           uint64    `json:"account_id"`            // account_id
+	Meter               string    `json:"meter"`                 // meter
+	Minute              string    `json:"minute"`                // minute
+	Usage               uint64    `json:"usage"`                 // usage
+	BillableRequests    uint64    `json:"billable_requests"`     // billable_requests
+	NonBillableRequests uint64    `json:"non_billable_requests"` // non_billable_requests
+	CreatedAt           time.Time `json:"created_at"`            // created_at
+	UpdatedAt           time.Time `json:"updated_at"`            // updated_at
 	// xo fields
 	_exists, _deleted bool
 }
@@ -39,13 +42,13 @@ func (emau *EveryMinuteAPIUsage) Insert(ctx context.Context, db DB) error {
 	}
 	// insert (manual)
 	const sqlstr = `INSERT INTO usage_based_billing.every_minute_api_usage (` +
-		`account_id, minute, usage, created_at, updated_at` +
+		`account_id, meter, minute, usage, billable_requests, non_billable_requests, created_at, updated_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?, ?, ?` +
 		`)`
 	// run
-	logf(sqlstr, emau.AccountID, emau.Minute, emau.Usage, emau.CreatedAt, emau.UpdatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, emau.AccountID, emau.Minute, emau.Usage, emau.CreatedAt, emau.UpdatedAt); err != nil {
+	logf(sqlstr, emau.AccountID, emau.Meter, emau.Minute, emau.Usage, emau.BillableRequests, emau.NonBillableRequests, emau.CreatedAt, emau.UpdatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, emau.AccountID, emau.Meter, emau.Minute, emau.Usage, emau.BillableRequests, emau.NonBillableRequests, emau.CreatedAt, emau.UpdatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -63,11 +66,11 @@ func (emau *EveryMinuteAPIUsage) Update(ctx context.Context, db DB) error {
 	}
 	// update with primary key
 	const sqlstr = `UPDATE usage_based_billing.every_minute_api_usage SET ` +
-		`usage = ?, created_at = ?, updated_at = ? ` +
-		`WHERE account_id = ? AND minute = ?`
+		`usage = ?, billable_requests = ?, non_billable_requests = ?, created_at = ?, updated_at = ? ` +
+		`WHERE account_id = ? AND minute = ? AND meter = ?`
 	// run
-	logf(sqlstr, emau.Usage, emau.CreatedAt, emau.UpdatedAt, emau.AccountID, emau.Minute)
-	if _, err := db.ExecContext(ctx, sqlstr, emau.Usage, emau.CreatedAt, emau.UpdatedAt, emau.AccountID, emau.Minute); err != nil {
+	logf(sqlstr, emau.Usage, emau.BillableRequests, emau.NonBillableRequests, emau.CreatedAt, emau.UpdatedAt, emau.AccountID, emau.Minute, emau.Meter)
+	if _, err := db.ExecContext(ctx, sqlstr, emau.Usage, emau.BillableRequests, emau.NonBillableRequests, emau.CreatedAt, emau.UpdatedAt, emau.AccountID, emau.Minute, emau.Meter); err != nil {
 		return logerror(err)
 	}
 	return nil
@@ -89,15 +92,15 @@ func (emau *EveryMinuteAPIUsage) Upsert(ctx context.Context, db DB) error {
 	}
 	// upsert
 	const sqlstr = `INSERT INTO usage_based_billing.every_minute_api_usage (` +
-		`account_id, minute, usage, created_at, updated_at` +
+		`account_id, meter, minute, usage, billable_requests, non_billable_requests, created_at, updated_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?, ?, ?` +
 		`)` +
 		` ON DUPLICATE KEY UPDATE ` +
-		`account_id = VALUES(account_id), minute = VALUES(minute), usage = VALUES(usage), created_at = VALUES(created_at), updated_at = VALUES(updated_at)`
+		`account_id = VALUES(account_id), meter = VALUES(meter), minute = VALUES(minute), usage = VALUES(usage), billable_requests = VALUES(billable_requests), non_billable_requests = VALUES(non_billable_requests), created_at = VALUES(created_at), updated_at = VALUES(updated_at)`
 	// run
-	logf(sqlstr, emau.AccountID, emau.Minute, emau.Usage, emau.CreatedAt, emau.UpdatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, emau.AccountID, emau.Minute, emau.Usage, emau.CreatedAt, emau.UpdatedAt); err != nil {
+	logf(sqlstr, emau.AccountID, emau.Meter, emau.Minute, emau.Usage, emau.BillableRequests, emau.NonBillableRequests, emau.CreatedAt, emau.UpdatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, emau.AccountID, emau.Meter, emau.Minute, emau.Usage, emau.BillableRequests, emau.NonBillableRequests, emau.CreatedAt, emau.UpdatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -115,10 +118,10 @@ func (emau *EveryMinuteAPIUsage) Delete(ctx context.Context, db DB) error {
 	}
 	// delete with composite primary key
 	const sqlstr = `DELETE FROM usage_based_billing.every_minute_api_usage ` +
-		`WHERE account_id = ? AND minute = ?`
+		`WHERE account_id = ? AND minute = ? AND meter = ?`
 	// run
-	logf(sqlstr, emau.AccountID, emau.Minute)
-	if _, err := db.ExecContext(ctx, sqlstr, emau.AccountID, emau.Minute); err != nil {
+	logf(sqlstr, emau.AccountID, emau.Minute, emau.Meter)
+	if _, err := db.ExecContext(ctx, sqlstr, emau.AccountID, emau.Minute, emau.Meter); err != nil {
 		return logerror(err)
 	}
 	// set deleted
@@ -126,21 +129,21 @@ func (emau *EveryMinuteAPIUsage) Delete(ctx context.Context, db DB) error {
 	return nil
 }
 
-// EveryMinuteAPIUsageByAccountIDMinute retrieves a row from 'usage_based_billing.every_minute_api_usage' as a [EveryMinuteAPIUsage].
+// EveryMinuteAPIUsageByAccountIDMinuteMeter retrieves a row from 'usage_based_billing.every_minute_api_usage' as a [EveryMinuteAPIUsage].
 //
-// Generated from index 'every_minute_api_usage_account_id_minute_pkey'.
-func EveryMinuteAPIUsageByAccountIDMinute(ctx context.Context, db DB, accountID uint64, minute string) (*EveryMinuteAPIUsage, error) {
+// Generated from index 'every_minute_api_usage_account_id_minute_meter_pkey'.
+func EveryMinuteAPIUsageByAccountIDMinuteMeter(ctx context.Context, db DB, accountID uint64, minute, meter string) (*EveryMinuteAPIUsage, error) {
 	// query
 	const sqlstr = `SELECT ` +
-		`account_id, minute, usage, created_at, updated_at ` +
+		`account_id, meter, minute, usage, billable_requests, non_billable_requests, created_at, updated_at ` +
 		`FROM usage_based_billing.every_minute_api_usage ` +
-		`WHERE account_id = ? AND minute = ?`
+		`WHERE account_id = ? AND minute = ? AND meter = ?`
 	// run
-	logf(sqlstr, accountID, minute)
+	logf(sqlstr, accountID, minute, meter)
 	emau := EveryMinuteAPIUsage{
 		_exists: true,
 	}
-	if err := db.QueryRowContext(ctx, sqlstr, accountID, minute).Scan(&emau.AccountID, &emau.Minute, &emau.Usage, &emau.CreatedAt, &emau.UpdatedAt); err != nil {
+	if err := db.QueryRowContext(ctx, sqlstr, accountID, minute, meter).Scan(&emau.AccountID, &emau.Meter, &emau.Minute, &emau.Usage, &emau.BillableRequests, &emau.NonBillableRequests, &emau.CreatedAt, &emau.UpdatedAt); err != nil {
 		return nil, logerror(err)
 	}
 	return &emau, nil
@@ -10,6 +10,7 @@ import (
 // HouryAPIUsage represents a row from 'usage_based_billing.houry_api_usage'.
 type HouryAPIUsage struct {
 	AccountID uint64    `json:"account_id"` // account_id
+	Meter     string    `json:"meter"`      // meter
 	Hour      string    `json:"hour"`       // hour
 	Usage     uint64    `json:"usage"`      // usage
 	CreatedAt time.Time `json:"created_at"` // created_at
@@ -39,13 +40,13 @@ func (hau *HouryAPIUsage) Insert(ctx context.Context, db DB) error {
 	}
 	// insert (manual)
 	const sqlstr = `INSERT INTO usage_based_billing.houry_api_usage (` +
-		`account_id, hour, usage, created_at, updated_at` +
+		`account_id, meter, hour, usage, created_at, updated_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?` +
 		`)`
 	// run
-	logf(sqlstr, hau.AccountID, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, hau.AccountID, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt); err != nil {
+	logf(sqlstr, hau.AccountID, hau.Meter, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, hau.AccountID, hau.Meter, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -64,10 +65,10 @@ func (hau *HouryAPIUsage) Update(ctx context.Context, db DB) error {
 	// update with primary key
 	const sqlstr = `UPDATE usage_based_billing.houry_api_usage SET ` +
 		`usage = ?, created_at = ?, updated_at = ? ` +
-		`WHERE account_id = ? AND hour = ?`
+		`WHERE account_id = ? AND hour = ? AND meter = ?`
 	// run
-	logf(sqlstr, hau.Usage, hau.CreatedAt, hau.UpdatedAt, hau.AccountID, hau.Hour)
-	if _, err := db.ExecContext(ctx, sqlstr, hau.Usage, hau.CreatedAt, hau.UpdatedAt, hau.AccountID, hau.Hour); err != nil {
+	logf(sqlstr, hau.Usage, hau.CreatedAt, hau.UpdatedAt, hau.AccountID, hau.Hour, hau.Meter)
+	if _, err := db.ExecContext(ctx, sqlstr, hau.Usage, hau.CreatedAt, hau.UpdatedAt, hau.AccountID, hau.Hour, hau.Meter); err != nil {
 		return logerror(err)
 	}
 	return nil
@@ -89,15 +90,15 @@ func (hau *HouryAPIUsage) Upsert(ctx context.Context, db DB) error {
 	}
 	// upsert
 	const sqlstr = `INSERT INTO usage_based_billing.houry_api_usage (` +
-		`account_id, hour, usage, created_at, updated_at` +
+		`account_id, meter, hour, usage, created_at, updated_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?` +
 		`)` +
 		` ON DUPLICATE KEY UPDATE ` +
-		`account_id = VALUES(account_id), hour = VALUES(hour), usage = VALUES(usage), created_at = VALUES(created_at), updated_at = VALUES(updated_at)`
+		`account_id = VALUES(account_id), meter = VALUES(meter), hour = VALUES(hour), usage = VALUES(usage), created_at = VALUES(created_at), updated_at = VALUES(updated_at)`
 	// run
-	logf(sqlstr, hau.AccountID, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, hau.AccountID, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt); err != nil {
+	logf(sqlstr, hau.AccountID, hau.Meter, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, hau.AccountID, hau.Meter, hau.Hour, hau.Usage, hau.CreatedAt, hau.UpdatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -115,10 +116,10 @@ func (hau *HouryAPIUsage) Delete(ctx context.Context, db DB) error {
 	}
 	// delete with composite primary key
 	const sqlstr = `DELETE FROM usage_based_billing.houry_api_usage ` +
-		`WHERE account_id = ? AND hour = ?`
+		`WHERE account_id = ? AND hour = ? AND meter = ?`
 	// run
-	logf(sqlstr, hau.AccountID, hau.Hour)
-	if _, err := db.ExecContext(ctx, sqlstr, hau.AccountID, hau.Hour); err != nil {
+	logf(sqlstr, hau.AccountID, hau.Hour, hau.Meter)
+	if _, err := db.ExecContext(ctx, sqlstr, hau.AccountID, hau.Hour, hau.Meter); err != nil {
 		return logerror(err)
 	}
 	// set deleted
@@ -126,21 +127,21 @@ func (hau *HouryAPIUsage) Delete(ctx context.Context, db DB) error {
 	return nil
 }
 
-// HouryAPIUsageByAccountIDHour retrieves a row from 'usage_based_billing.houry_api_usage' as a [HouryAPIUsage].
+// HouryAPIUsageByAccountIDHourMeter retrieves a row from 'usage_based_billing.houry_api_usage' as a [HouryAPIUsage].
 //
-// Generated from index 'houry_api_usage_account_id_hour_pkey'.
-func HouryAPIUsageByAccountIDHour(ctx context.Context, db DB, accountID uint64, hour string) (*HouryAPIUsage, error) {
+// Generated from index 'houry_api_usage_account_id_hour_meter_pkey'.
+func HouryAPIUsageByAccountIDHourMeter(ctx context.Context, db DB, accountID uint64, hour, meter string) (*HouryAPIUsage, error) {
 	// query
 	const sqlstr = `SELECT ` +
-		`account_id, hour, usage, created_at, updated_at ` +
+		`account_id, meter, hour, usage, created_at, updated_at ` +
 		`FROM usage_based_billing.houry_api_usage ` +
-		`WHERE account_id = ? AND hour = ?`
+		`WHERE account_id = ? AND hour = ? AND meter = ?`
 	// run
-	logf(sqlstr, accountID, hour)
+	logf(sqlstr, accountID, hour, meter)
 	hau := HouryAPIUsage{
 		_exists: true,
 	}
-	if err := db.QueryRowContext(ctx, sqlstr, accountID, hour).Scan(&hau.AccountID, &hau.Hour, &hau.Usage, &hau.CreatedAt, &hau.UpdatedAt); err != nil {
+	if err := db.QueryRowContext(ctx, sqlstr, accountID, hour, meter).Scan(&hau.AccountID, &hau.Meter, &hau.Hour, &hau.Usage, &hau.CreatedAt, &hau.UpdatedAt); err != nil {
 		return nil, logerror(err)
 	}
 	return &hau, nil
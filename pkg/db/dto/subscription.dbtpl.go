@@ -4,16 +4,18 @@ package dto
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
 // Subscription represents a row from 'usage_based_billing.subscription'.
 type Subscription struct {
-	ID          uint64    `json:"id"`           // id
-	AccountID   uint64    `json:"account_id"`   // account_id
-	From        time.Time `json:"from"`         // from
-	EstimatedTo time.Time `json:"estimated_to"` // estimated_to
-	CreatedAt   time.Time `json:"created_at"`   // created_at
+	ID          uint64       `json:"id"`           // id
+	AccountID   uint64       `json:"account_id"`   // account_id
+	From        time.Time    `json:"from"`         // from
+	EstimatedTo time.Time    `json:"estimated_to"` // estimated_to
+	ClosedAt    sql.NullTime `json:"closed_at"`    // closed_at
+	CreatedAt   time.Time    `json:"created_at"`   // created_at
 	// xo fields
 	_exists, _deleted bool
 }
@@ -39,13 +41,13 @@ func (s *Subscription) Insert(ctx context.Context, db DB) error {
 	}
 	// insert (primary key generated and returned by database)
 	const sqlstr = `INSERT INTO usage_based_billing.subscription (` +
-		`account_id, from, estimated_to, created_at` +
+		`account_id, from, estimated_to, closed_at, created_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?` +
+		`?, ?, ?, ?, ?` +
 		`)`
 	// run
-	logf(sqlstr, s.AccountID, s.From, s.EstimatedTo, s.CreatedAt)
-	res, err := db.ExecContext(ctx, sqlstr, s.AccountID, s.From, s.EstimatedTo, s.CreatedAt)
+	logf(sqlstr, s.AccountID, s.From, s.EstimatedTo, s.ClosedAt, s.CreatedAt)
+	res, err := db.ExecContext(ctx, sqlstr, s.AccountID, s.From, s.EstimatedTo, s.ClosedAt, s.CreatedAt)
 	if err != nil {
 		return logerror(err)
 	}
@@ -70,11 +72,11 @@ func (s *Subscription) Update(ctx context.Context, db DB) error {
 	}
 	// update with primary key
 	const sqlstr = `UPDATE usage_based_billing.subscription SET ` +
-		`account_id = ?, from = ?, estimated_to = ?, created_at = ? ` +
+		`account_id = ?, from = ?, estimated_to = ?, closed_at = ?, created_at = ? ` +
 		`WHERE id = ?`
 	// run
-	logf(sqlstr, s.AccountID, s.From, s.EstimatedTo, s.CreatedAt, s.ID)
-	if _, err := db.ExecContext(ctx, sqlstr, s.AccountID, s.From, s.EstimatedTo, s.CreatedAt, s.ID); err != nil {
+	logf(sqlstr, s.AccountID, s.From, s.EstimatedTo, s.ClosedAt, s.CreatedAt, s.ID)
+	if _, err := db.ExecContext(ctx, sqlstr, s.AccountID, s.From, s.EstimatedTo, s.ClosedAt, s.CreatedAt, s.ID); err != nil {
 		return logerror(err)
 	}
 	return nil
@@ -96,15 +98,15 @@ func (s *Subscription) Upsert(ctx context.Context, db DB) error {
 	}
 	// upsert
 	const sqlstr = `INSERT INTO usage_based_billing.subscription (` +
-		`id, account_id, from, estimated_to, created_at` +
+		`id, account_id, from, estimated_to, closed_at, created_at` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?` +
 		`)` +
 		` ON DUPLICATE KEY UPDATE ` +
-		`account_id = VALUES(account_id), from = VALUES(from), estimated_to = VALUES(estimated_to), created_at = VALUES(created_at)`
+		`account_id = VALUES(account_id), from = VALUES(from), estimated_to = VALUES(estimated_to), closed_at = VALUES(closed_at), created_at = VALUES(created_at)`
 	// run
-	logf(sqlstr, s.ID, s.AccountID, s.From, s.EstimatedTo, s.CreatedAt)
-	if _, err := db.ExecContext(ctx, sqlstr, s.ID, s.AccountID, s.From, s.EstimatedTo, s.CreatedAt); err != nil {
+	logf(sqlstr, s.ID, s.AccountID, s.From, s.EstimatedTo, s.ClosedAt, s.CreatedAt)
+	if _, err := db.ExecContext(ctx, sqlstr, s.ID, s.AccountID, s.From, s.EstimatedTo, s.ClosedAt, s.CreatedAt); err != nil {
 		return logerror(err)
 	}
 	// set exists
@@ -139,7 +141,7 @@ func (s *Subscription) Delete(ctx context.Context, db DB) error {
 func SubscriptionByAccountIDFrom(ctx context.Context, db DB, accountID uint64, from time.Time) (*Subscription, error) {
 	// query
 	const sqlstr = `SELECT ` +
-		`id, account_id, from, estimated_to, created_at ` +
+		`id, account_id, from, estimated_to, closed_at, created_at ` +
 		`FROM usage_based_billing.subscription ` +
 		`WHERE account_id = ? AND from = ?`
 	// run
@@ -147,7 +149,7 @@ func SubscriptionByAccountIDFrom(ctx context.Context, db DB, accountID uint64, f
 	s := Subscription{
 		_exists: true,
 	}
-	if err := db.QueryRowContext(ctx, sqlstr, accountID, from).Scan(&s.ID, &s.AccountID, &s.From, &s.EstimatedTo, &s.CreatedAt); err != nil {
+	if err := db.QueryRowContext(ctx, sqlstr, accountID, from).Scan(&s.ID, &s.AccountID, &s.From, &s.EstimatedTo, &s.ClosedAt, &s.CreatedAt); err != nil {
 		return nil, logerror(err)
 	}
 	return &s, nil
@@ -159,7 +161,7 @@ func SubscriptionByAccountIDFrom(ctx context.Context, db DB, accountID uint64, f
 func SubscriptionByID(ctx context.Context, db DB, id uint64) (*Subscription, error) {
 	// query
 	const sqlstr = `SELECT ` +
-		`id, account_id, from, estimated_to, created_at ` +
+		`id, account_id, from, estimated_to, closed_at, created_at ` +
 		`FROM usage_based_billing.subscription ` +
 		`WHERE id = ?`
 	// run
@@ -167,7 +169,7 @@ func SubscriptionByID(ctx context.Context, db DB, id uint64) (*Subscription, err
 	s := Subscription{
 		_exists: true,
 	}
-	if err := db.QueryRowContext(ctx, sqlstr, id).Scan(&s.ID, &s.AccountID, &s.From, &s.EstimatedTo, &s.CreatedAt); err != nil {
+	if err := db.QueryRowContext(ctx, sqlstr, id).Scan(&s.ID, &s.AccountID, &s.From, &s.EstimatedTo, &s.ClosedAt, &s.CreatedAt); err != nil {
 		return nil, logerror(err)
 	}
 	return &s, nil
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `audit_event` (
+    `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+    `actor` VARCHAR(255) NOT NULL,
+    `action` VARCHAR(64) NOT NULL,
+    `entity` VARCHAR(64) NOT NULL,
+    `entity_id` bigint UNSIGNED NOT NULL DEFAULT 0,
+    `before_value` JSON,
+    `after_value` JSON,
+    `reason` VARCHAR(1024) NOT NULL DEFAULT '',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`id`),
+    INDEX (`entity`, `entity_id`),
+    INDEX (`created_at`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `usage_alert` (
+    `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+    `account_id` bigint UNSIGNED NOT NULL,
+    `kind` VARCHAR(16) NOT NULL, -- quota | budget
+    `limit_value` DECIMAL(20, 5) NOT NULL DEFAULT 0,
+    `thresholds` VARCHAR(255) NOT NULL, -- 50,80,100
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP() ON UPDATE CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`account_id`) REFERENCES `account`(`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
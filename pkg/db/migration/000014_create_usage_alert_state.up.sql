@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `usage_alert_state` (
+    `alert_id` bigint UNSIGNED NOT NULL,
+    `subscription_id` bigint UNSIGNED NOT NULL,
+    `threshold` smallint UNSIGNED NOT NULL,
+    `fired_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`alert_id`, `subscription_id`, `threshold`),
+    FOREIGN KEY (`alert_id`) REFERENCES `usage_alert`(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`subscription_id`) REFERENCES `subscription`(`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `account_billing_status` (
+    `account_id` bigint UNSIGNED NOT NULL,
+    `status` VARCHAR(16) NOT NULL DEFAULT 'active', -- active | throttled | suspended
+    `projected_total` DECIMAL(20, 5) NOT NULL DEFAULT 0,
+    `evaluated_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP() ON UPDATE CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`account_id`),
+    FOREIGN KEY (`account_id`) REFERENCES `account`(`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `access_log_object` (
+    `object_key` VARCHAR(255) NOT NULL,
+    `batch_id` CHAR(36) NOT NULL, -- uuidv7 of the worker flush
+    `record_count` int UNSIGNED NOT NULL,
+    `min_timestamp` DATETIME(3) NOT NULL,
+    `max_timestamp` DATETIME(3) NOT NULL,
+    `account_ids` JSON NOT NULL, -- sorted array of the account ids in the object
+    `size_bytes` bigint UNSIGNED NOT NULL,
+    `checksum` CHAR(64) NOT NULL, -- hex sha256 of the object
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`object_key`),
+    INDEX (`batch_id`),
+    INDEX (`min_timestamp`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
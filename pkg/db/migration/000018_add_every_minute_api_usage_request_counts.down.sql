@@ -0,0 +1,3 @@
+ALTER TABLE `every_minute_api_usage`
+    DROP COLUMN `non_billable_requests`,
+    DROP COLUMN `billable_requests`;
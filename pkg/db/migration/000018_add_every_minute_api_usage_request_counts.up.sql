@@ -0,0 +1,5 @@
+ALTER TABLE `every_minute_api_usage`
+    ADD COLUMN `billable_requests` bigint UNSIGNED NOT NULL DEFAULT 0 AFTER `usage`,
+    ADD COLUMN `non_billable_requests` bigint UNSIGNED NOT NULL DEFAULT 0 AFTER `billable_requests`;
+-- usage counted one per 2xx request until meters were introduced
+UPDATE `every_minute_api_usage` SET `billable_requests` = `usage`;
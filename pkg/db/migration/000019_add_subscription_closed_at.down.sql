@@ -0,0 +1,2 @@
+ALTER TABLE `subscription`
+    DROP COLUMN `closed_at`;
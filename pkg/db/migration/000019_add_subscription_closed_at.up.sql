@@ -0,0 +1,6 @@
+ALTER TABLE `subscription`
+    ADD COLUMN `closed_at` DATETIME(3) NULL AFTER `estimated_to`; -- set when the period is invoiced
+-- periods invoiced before closed_at was introduced
+UPDATE `subscription` s
+    JOIN (SELECT `subscription_id`, MIN(`created_at`) AS `created_at` FROM `invoice` GROUP BY `subscription_id`) i ON i.`subscription_id` = s.`id`
+    SET s.`closed_at` = i.`created_at`;
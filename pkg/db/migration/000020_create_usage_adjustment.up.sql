@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `usage_adjustment` (
+    `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+    `account_id` bigint UNSIGNED NOT NULL,
+    `subscription_id` bigint UNSIGNED NOT NULL, -- closed period the late usage belongs to
+    `usage` bigint UNSIGNED NOT NULL DEFAULT 0,
+    `requests` bigint UNSIGNED NOT NULL DEFAULT 0,
+    `batch_id` CHAR(36) NOT NULL, -- uuidv7 of the worker flush
+    `invoice_id` bigint UNSIGNED, -- invoice the usage was billed on, NULL while pending
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`id`),
+    INDEX (`account_id`, `invoice_id`),
+    FOREIGN KEY (`account_id`) REFERENCES `account`(`id`),
+    FOREIGN KEY (`subscription_id`) REFERENCES `subscription`(`id`),
+    FOREIGN KEY (`invoice_id`) REFERENCES `invoice`(`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
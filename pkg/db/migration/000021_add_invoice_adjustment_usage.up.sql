@@ -0,0 +1,2 @@
+ALTER TABLE `invoice`
+    ADD COLUMN `adjustment_usage` int UNSIGNED NOT NULL DEFAULT 0 AFTER `total_usage`; -- late usage of earlier periods included in total_usage
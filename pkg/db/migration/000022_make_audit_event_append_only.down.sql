@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS `audit_event_no_delete`;
+DROP TRIGGER IF EXISTS `audit_event_no_update`;
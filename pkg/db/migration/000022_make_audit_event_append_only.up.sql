@@ -0,0 +1,8 @@
+-- audit_event is append-only: the application has no UPDATE or DELETE path, and these triggers
+-- reject them for every user. Operators may additionally restrict the application user with
+-- GRANT SELECT, INSERT ON usage_based_billing.audit_event (i.e. no UPDATE, DELETE).
+-- Creating triggers with binary logging enabled requires SUPER or log_bin_trust_function_creators.
+CREATE TRIGGER `audit_event_no_update` BEFORE UPDATE ON `audit_event`
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_event is append-only';
+CREATE TRIGGER `audit_event_no_delete` BEFORE DELETE ON `audit_event`
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_event is append-only';
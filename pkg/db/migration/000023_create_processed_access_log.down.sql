@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `processed_access_log`;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `processed_access_log` (
+    `log_key` BINARY(32) NOT NULL, -- sha256 of the account id, request id and timestamp of the log
+    `account_id` bigint UNSIGNED NOT NULL,
+    `batch_id` CHAR(36) NOT NULL, -- uuidv7 of the worker flush that counted the log
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`log_key`),
+    INDEX (`created_at`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
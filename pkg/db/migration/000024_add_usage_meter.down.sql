@@ -0,0 +1,30 @@
+DROP TABLE IF EXISTS `account_meter_price`;
+ALTER TABLE `usage_adjustment`
+    DROP COLUMN `meter`;
+-- the usage of all meters is summed into the row of the empty meter
+INSERT INTO `daily_api_usage` (`account_id`, `meter`, `date`, `usage`)
+    SELECT `account_id`, '', `date`, SUM(`usage`) FROM `daily_api_usage` WHERE `meter` <> '' GROUP BY `account_id`, `date`
+    ON DUPLICATE KEY UPDATE `usage` = `usage` + VALUES(`usage`);
+DELETE FROM `daily_api_usage` WHERE `meter` <> '';
+ALTER TABLE `daily_api_usage`
+    DROP PRIMARY KEY,
+    DROP COLUMN `meter`,
+    ADD PRIMARY KEY (`account_id`, `date`);
+-- the usage of all meters is summed into the row of the empty meter
+INSERT INTO `houry_api_usage` (`account_id`, `meter`, `hour`, `usage`)
+    SELECT `account_id`, '', `hour`, SUM(`usage`) FROM `houry_api_usage` WHERE `meter` <> '' GROUP BY `account_id`, `hour`
+    ON DUPLICATE KEY UPDATE `usage` = `usage` + VALUES(`usage`);
+DELETE FROM `houry_api_usage` WHERE `meter` <> '';
+ALTER TABLE `houry_api_usage`
+    DROP PRIMARY KEY,
+    DROP COLUMN `meter`,
+    ADD PRIMARY KEY (`account_id`, `hour`);
+-- the usage of all meters is summed into the row of the empty meter
+INSERT INTO `every_minute_api_usage` (`account_id`, `meter`, `minute`, `usage`, `billable_requests`, `non_billable_requests`)
+    SELECT `account_id`, '', `minute`, SUM(`usage`), SUM(`billable_requests`), SUM(`non_billable_requests`) FROM `every_minute_api_usage` WHERE `meter` <> '' GROUP BY `account_id`, `minute`
+    ON DUPLICATE KEY UPDATE `usage` = `usage` + VALUES(`usage`), `billable_requests` = `billable_requests` + VALUES(`billable_requests`), `non_billable_requests` = `non_billable_requests` + VALUES(`non_billable_requests`);
+DELETE FROM `every_minute_api_usage` WHERE `meter` <> '';
+ALTER TABLE `every_minute_api_usage`
+    DROP PRIMARY KEY,
+    DROP COLUMN `meter`,
+    ADD PRIMARY KEY (`account_id`, `minute`);
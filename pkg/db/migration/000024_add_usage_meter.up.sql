@@ -0,0 +1,27 @@
+-- Usage is kept per meter, the handler the requests were billed by. Rows written before
+-- meters were introduced, and access logs without a meter, are under the empty meter.
+-- The meter comes last in the keys, so ranges of an account's buckets stay one index scan.
+ALTER TABLE `every_minute_api_usage`
+    ADD COLUMN `meter` VARCHAR(64) NOT NULL DEFAULT '' AFTER `account_id`,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (`account_id`, `minute`, `meter`);
+ALTER TABLE `houry_api_usage`
+    ADD COLUMN `meter` VARCHAR(64) NOT NULL DEFAULT '' AFTER `account_id`,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (`account_id`, `hour`, `meter`);
+ALTER TABLE `daily_api_usage`
+    ADD COLUMN `meter` VARCHAR(64) NOT NULL DEFAULT '' AFTER `account_id`,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (`account_id`, `date`, `meter`);
+ALTER TABLE `usage_adjustment`
+    ADD COLUMN `meter` VARCHAR(64) NOT NULL DEFAULT '' AFTER `subscription_id`;
+-- price of one unit of a meter, meters without a row use the default price
+CREATE TABLE IF NOT EXISTS `account_meter_price` (
+    `account_id` bigint UNSIGNED NOT NULL,
+    `meter` VARCHAR(64) NOT NULL,
+    `price_per_usage` DECIMAL(20, 5) NOT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP(),
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP() ON UPDATE CURRENT_TIMESTAMP(),
+    PRIMARY KEY (`account_id`, `meter`),
+    FOREIGN KEY (`account_id`) REFERENCES `account`(`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -4,23 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/config"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
 )
 
 var conn *sql.DB
 
-func MustInit() {
-	db, err := open()
+func MustInit(cfg *config.DB) {
+	db, err := open(cfg)
 	if err != nil {
 		panic(err)
 	}
 	db.SetConnMaxIdleTime(time.Minute)
 	db.SetConnMaxLifetime(time.Minute)
-	db.SetMaxOpenConns(100)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
 
 	conn = db
 }
@@ -35,8 +36,8 @@ func Close() {
 	}
 }
 
-func open() (*sql.DB, error) {
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(localhost:3306)/usage_based_billing?charset=utf8mb4&parseTime=true", "user", "password"))
+func open(cfg *config.DB) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +45,7 @@ func open() (*sql.DB, error) {
 }
 
 type DBConnection interface {
-	PrepareContext(ctx context.Context, query string, args ...any) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
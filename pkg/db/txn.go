@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+// RunInTxn begins a transaction, makes it available through GetTxn and commits
+// when fn returns nil. Otherwise the transaction is rolled back.
+func RunInTxn(ctx context.Context, dbConn *sql.DB, fn func(ctx context.Context) error) error {
+	txn, err := dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, ctxkey.Txn{}, DBConnection(txn))); err != nil {
+		return errors.Join(err, txn.Rollback())
+	}
+
+	return txn.Commit()
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+)
+
+func DBPing(dbConn *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return dbConn.PingContext(ctx)
+	}
+}
+
+func AMQPChannel(mqConn *rabbitmq.Conn) Check {
+	return func(ctx context.Context) error {
+		if !mqConn.IsOpen() {
+			return errors.New("amqp channel closed")
+		}
+		return nil
+	}
+}
+
+func S3Bucket(client *s3.Client, bucket string) Check {
+	return func(ctx context.Context) error {
+		_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket})
+		return err
+	}
+}
+
+// Fresh fails when last, e.g. the last iteration of a loop, is older than maxAge.
+func Fresh(last func() time.Time, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		if age := now.FromContext(ctx).Sub(last()); age > maxAge {
+			return fmt.Errorf("last progress %s ago", age.Truncate(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+// Package health serves readiness and liveness probes of long-running commands.
+//
+// Readiness fails while a dependency is unavailable or the process is draining,
+// so that the load balancer stops routing to it. Liveness fails only when the
+// process is stuck and should be restarted.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+)
+
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type Checker struct {
+	timeout   time.Duration
+	readiness []namedCheck
+	liveness  []namedCheck
+	draining  atomic.Bool
+}
+
+// NewChecker runs every check with timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+	}
+}
+
+// AddReadiness must be called before serving.
+func (c *Checker) AddReadiness(name string, check Check) {
+	c.readiness = append(c.readiness, namedCheck{name: name, check: check})
+}
+
+// AddLiveness must be called before serving.
+func (c *Checker) AddLiveness(name string, check Check) {
+	c.liveness = append(c.liveness, namedCheck{name: name, check: check})
+}
+
+// Drain makes readiness fail from now on while the process keeps serving.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (c *Checker) run(ctx context.Context, checks []namedCheck) (bool, map[string]string) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make(map[string]string, len(checks))
+	ok := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range checks {
+		wg.Go(func() {
+			result := "ok"
+			if err := nc.check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[nc.name] = result
+			ok = ok && result == "ok"
+		})
+	}
+	wg.Wait()
+	return ok, results
+}
+
+func (c *Checker) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	if c.Draining() {
+		httplib.WriteJSON(w, http.StatusServiceUnavailable, &report{Status: "draining", Checks: map[string]string{}})
+		return
+	}
+	ok, checks := c.run(r.Context(), c.readiness)
+	c.write(w, ok, checks)
+}
+
+func (c *Checker) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	ok, checks := c.run(r.Context(), c.liveness)
+	c.write(w, ok, checks)
+}
+
+func (c *Checker) write(w http.ResponseWriter, ok bool, checks map[string]string) {
+	if !ok {
+		httplib.WriteJSON(w, http.StatusServiceUnavailable, &report{Status: "unavailable", Checks: checks})
+		return
+	}
+	httplib.WriteJSON(w, http.StatusOK, &report{Status: "ok", Checks: checks})
+}
+
+// Register adds GET /healthz/ready and GET /healthz/live to mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz/ready", c.HandleReadiness)
+	mux.HandleFunc("GET /healthz/live", c.HandleLiveness)
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+func TestChecker(t *testing.T) {
+	t.Parallel()
+
+	c := NewChecker(time.Second)
+	c.AddReadiness("db", func(ctx context.Context) error { return nil })
+	c.AddReadiness("amqp", func(ctx context.Context) error { return errors.New("amqp channel closed") })
+	c.AddLiveness("loop", func(ctx context.Context) error { return nil })
+
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := serve("/healthz/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"unavailable","checks":{"db":"ok","amqp":"amqp channel closed"}}`, rec.Body.String())
+
+	rec = serve("/healthz/live")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	c.Drain()
+	rec = serve("/healthz/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"draining","checks":{}}`, rec.Body.String())
+	assert.Equal(t, http.StatusOK, serve("/healthz/live").Code)
+}
+
+func TestFresh(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	ctx := now.WithContext(context.Background(), base)
+	last := func() time.Time { return base.Add(-time.Minute) }
+
+	assert.NoError(t, Fresh(last, 2*time.Minute)(ctx))
+	assert.Error(t, Fresh(last, 30*time.Second)(ctx))
+}
@@ -0,0 +1,19 @@
+package httplib
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+func WriteJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to json.Encode", "error", err)
+	}
+}
+
+func WriteError(w http.ResponseWriter, statusCode int, message string) {
+	WriteJSON(w, statusCode, map[string]string{"error": message})
+}
@@ -0,0 +1,99 @@
+package logstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+)
+
+// Catalog is the table of the flushed objects, access_log_object, whose logs
+// are counted in the usage tables.
+type Catalog interface {
+	// Recorded returns the keys that have a row.
+	Recorded(ctx context.Context, keys []string) (map[string]bool, error)
+	// Replace swaps the rows of the inputs of m for a row of its output in one step.
+	// It is called again when a compaction is resumed, so it must be idempotent.
+	Replace(ctx context.Context, m *Manifest) error
+}
+
+// DBCatalog is the Catalog of the access_log_object table.
+type DBCatalog struct {
+	dbConn *sql.DB
+}
+
+var _ Catalog = (*DBCatalog)(nil)
+
+func NewDBCatalog(dbConn *sql.DB) *DBCatalog {
+	return &DBCatalog{dbConn: dbConn}
+}
+
+func (c *DBCatalog) Recorded(ctx context.Context, keys []string) (map[string]bool, error) {
+	recorded := make(map[string]bool)
+	for chunk := range slices.Chunk(keys, 1000) {
+		rows, err := c.dbConn.QueryContext(ctx,
+			"SELECT `object_key` FROM access_log_object WHERE `object_key` IN (?"+strings.Repeat(", ?", len(chunk)-1)+")",
+			keyArgs(chunk)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			recorded[key] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return recorded, nil
+}
+
+func (c *DBCatalog) Replace(ctx context.Context, m *Manifest) error {
+	accountIds, err := json.Marshal(m.AccountIds)
+	if err != nil {
+		return err
+	}
+	inputs := make([]string, len(m.Inputs))
+	for i, in := range m.Inputs {
+		inputs[i] = in.Key
+	}
+
+	return db.RunInTxn(ctx, c.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+		for chunk := range slices.Chunk(inputs, 1000) {
+			if _, err := txn.ExecContext(ctx,
+				"DELETE FROM access_log_object WHERE `object_key` IN (?"+strings.Repeat(", ?", len(chunk)-1)+")",
+				keyArgs(chunk)...,
+			); err != nil {
+				return err
+			}
+		}
+		_, err = txn.ExecContext(ctx,
+			"INSERT INTO access_log_object (`object_key`, `batch_id`, `record_count`, `min_timestamp`, `max_timestamp`, `account_ids`, `size_bytes`, `checksum`) "+
+				db.MakeValues(8, 1)+
+				" ON DUPLICATE KEY UPDATE `object_key` = `object_key`",
+			m.Output, m.Id, m.Rows, m.MinTimestamp, m.MaxTimestamp, accountIds, m.Bytes, m.Checksum,
+		)
+		return err
+	})
+}
+
+func keyArgs(keys []string) []any {
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	return args
+}
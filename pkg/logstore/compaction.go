@@ -0,0 +1,193 @@
+package logstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// Manifest records what a compaction merged. It is written before the inputs are deleted,
+// so inputs left behind by a failed delete can be told apart from uncompacted ones.
+type Manifest struct {
+	// Id is the uuidv7 of the compaction, the batch id of the output in the catalog.
+	Id           string          `json:"id"`
+	Partition    string          `json:"partition"`
+	Output       string          `json:"output"`
+	Rows         int             `json:"rows"`
+	Bytes        int64           `json:"bytes"`
+	MinTimestamp time.Time       `json:"min_timestamp"`
+	MaxTimestamp time.Time       `json:"max_timestamp"`
+	AccountIds   []int64         `json:"account_ids"`
+	Checksum     string          `json:"checksum"` // hex sha256 of the output
+	Inputs       []ManifestInput `json:"inputs"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type ManifestInput struct {
+	Key   string `json:"key"`
+	Bytes int64  `json:"bytes"`
+	Rows  int    `json:"rows"`
+}
+
+// Compactor merges the small objects of an hour partition into one object.
+type Compactor struct {
+	bucket  *bucket
+	catalog Catalog
+}
+
+func NewCompactor(client S3API, bucketName string, catalog Catalog) *Compactor {
+	return &Compactor{
+		bucket:  &bucket{client: client, name: bucketName},
+		catalog: catalog,
+	}
+}
+
+// Compact merges the Parquet objects of p into a single sorted object and
+// replaces their rows in the catalog with one of the output before the inputs
+// are deleted. It returns a nil manifest when p has fewer than two objects.
+//
+// Only objects with a row are merged: the logs of the others were redelivered
+// and are counted in another object, so they are left as they are.
+//
+// Objects already recorded by a manifest are only deleted, not merged again,
+// so rerunning after a crash between writing the manifest and deleting the inputs
+// does not duplicate logs. Their catalog rows are replaced again first, in case
+// the crash came before that.
+func (c *Compactor) Compact(ctx context.Context, p Partition) (*Manifest, error) {
+	keys, err := c.bucket.list(ctx, p.Prefix())
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		present[key] = true
+	}
+
+	manifests, err := readManifests(ctx, c.bucket, p)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]bool)
+	for _, m := range manifests {
+		var leftovers []string
+		for _, in := range m.Inputs {
+			merged[in.Key] = true
+			if present[in.Key] {
+				leftovers = append(leftovers, in.Key)
+			}
+		}
+		if len(leftovers) == 0 {
+			continue
+		}
+		if err := c.catalog.Replace(ctx, m); err != nil {
+			return nil, err
+		}
+		if err := c.bucket.delete(ctx, leftovers); err != nil {
+			return nil, err
+		}
+	}
+
+	var candidates []string
+	for _, key := range keys {
+		if isDataKey(key) && !merged[key] {
+			candidates = append(candidates, key)
+		}
+	}
+	recorded, err := c.catalog.Recorded(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	for _, key := range candidates {
+		if recorded[key] {
+			inputs = append(inputs, key)
+		}
+	}
+	if len(inputs) < 2 {
+		return nil, nil
+	}
+
+	id := uuid.Must(uuid.NewV7()).String()
+	manifest := &Manifest{
+		Id:        id,
+		Partition: p.String(),
+		CreatedAt: now.FromContext(ctx).UTC(),
+	}
+	var logs []types.ApiAccessLog
+	for _, key := range inputs {
+		data, err := c.bucket.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		objectLogs, err := Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		logs = append(logs, objectLogs...)
+		manifest.Inputs = append(manifest.Inputs, ManifestInput{
+			Key:   key,
+			Bytes: int64(len(data)),
+			Rows:  len(objectLogs),
+		})
+	}
+
+	data, err := Encode(logs)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	manifest.Output = p.compactedKey(id)
+	manifest.Rows = len(logs)
+	manifest.Bytes = int64(len(data))
+	manifest.Checksum = hex.EncodeToString(sum[:])
+	manifest.MinTimestamp, manifest.MaxTimestamp, manifest.AccountIds = summarize(logs)
+	if err := c.bucket.put(ctx, manifest.Output, data); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.bucket.put(ctx, p.manifestKey(id), body); err != nil {
+		return nil, err
+	}
+	if err := c.catalog.Replace(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	if err := c.bucket.delete(ctx, inputs); err != nil {
+		return nil, err
+	}
+
+	compactedObjectsTotal.Add(float64(len(manifest.Inputs)))
+	compactedRowsTotal.Add(float64(manifest.Rows))
+	slog.Info("Compacted access logs", "partition", manifest.Partition, "inputs", len(manifest.Inputs), "rows", manifest.Rows, "output", manifest.Output)
+	return manifest, nil
+}
+
+// summarize returns the timestamp range and the sorted account ids of logs.
+func summarize(logs []types.ApiAccessLog) (minTs, maxTs time.Time, accountIds []int64) {
+	minTs, maxTs = logs[0].Timestamp, logs[0].Timestamp
+	for _, l := range logs {
+		accountIds = append(accountIds, l.AccountId)
+		if l.Timestamp.Before(minTs) {
+			minTs = l.Timestamp
+		}
+		if l.Timestamp.After(maxTs) {
+			maxTs = l.Timestamp
+		}
+	}
+	slices.Sort(accountIds)
+	return minTs.UTC(), maxTs.UTC(), slices.Compact(accountIds)
+}
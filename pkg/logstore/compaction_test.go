@@ -0,0 +1,290 @@
+package logstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// fakeS3 is an in-memory bucket. ListObjectsV2 returns one key per page to exercise pagination.
+type fakeS3 struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	deleteErr error
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) keys(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keys []string
+	for _, k := range slices.Sorted(maps.Keys(f.objects)) {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (f *fakeS3) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := f.keys(aws.ToString(in.Prefix))
+	if token := aws.ToString(in.ContinuationToken); token != "" {
+		i, _ := slices.BinarySearch(keys, token)
+		keys = keys[i:]
+	}
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(len(keys) > 1)}
+	if len(keys) > 0 {
+		f.mu.Lock()
+		out.Contents = []s3types.Object{{Key: aws.String(keys[0]), Size: aws.Int64(int64(len(f.objects[keys[0]])))}}
+		f.mu.Unlock()
+	}
+	if len(keys) > 1 {
+		out.NextContinuationToken = aws.String(keys[1])
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.objects[aws.ToString(in.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjects(_ context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	for _, o := range in.Delete.Objects {
+		delete(f.objects, aws.ToString(o.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+// fakeCatalog is an in-memory access_log_object table.
+type fakeCatalog struct {
+	mu         sync.Mutex
+	rows       map[string]bool
+	replaced   int
+	replaceErr error
+}
+
+func newFakeCatalog() *fakeCatalog {
+	return &fakeCatalog{rows: make(map[string]bool)}
+}
+
+func (c *fakeCatalog) Recorded(_ context.Context, keys []string) (map[string]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recorded := make(map[string]bool)
+	for _, key := range keys {
+		if c.rows[key] {
+			recorded[key] = true
+		}
+	}
+	return recorded, nil
+}
+
+func (c *fakeCatalog) Replace(_ context.Context, m *Manifest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.replaceErr != nil {
+		return c.replaceErr
+	}
+	for _, in := range m.Inputs {
+		delete(c.rows, in.Key)
+	}
+	c.rows[m.Output] = true
+	c.replaced++
+	return nil
+}
+
+func (c *fakeCatalog) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Sorted(maps.Keys(c.rows))
+}
+
+func (c *fakeCatalog) record(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		c.rows[key] = true
+	}
+}
+
+func putLogs(t *testing.T, f *fakeS3, key string, logs ...types.ApiAccessLog) {
+	t.Helper()
+
+	data, err := Encode(logs)
+	require.NoError(t, err)
+	_, err = f.PutObject(context.Background(), &s3.PutObjectInput{Key: &key, Body: bytes.NewReader(data)})
+	require.NoError(t, err)
+}
+
+func readManifest(t *testing.T, f *fakeS3, p Partition) Manifest {
+	t.Helper()
+
+	keys := f.keys(p.Prefix() + manifestDir)
+	require.Len(t, keys, 1)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(f.objects[keys[0]], &m))
+	return m
+}
+
+func TestCompactor_Compact(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	ctx := now.WithContext(context.Background(), base.Add(2*time.Hour))
+	p := PartitionOf(base)
+	other := PartitionOf(base.Add(time.Hour))
+
+	f := newFakeS3()
+	putLogs(t, f, p.ObjectKey("a"), types.ApiAccessLog{AccountId: 2, Timestamp: base.Add(time.Second)})
+	putLogs(t, f, p.ObjectKey("b"),
+		types.ApiAccessLog{AccountId: 1, Timestamp: base.Add(2 * time.Second)},
+		types.ApiAccessLog{AccountId: 2, Timestamp: base},
+	)
+	putLogs(t, f, p.ObjectKey("c"), types.ApiAccessLog{AccountId: 1, Timestamp: base.Add(time.Second)})
+	putLogs(t, f, other.ObjectKey("d"), types.ApiAccessLog{AccountId: 1, Timestamp: base.Add(time.Hour)})
+	// e is from a flush whose transaction failed, its log was redelivered
+	putLogs(t, f, p.ObjectKey("e"), types.ApiAccessLog{AccountId: 3, Timestamp: base})
+
+	catalog := newFakeCatalog()
+	catalog.record(p.ObjectKey("a"), p.ObjectKey("b"), p.ObjectKey("c"), other.ObjectKey("d"))
+	c := NewCompactor(f, "bucket", catalog)
+	m, err := c.Compact(ctx, p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Equal(t, "dt=2025-07-01/hour=10", m.Partition)
+	assert.Equal(t, 4, m.Rows)
+	assert.Equal(t, base, m.MinTimestamp)
+	assert.Equal(t, base.Add(2*time.Second), m.MaxTimestamp)
+	assert.Equal(t, []int64{1, 2}, m.AccountIds)
+	assert.Len(t, m.Checksum, 64)
+	assert.Equal(t, p.compactedKey(m.Id), m.Output)
+	assert.Equal(t, base.Add(2*time.Hour), m.CreatedAt)
+	assert.Equal(t, []string{p.ObjectKey("a"), p.ObjectKey("b"), p.ObjectKey("c")}, []string{m.Inputs[0].Key, m.Inputs[1].Key, m.Inputs[2].Key})
+	assert.Equal(t, []int{1, 2, 1}, []int{m.Inputs[0].Rows, m.Inputs[1].Rows, m.Inputs[2].Rows})
+	assert.Equal(t, *m, readManifest(t, f, p))
+
+	assert.ElementsMatch(t, []string{m.Output, p.ObjectKey("e")}, slices.DeleteFunc(f.keys(p.Prefix()), func(k string) bool { return !isDataKey(k) }), "inputs are deleted, objects without a row are left")
+	assert.Equal(t, []string{other.ObjectKey("d")}, f.keys(other.Prefix()), "other partitions are untouched")
+	assert.ElementsMatch(t, []string{m.Output, other.ObjectKey("d")}, catalog.keys(), "the rows of the inputs are replaced by the output")
+
+	got, err := Decode(f.objects[m.Output])
+	require.NoError(t, err)
+	assert.Equal(t, []types.ApiAccessLog{
+		{AccountId: 1, Timestamp: base.Add(time.Second)},
+		{AccountId: 1, Timestamp: base.Add(2 * time.Second)},
+		{AccountId: 2, Timestamp: base},
+		{AccountId: 2, Timestamp: base.Add(time.Second)},
+	}, got)
+
+	m, err = c.Compact(ctx, p)
+	require.NoError(t, err)
+	assert.Nil(t, m, "a single object with a row is left as is")
+}
+
+func TestCompactor_Compact_resumesDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	p := PartitionOf(base)
+
+	f := newFakeS3()
+	putLogs(t, f, p.ObjectKey("a"), types.ApiAccessLog{AccountId: 1, Timestamp: base})
+	putLogs(t, f, p.ObjectKey("b"), types.ApiAccessLog{AccountId: 2, Timestamp: base})
+
+	catalog := newFakeCatalog()
+	catalog.record(p.ObjectKey("a"), p.ObjectKey("b"))
+	c := NewCompactor(f, "bucket", catalog)
+	f.deleteErr = assert.AnError
+	_, err := c.Compact(ctx, p)
+	require.ErrorIs(t, err, assert.AnError)
+	m := readManifest(t, f, p)
+	assert.Len(t, f.keys(p.Prefix()), 4, "inputs, output and manifest")
+	assert.Equal(t, []string{m.Output}, catalog.keys(), "rows are replaced before the inputs are deleted")
+
+	f.deleteErr = nil
+	got, err := c.Compact(ctx, p)
+	require.NoError(t, err)
+	assert.Nil(t, got, "recorded inputs are not merged again")
+	assert.Equal(t, m, readManifest(t, f, p))
+	assert.Equal(t, []string{p.manifestKey(m.Id), m.Output}, f.keys(p.Prefix()))
+	assert.Equal(t, []string{m.Output}, catalog.keys())
+	assert.Equal(t, 2, catalog.replaced, "the rows are replaced again before the leftovers are deleted")
+}
+
+func TestCompactor_Compact_resumesCatalog(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	p := PartitionOf(base)
+
+	f := newFakeS3()
+	putLogs(t, f, p.ObjectKey("a"), types.ApiAccessLog{AccountId: 1, Timestamp: base})
+	putLogs(t, f, p.ObjectKey("b"), types.ApiAccessLog{AccountId: 2, Timestamp: base})
+
+	catalog := newFakeCatalog()
+	catalog.record(p.ObjectKey("a"), p.ObjectKey("b"))
+	c := NewCompactor(f, "bucket", catalog)
+	catalog.replaceErr = assert.AnError
+	_, err := c.Compact(ctx, p)
+	require.ErrorIs(t, err, assert.AnError)
+	m := readManifest(t, f, p)
+	assert.Len(t, f.keys(p.Prefix()), 4, "the inputs are kept while their rows are")
+	assert.Equal(t, []string{p.ObjectKey("a"), p.ObjectKey("b")}, catalog.keys())
+
+	catalog.replaceErr = nil
+	got, err := c.Compact(ctx, p)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.Equal(t, []string{p.manifestKey(m.Id), m.Output}, f.keys(p.Prefix()))
+	assert.Equal(t, []string{m.Output}, catalog.keys())
+}
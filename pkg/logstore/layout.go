@@ -0,0 +1,86 @@
+// Package logstore is the S3 layout and Parquet format of access logs.
+//
+// Objects are Hive-partitioned by the UTC hour of their logs:
+//
+//	logs/dt=2025-07-01/hour=10/<uuidv7>.parquet            written by a worker flush
+//	logs/dt=2025-07-01/hour=10/compacted-<uuidv7>.parquet  written by compaction
+//	logs/dt=2025-07-01/hour=10/_compaction/<uuidv7>.json   what a compaction merged
+//
+// Query engines skip keys starting with "_" inside a partition.
+package logstore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	Prefix = "logs/"
+
+	compactedPrefix = "compacted-"
+	manifestDir     = "_compaction/"
+)
+
+// Partition is the hour of access logs an object holds.
+type Partition struct {
+	hour time.Time
+}
+
+// PartitionOf returns the partition of a log written at t.
+func PartitionOf(t time.Time) Partition {
+	return Partition{hour: t.UTC().Truncate(time.Hour)}
+}
+
+func (p Partition) Hour() time.Time {
+	return p.hour
+}
+
+// Prefix returns the key prefix of every object of p, e.g. logs/dt=2025-07-01/hour=10/.
+func (p Partition) Prefix() string {
+	return fmt.Sprintf("%sdt=%s/hour=%02d/", Prefix, p.hour.Format(time.DateOnly), p.hour.Hour())
+}
+
+func (p Partition) String() string {
+	return strings.TrimSuffix(strings.TrimPrefix(p.Prefix(), Prefix), "/")
+}
+
+// ObjectKey returns the key of a flushed object named id.
+func (p Partition) ObjectKey(id string) string {
+	return p.Prefix() + id + ".parquet"
+}
+
+func (p Partition) compactedKey(id string) string {
+	return p.Prefix() + compactedPrefix + id + ".parquet"
+}
+
+func (p Partition) manifestKey(id string) string {
+	return p.Prefix() + manifestDir + id + ".json"
+}
+
+// ParsePartition returns the partition of an object key written by this package.
+func ParsePartition(key string) (Partition, error) {
+	var date string
+	var hour int
+	rest, ok := strings.CutPrefix(key, Prefix+"dt=")
+	if ok {
+		date, rest, ok = strings.Cut(rest, "/hour=")
+	}
+	if ok {
+		_, err := fmt.Sscanf(rest, "%02d/", &hour)
+		ok = err == nil && hour >= 0 && hour < 24
+	}
+	if !ok {
+		return Partition{}, fmt.Errorf("not an access log key: %s", key)
+	}
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return Partition{}, fmt.Errorf("not an access log key: %s: %w", key, err)
+	}
+	return Partition{hour: day.Add(time.Duration(hour) * time.Hour)}, nil
+}
+
+// isDataKey reports whether key is a Parquet object and not a manifest.
+func isDataKey(key string) bool {
+	return strings.HasSuffix(key, ".parquet") && !strings.Contains(key, "/_")
+}
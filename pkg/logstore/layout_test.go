@@ -0,0 +1,50 @@
+package logstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	t.Parallel()
+
+	jst := time.FixedZone("JST", 9*60*60)
+	p := PartitionOf(time.Date(2025, 7, 1, 8, 30, 0, 0, jst))
+
+	assert.Equal(t, "logs/dt=2025-06-30/hour=23/", p.Prefix())
+	assert.Equal(t, "dt=2025-06-30/hour=23", p.String())
+	assert.Equal(t, "logs/dt=2025-06-30/hour=23/id.parquet", p.ObjectKey("id"))
+	assert.Equal(t, time.Date(2025, 6, 30, 23, 0, 0, 0, time.UTC), p.Hour())
+}
+
+func TestParsePartition(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key     string
+		want    time.Time
+		wantErr bool
+	}{
+		{key: "logs/dt=2025-07-01/hour=10/id.parquet", want: time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)},
+		{key: "logs/dt=2025-07-01/hour=00/_compaction/id.json", want: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{key: "logs/2025/07/01/id.parquet", wantErr: true},
+		{key: "logs/dt=2025-07-01/hour=24/id.parquet", wantErr: true},
+		{key: "logs/dt=2025-13-01/hour=10/id.parquet", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParsePartition(tt.key)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, PartitionOf(tt.want), got)
+		})
+	}
+}
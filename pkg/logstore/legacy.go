@@ -0,0 +1,117 @@
+package logstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+// Objects flushed before the hourly layout are keyed by the date of the flush in
+// the worker's timezone, e.g. logs/2025/07/01/<uuidv7>.parquet, and may hold logs
+// of any hour. Source does not read them; Migrator moves them into the partitions.
+
+// LegacyPrefix returns the key prefix of the legacy objects flushed on the date of day, e.g. logs/2025/07/01/.
+func LegacyPrefix(day time.Time) string {
+	return Prefix + day.Format("2006/01/02") + "/"
+}
+
+// legacyId returns the name of a legacy object without its extension.
+func legacyId(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, Prefix)
+	if !ok || !isDataKey(key) {
+		return "", false
+	}
+	dir, name := path.Split(rest)
+	if _, err := time.Parse("2006/01/02/", dir); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(name, ".parquet"), true
+}
+
+// Migrator moves legacy objects into the hourly layout.
+type Migrator struct {
+	bucket  *bucket
+	catalog Catalog
+}
+
+func NewMigrator(client S3API, bucketName string, catalog Catalog) *Migrator {
+	return &Migrator{
+		bucket:  &bucket{client: client, name: bucketName},
+		catalog: catalog,
+	}
+}
+
+// Migrate moves the legacy objects flushed on the date of day into the partitions
+// of their logs and returns the manifests of the objects written.
+//
+// A legacy object is split into one object per partition named after it, each is
+// recorded in the catalog, and the legacy object is deleted last. Their logs were
+// counted when they were flushed, so the rows only record that. The keys and batch
+// ids are derived from the legacy key, so rerunning after a failure rewrites the
+// same objects and rows.
+func (m *Migrator) Migrate(ctx context.Context, day time.Time) ([]*Manifest, error) {
+	keys, err := m.bucket.list(ctx, LegacyPrefix(day))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, key := range keys {
+		id, ok := legacyId(key)
+		if !ok {
+			continue
+		}
+		data, err := m.bucket.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		logs, err := Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		partitions := SplitByPartition(logs)
+		for _, p := range slices.SortedFunc(maps.Keys(partitions), func(a, b Partition) int { return a.hour.Compare(b.hour) }) {
+			partitionLogs := partitions[p]
+			out, err := Encode(partitionLogs)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(out)
+			manifest := &Manifest{
+				Id:        id,
+				Partition: p.String(),
+				Output:    p.ObjectKey(id),
+				Rows:      len(partitionLogs),
+				Bytes:     int64(len(out)),
+				Checksum:  hex.EncodeToString(sum[:]),
+				Inputs:    []ManifestInput{{Key: key, Bytes: int64(len(data)), Rows: len(logs)}},
+				CreatedAt: now.FromContext(ctx).UTC(),
+			}
+			manifest.MinTimestamp, manifest.MaxTimestamp, manifest.AccountIds = summarize(partitionLogs)
+			if err := m.bucket.put(ctx, manifest.Output, out); err != nil {
+				return nil, err
+			}
+			if err := m.catalog.Replace(ctx, manifest); err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, manifest)
+		}
+
+		if err := m.bucket.delete(ctx, []string{key}); err != nil {
+			return nil, err
+		}
+		migratedObjectsTotal.Inc()
+		slog.Info("Migrated legacy access logs", "key", key, "rows", len(logs), "partitions", len(partitions))
+	}
+	return manifests, nil
+}
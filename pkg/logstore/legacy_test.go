@@ -0,0 +1,91 @@
+package logstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestMigrator_Migrate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	jst := time.FixedZone("JST", 9*60*60)
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, jst)
+	// flushed shortly after midnight JST, so its logs are of the previous UTC day
+	base := time.Date(2025, 6, 30, 14, 59, 0, 0, time.UTC)
+	p, next := PartitionOf(base), PartitionOf(base.Add(time.Hour))
+
+	f := newFakeS3()
+	legacy := LegacyPrefix(day) + "a.parquet"
+	putLogs(t, f, legacy,
+		types.ApiAccessLog{AccountId: 1, Timestamp: base},
+		types.ApiAccessLog{AccountId: 2, Timestamp: base.Add(2 * time.Minute)},
+		types.ApiAccessLog{AccountId: 1, Timestamp: base.Add(3 * time.Minute)},
+	)
+	putLogs(t, f, LegacyPrefix(day.AddDate(0, 0, 1))+"b.parquet", types.ApiAccessLog{AccountId: 1, Timestamp: base.Add(24 * time.Hour)})
+
+	catalog := newFakeCatalog()
+	m := NewMigrator(f, "bucket", catalog)
+	f.deleteErr = assert.AnError
+	_, err := m.Migrate(ctx, day)
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, []string{legacy}, f.keys(LegacyPrefix(day)), "the legacy object is kept until it is migrated")
+
+	f.deleteErr = nil
+	manifests, err := m.Migrate(ctx, day)
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	assert.Empty(t, f.keys(LegacyPrefix(day)))
+	assert.Len(t, f.keys(LegacyPrefix(day.AddDate(0, 0, 1))), 1, "other days are untouched")
+	assert.Equal(t, []string{p.ObjectKey("a")}, f.keys(p.Prefix()), "rerunning rewrites the same objects")
+	assert.Equal(t, []string{next.ObjectKey("a")}, f.keys(next.Prefix()))
+	assert.Equal(t, []string{p.ObjectKey("a"), next.ObjectKey("a")}, catalog.keys())
+
+	assert.Equal(t, "a", manifests[0].Id)
+	assert.Equal(t, p.ObjectKey("a"), manifests[0].Output)
+	assert.Equal(t, 1, manifests[0].Rows)
+	assert.Equal(t, []int64{1, 2}, manifests[1].AccountIds)
+	assert.Equal(t, base.Add(2*time.Minute), manifests[1].MinTimestamp)
+	assert.Equal(t, base.Add(3*time.Minute), manifests[1].MaxTimestamp)
+
+	src := NewS3Source(f, "bucket")
+	keys, err := src.Keys(ctx, next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{next.ObjectKey("a")}, keys)
+	logs, err := src.Read(ctx, keys[0])
+	require.NoError(t, err)
+	assert.Equal(t, []types.ApiAccessLog{
+		{AccountId: 1, Timestamp: base.Add(3 * time.Minute)},
+		{AccountId: 2, Timestamp: base.Add(2 * time.Minute)},
+	}, logs)
+}
+
+func TestLegacyId(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key    string
+		want   string
+		wantOk bool
+	}{
+		{key: "logs/2025/07/01/id.parquet", want: "id", wantOk: true},
+		{key: "logs/dt=2025-07-01/hour=10/id.parquet"},
+		{key: "logs/2025/07/01/id.json"},
+		{key: "logs/2025/13/01/id.parquet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := legacyId(tt.key)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package logstore
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	compactedObjectsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "logstore_compacted_objects_total",
+		Help: "Parquet objects merged and deleted by compaction.",
+	})
+
+	compactedRowsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "logstore_compacted_rows_total",
+		Help: "Access log rows written to compacted objects.",
+	})
+
+	migratedObjectsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "logstore_migrated_legacy_objects_total",
+		Help: "Legacy Parquet objects moved into the hourly layout and deleted.",
+	})
+)
@@ -0,0 +1,263 @@
+package logstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/compress"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// SchemaVersion is the version of the Parquet schema written by Encode.
+//
+//	1: account_id, client_ip, method, path, status_code, latency_ms, user_agent, timestamp.
+//	   latency_ms holds nanoseconds despite its name. Objects without a schema_version are version 1.
+//	2: latency_ms is renamed to latency_ns; request_id, api_key_id, region and response_bytes are added.
+//	3: meter and usage are added. Objects of earlier versions bill one call per row.
+//	4: billable is added. Objects of earlier versions only hold billable rows.
+const SchemaVersion = 4
+
+const (
+	schemaVersionKey  = "schema_version"
+	sortingColumnsKey = "sorting_columns"
+)
+
+var schema = arrow.NewSchema(
+	[]arrow.Field{
+		{Name: "account_id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "client_ip", Type: arrow.BinaryTypes.String},
+		{Name: "method", Type: arrow.BinaryTypes.String},
+		{Name: "path", Type: arrow.BinaryTypes.String},
+		{Name: "status_code", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "latency_ns", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "user_agent", Type: arrow.BinaryTypes.String},
+		{Name: "timestamp", Type: arrow.FixedWidthTypes.Timestamp_ms},
+		{Name: "request_id", Type: arrow.BinaryTypes.String},
+		{Name: "api_key_id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "region", Type: arrow.BinaryTypes.String},
+		{Name: "response_bytes", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "meter", Type: arrow.BinaryTypes.String},
+		{Name: "usage", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "billable", Type: arrow.FixedWidthTypes.Boolean},
+	},
+	nil, // metadata
+)
+
+// rowGroupRows bounds a row group. Rows are sorted, so the account_id
+// statistics of a row group let readers skip the groups of other accounts.
+const rowGroupRows = 64 * 1024
+
+// Encode writes logs as Parquet of SchemaVersion sorted by account_id and timestamp.
+// logs is not modified.
+func Encode(logs []types.ApiAccessLog) ([]byte, error) {
+	sorted := slices.Clone(logs)
+	slices.SortStableFunc(sorted, func(a, b types.ApiAccessLog) int {
+		return cmp.Or(cmp.Compare(a.AccountId, b.AccountId), a.Timestamp.Compare(b.Timestamp))
+	})
+
+	pool := memory.NewGoAllocator()
+	rb := array.NewRecordBuilder(pool, schema)
+	defer rb.Release()
+
+	for _, l := range sorted {
+		rb.Field(0).(*array.Int64Builder).Append(l.AccountId)
+		rb.Field(1).(*array.StringBuilder).Append(l.ClientIP)
+		rb.Field(2).(*array.StringBuilder).Append(l.Method)
+		rb.Field(3).(*array.StringBuilder).Append(l.Path)
+		rb.Field(4).(*array.Int32Builder).Append(int32(l.StatusCode))
+		rb.Field(5).(*array.Int64Builder).Append(l.Latency)
+		rb.Field(6).(*array.StringBuilder).Append(l.UserAgent)
+		rb.Field(7).(*array.TimestampBuilder).Append(arrow.Timestamp(l.Timestamp.UnixMilli()))
+		rb.Field(8).(*array.StringBuilder).Append(l.RequestId)
+		rb.Field(9).(*array.Int64Builder).Append(l.ApiKeyId)
+		rb.Field(10).(*array.StringBuilder).Append(l.Region)
+		rb.Field(11).(*array.Int64Builder).Append(l.ResponseBytes)
+		rb.Field(12).(*array.StringBuilder).Append(l.Meter)
+		rb.Field(13).(*array.Int64Builder).Append(l.Usage)
+		rb.Field(14).(*array.BooleanBuilder).Append(!l.NonBillable)
+	}
+
+	rec := rb.NewRecord()
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithMaxRowGroupLength(rowGroupRows),
+	)
+	writer, err := pqarrow.NewFileWriter(schema, &buf, props, pqarrow.NewArrowWriterProperties())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet file writer: %w", err)
+	}
+	for _, kv := range [][2]string{
+		{schemaVersionKey, strconv.Itoa(SchemaVersion)},
+		{sortingColumnsKey, "account_id,timestamp"},
+	} {
+		if err := writer.AppendKeyValueMetadata(kv[0], kv[1]); err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+
+	if err := writer.Write(rec); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to write record to parquet: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close parquet writer: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode reads the logs of a Parquet object of any schema version up to SchemaVersion.
+// Columns a version does not have are left zero.
+func Decode(data []byte) ([]types.ApiAccessLog, error) {
+	reader, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet: %w", err)
+	}
+	defer reader.Close()
+
+	version := 1
+	if v := reader.MetaData().KeyValueMetadata().FindValue(schemaVersionKey); v != nil {
+		if version, err = strconv.Atoi(*v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", schemaVersionKey, *v, err)
+		}
+	}
+	if version < 1 || version > SchemaVersion {
+		return nil, fmt.Errorf("unsupported %s %d", schemaVersionKey, version)
+	}
+	latencyColumn := "latency_ns"
+	if version == 1 {
+		latencyColumn = "latency_ms"
+	}
+
+	fileReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet: %w", err)
+	}
+	table, err := fileReader.ReadTable(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet: %w", err)
+	}
+	defer table.Release()
+
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+
+	logs := make([]types.ApiAccessLog, 0, table.NumRows())
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		accountIds := cols.int64s("account_id")
+		clientIps := cols.strings("client_ip")
+		methods := cols.strings("method")
+		paths := cols.strings("path")
+		statusCodes := cols.int32s("status_code")
+		latencies := cols.int64s(latencyColumn)
+		userAgents := cols.strings("user_agent")
+		timestamps := cols.timestamps("timestamp")
+		requestIds := cols.strings("request_id")
+		apiKeyIds := cols.int64s("api_key_id")
+		regions := cols.strings("region")
+		responseBytes := cols.int64s("response_bytes")
+		meters := cols.strings("meter")
+		usages := cols.int64s("usage")
+		billables := cols.bools("billable")
+		if version < 4 {
+			billables = func(int) bool { return true }
+		}
+		if cols.err != nil {
+			return nil, cols.err
+		}
+
+		for i := range int(rec.NumRows()) {
+			logs = append(logs, types.ApiAccessLog{
+				AccountId:     accountIds(i),
+				ClientIP:      clientIps(i),
+				Method:        methods(i),
+				Path:          paths(i),
+				StatusCode:    int(statusCodes(i)),
+				Latency:       latencies(i),
+				UserAgent:     userAgents(i),
+				Timestamp:     time.UnixMilli(int64(timestamps(i))).UTC(),
+				RequestId:     requestIds(i),
+				ApiKeyId:      apiKeyIds(i),
+				Region:        regions(i),
+				ResponseBytes: responseBytes(i),
+				Meter:         meters(i),
+				Usage:         usages(i),
+				NonBillable:   !billables(i),
+			})
+		}
+	}
+	return logs, tr.Err()
+}
+
+// columns looks up the columns of a record by name. A missing column reads as
+// zero values; a column of an unexpected type is an error.
+type columns struct {
+	rec arrow.Record
+	err error
+}
+
+func column[T any, A interface {
+	arrow.Array
+	Value(int) T
+}](c *columns, name string) func(int) T {
+	indices := c.rec.Schema().FieldIndices(name)
+	if len(indices) == 0 {
+		return func(int) (zero T) { return zero }
+	}
+	values, ok := c.rec.Column(indices[0]).(A)
+	if !ok {
+		c.err = errors.Join(c.err, fmt.Errorf("column %s has unexpected type %s", name, c.rec.Column(indices[0]).DataType()))
+		return func(int) (zero T) { return zero }
+	}
+	return values.Value
+}
+
+func (c *columns) int64s(name string) func(int) int64 {
+	return column[int64, *array.Int64](c, name)
+}
+
+func (c *columns) int32s(name string) func(int) int32 {
+	return column[int32, *array.Int32](c, name)
+}
+
+func (c *columns) strings(name string) func(int) string {
+	return column[string, *array.String](c, name)
+}
+
+func (c *columns) bools(name string) func(int) bool {
+	return column[bool, *array.Boolean](c, name)
+}
+
+func (c *columns) timestamps(name string) func(int) arrow.Timestamp {
+	return column[arrow.Timestamp, *array.Timestamp](c, name)
+}
+
+// SplitByPartition groups logs by the hour partition of their timestamp.
+func SplitByPartition(logs []types.ApiAccessLog) map[Partition][]types.ApiAccessLog {
+	partitions := make(map[Partition][]types.ApiAccessLog)
+	for _, l := range logs {
+		p := PartitionOf(l.Timestamp)
+		partitions[p] = append(partitions[p], l)
+	}
+	return partitions
+}
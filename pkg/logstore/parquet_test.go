@@ -0,0 +1,136 @@
+package logstore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	logs := []types.ApiAccessLog{
+		{AccountId: 2, Timestamp: base.Add(2 * time.Second), Path: "/c", Method: "GET", StatusCode: 200, Latency: 3},
+		{AccountId: 1, Timestamp: base.Add(3 * time.Second), Path: "/b", Method: "POST", StatusCode: 201, Latency: 2},
+		{AccountId: 1, Timestamp: base.Add(1 * time.Second), Path: "/a", Method: "GET", StatusCode: 500, Latency: 1, ClientIP: "10.0.0.1", UserAgent: "curl",
+			RequestId: "req", ApiKeyId: 10, Region: "ap-northeast-1", ResponseBytes: 512, Meter: "api1", Usage: 2, NonBillable: true},
+	}
+
+	data, err := Encode(logs)
+	require.NoError(t, err)
+	assert.Equal(t, "/c", logs[0].Path, "input is not reordered")
+
+	got, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ApiAccessLog{logs[2], logs[1], logs[0]}, got)
+
+	reader, err := file.NewParquetReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, "account_id,timestamp", *reader.MetaData().KeyValueMetadata().FindValue("sorting_columns"))
+	assert.Equal(t, "4", *reader.MetaData().KeyValueMetadata().FindValue("schema_version"))
+}
+
+// encodeV1 writes logs with the schema of version 1, which had no schema_version.
+func encodeV1(t *testing.T, logs []types.ApiAccessLog, metadata ...string) []byte {
+	t.Helper()
+
+	schemaV1 := arrow.NewSchema([]arrow.Field{
+		{Name: "account_id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "client_ip", Type: arrow.BinaryTypes.String},
+		{Name: "method", Type: arrow.BinaryTypes.String},
+		{Name: "path", Type: arrow.BinaryTypes.String},
+		{Name: "status_code", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "latency_ms", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "user_agent", Type: arrow.BinaryTypes.String},
+		{Name: "timestamp", Type: arrow.FixedWidthTypes.Timestamp_ms},
+	}, nil)
+	rb := array.NewRecordBuilder(memory.NewGoAllocator(), schemaV1)
+	defer rb.Release()
+	for _, l := range logs {
+		rb.Field(0).(*array.Int64Builder).Append(l.AccountId)
+		rb.Field(1).(*array.StringBuilder).Append(l.ClientIP)
+		rb.Field(2).(*array.StringBuilder).Append(l.Method)
+		rb.Field(3).(*array.StringBuilder).Append(l.Path)
+		rb.Field(4).(*array.Int32Builder).Append(int32(l.StatusCode))
+		rb.Field(5).(*array.Int64Builder).Append(l.Latency)
+		rb.Field(6).(*array.StringBuilder).Append(l.UserAgent)
+		rb.Field(7).(*array.TimestampBuilder).Append(arrow.Timestamp(l.Timestamp.UnixMilli()))
+	}
+	rec := rb.NewRecord()
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	writer, err := pqarrow.NewFileWriter(schemaV1, &buf, parquet.NewWriterProperties(), pqarrow.NewArrowWriterProperties())
+	require.NoError(t, err)
+	for i := 0; i < len(metadata); i += 2 {
+		require.NoError(t, writer.AppendKeyValueMetadata(metadata[i], metadata[i+1]))
+	}
+	require.NoError(t, writer.Write(rec))
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestDecode_versions(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	log := types.ApiAccessLog{AccountId: 1, Timestamp: ts, ClientIP: "10.0.0.1", Path: "/a", Method: "GET", StatusCode: 200, Latency: 1500000, UserAgent: "curl"}
+	v4 := log
+	v4.RequestId, v4.ApiKeyId, v4.Region, v4.ResponseBytes, v4.Meter, v4.Usage, v4.NonBillable = "req", 10, "ap-northeast-1", 512, "api1", 2, true
+	current, err := Encode([]types.ApiAccessLog{v4})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []types.ApiAccessLog
+		wantErr string
+	}{
+		{name: "v1 without metadata", data: encodeV1(t, []types.ApiAccessLog{log}), want: []types.ApiAccessLog{log}},
+		{name: "v1", data: encodeV1(t, []types.ApiAccessLog{log}, "schema_version", "1"), want: []types.ApiAccessLog{log}},
+		{name: "v4", data: current, want: []types.ApiAccessLog{v4}},
+		{name: "newer", data: encodeV1(t, []types.ApiAccessLog{log}, "schema_version", "5"), wantErr: "unsupported schema_version 5"},
+		{name: "invalid", data: encodeV1(t, []types.ApiAccessLog{log}, "schema_version", "x"), wantErr: "invalid schema_version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Decode(tt.data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitByPartition(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 7, 1, 10, 59, 59, 0, time.UTC)
+	got := SplitByPartition([]types.ApiAccessLog{
+		{AccountId: 1, Timestamp: base},
+		{AccountId: 2, Timestamp: base.Add(time.Second)},
+		{AccountId: 3, Timestamp: base.Add(-time.Minute)},
+	})
+
+	assert.Len(t, got, 2)
+	assert.Len(t, got[PartitionOf(base)], 2)
+	assert.Len(t, got[PartitionOf(base.Add(time.Second))], 1)
+}
@@ -0,0 +1,229 @@
+package logstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// S3API is the part of *s3.Client the compactor and Source use.
+type S3API interface {
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, opts ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// store lists and reads the objects of the access log layout.
+type store interface {
+	list(ctx context.Context, prefix string) ([]string, error)
+	get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Source reads the access logs of hour partitions from S3 or from a local copy of the bucket.
+type Source struct {
+	store store
+}
+
+func NewS3Source(client S3API, bucketName string) *Source {
+	return &Source{store: &bucket{client: client, name: bucketName}}
+}
+
+// NewDirSource reads a directory laid out like the bucket, e.g. one synced with `aws s3 sync`.
+func NewDirSource(dir string) *Source {
+	return &Source{store: dirStore(dir)}
+}
+
+// Keys returns the data objects of p, leaving out the inputs a compaction has already merged.
+func (s *Source) Keys(ctx context.Context, p Partition) ([]string, error) {
+	keys, err := s.store.list(ctx, p.Prefix())
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergedKeys(ctx, s.store, p)
+	if err != nil {
+		return nil, err
+	}
+	var data []string
+	for _, key := range keys {
+		if isDataKey(key) && !merged[key] {
+			data = append(data, key)
+		}
+	}
+	return data, nil
+}
+
+// Read decodes the object at key.
+func (s *Source) Read(ctx context.Context, key string) ([]types.ApiAccessLog, error) {
+	data, err := s.store.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return logs, nil
+}
+
+// mergedKeys returns the inputs recorded by the compaction manifests of p.
+func mergedKeys(ctx context.Context, st store, p Partition) (map[string]bool, error) {
+	manifests, err := readManifests(ctx, st, p)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]bool)
+	for _, m := range manifests {
+		for _, in := range m.Inputs {
+			merged[in.Key] = true
+		}
+	}
+	return merged, nil
+}
+
+// readManifests returns the compaction manifests of p.
+func readManifests(ctx context.Context, st store, p Partition) ([]*Manifest, error) {
+	keys, err := st.list(ctx, p.Prefix()+manifestDir)
+	if err != nil {
+		return nil, err
+	}
+	var manifests []*Manifest
+	for _, key := range keys {
+		data, err := st.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		manifests = append(manifests, &m)
+	}
+	return manifests, nil
+}
+
+// bucket is the store of an S3 bucket.
+type bucket struct {
+	client S3API
+	name   string
+}
+
+func (b *bucket) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	in := &s3.ListObjectsV2Input{
+		Bucket: &b.name,
+		Prefix: &prefix,
+	}
+	for {
+		out, err := b.client.ListObjectsV2(ctx, in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		for _, o := range out.Contents {
+			keys = append(keys, *o.Key)
+		}
+		if out.IsTruncated == nil || !*out.IsTruncated {
+			return keys, nil
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+func (b *bucket) get(ctx context.Context, key string) ([]byte, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &b.name,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func (b *bucket) put(ctx context.Context, key string, data []byte) error {
+	if _, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &b.name,
+		Key:    &key,
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *bucket) delete(ctx context.Context, keys []string) error {
+	// DeleteObjects accepts up to 1000 keys per request.
+	for chunk := range slices.Chunk(keys, 1000) {
+		objects := make([]s3types.ObjectIdentifier, len(chunk))
+		for i, key := range chunk {
+			objects[i] = s3types.ObjectIdentifier{Key: &key}
+		}
+		out, err := b.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &b.name,
+			Delete: &s3types.Delete{Objects: objects},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete compacted objects: %w", err)
+		}
+		if len(out.Errors) > 0 {
+			var errs []error
+			for _, e := range out.Errors {
+				errs = append(errs, fmt.Errorf("%s: %s", aws.ToString(e.Key), aws.ToString(e.Message)))
+			}
+			return fmt.Errorf("failed to delete compacted objects: %w", errors.Join(errs...))
+		}
+	}
+	return nil
+}
+
+// dirStore is the store of a local directory, keyed by slash-separated paths relative to it.
+type dirStore string
+
+func (d dirStore) list(_ context.Context, prefix string) ([]string, error) {
+	// Prefixes of the layout always end at a directory.
+	root := filepath.Join(string(d), filepath.FromSlash(prefix))
+	var keys []string
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	return keys, nil
+}
+
+func (d dirStore) get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(string(d), filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	return data, nil
+}
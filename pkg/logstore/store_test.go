@@ -0,0 +1,62 @@
+package logstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	p := PartitionOf(base)
+
+	// A compaction whose delete failed leaves its inputs next to the output.
+	f := newFakeS3()
+	putLogs(t, f, p.ObjectKey("a"), types.ApiAccessLog{AccountId: 1, Timestamp: base})
+	putLogs(t, f, p.ObjectKey("b"), types.ApiAccessLog{AccountId: 2, Timestamp: base})
+	catalog := newFakeCatalog()
+	catalog.record(p.ObjectKey("a"), p.ObjectKey("b"))
+	f.deleteErr = assert.AnError
+	_, err := NewCompactor(f, "bucket", catalog).Compact(ctx, p)
+	require.ErrorIs(t, err, assert.AnError)
+	m := readManifest(t, f, p)
+	putLogs(t, f, p.ObjectKey("c"), types.ApiAccessLog{AccountId: 3, Timestamp: base})
+
+	dir := t.TempDir()
+	for key, data := range f.objects {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+
+	for name, src := range map[string]*Source{
+		"s3":  NewS3Source(f, "bucket"),
+		"dir": NewDirSource(dir),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := src.Keys(ctx, p)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{p.ObjectKey("c"), m.Output}, keys, "merged inputs and manifests are left out")
+
+			logs, err := src.Read(ctx, m.Output)
+			require.NoError(t, err)
+			assert.Len(t, logs, 2)
+
+			keys, err = src.Keys(ctx, PartitionOf(base.Add(time.Hour)))
+			require.NoError(t, err)
+			assert.Empty(t, keys)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Package metrics exposes the collectors registered by each package on the
+// default Prometheus registry.
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Handler serves the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Push sends the default registry to a Pushgateway for batch commands that exit
+// before they could be scraped.
+func Push(ctx context.Context, url, job string) error {
+	return push.New(url, job).
+		Gatherer(prometheus.DefaultGatherer).
+		PushContext(ctx)
+}
@@ -0,0 +1,276 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publishes to a topic partitioned by message key and consumes it in a
+// consumer group. Offsets are committed when messages are acked.
+type Kafka struct {
+	brokers []string
+	topic   string
+	groupId string
+	writer  *kafka.Writer
+
+	mu      sync.Mutex
+	readers []*kafka.Reader
+}
+
+var (
+	_ UsagePublisher = (*Kafka)(nil)
+	_ UsageConsumer  = (*Kafka)(nil)
+)
+
+func NewKafka(brokers []string, topic, groupId string) *Kafka {
+	return &Kafka{
+		brokers: brokers,
+		topic:   topic,
+		groupId: groupId,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// Publish already hands over a batch, do not wait for more
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+func (k *Kafka) Publish(ctx context.Context, msgs ...Message) error {
+	kmsgs := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		headers := make([]kafka.Header, 0, len(msg.Headers))
+		for key, v := range msg.Headers {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(v)})
+		}
+		kmsgs[i] = kafka.Message{
+			Key:     []byte(msg.Key),
+			Value:   msg.Body,
+			Headers: headers,
+			Time:    msg.Timestamp,
+		}
+	}
+	err := k.writer.WriteMessages(ctx, kmsgs...)
+	var werrs kafka.WriteErrors
+	if errors.As(err, &werrs) {
+		var failed []int
+		for i, err := range werrs {
+			if err != nil {
+				failed = append(failed, i)
+			}
+		}
+		return &PublishError{Failed: failed, Err: err}
+	}
+	return err
+}
+
+func (k *Kafka) Ready(ctx context.Context) error {
+	var errs []error
+	for _, broker := range k.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// kafkaRetryDelay is how long a message nacked with requeue waits before it is
+// delivered again.
+const kafkaRetryDelay = time.Second
+
+// Consume ignores prefetch, the reader fetches ahead on its own. A Nack with
+// requeue delivers the message again after kafkaRetryDelay within the same
+// session. Its offset is not committed until it is acked, so it is fetched
+// again after a restart even when later messages of the partition were acked.
+func (k *Kafka) Consume(ctx context.Context, prefetch int, handle func(d Delivery)) error {
+	for ctx.Err() == nil {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:        k.brokers,
+			GroupID:        k.groupId,
+			Topic:          k.topic,
+			CommitInterval: time.Second,
+		})
+		// acks of the last session may arrive after Consume returns, Close closes it
+		k.mu.Lock()
+		k.readers = append(k.readers, reader)
+		k.mu.Unlock()
+
+		if err := k.consumeSession(ctx, reader, handle); err != nil {
+			slog.Error("Kafka consumer session failed, restarting", "error", err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+		}
+	}
+	return nil
+}
+
+func (k *Kafka) consumeSession(ctx context.Context, reader *kafka.Reader, handle func(d Delivery)) error {
+	done := make(chan struct{})
+	defer close(done)
+	var stale atomic.Bool
+
+	fetched := make(chan kafka.Message)
+	fetchErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := reader.FetchMessage(ctx)
+			if err != nil {
+				fetchErr <- err
+				return
+			}
+			select {
+			case fetched <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	offsets := newKafkaOffsets()
+	retries := make(chan *kafkaInflight)
+	for {
+		var m *kafkaInflight
+		select {
+		case msg := <-fetched:
+			m = offsets.add(msg)
+		case m = <-retries:
+		case err := <-fetchErr:
+			if ctx.Err() != nil {
+				return nil
+			}
+			// the new session fetches again from the last committed offsets
+			stale.Store(true)
+			k.closeReader(reader)
+			return err
+		}
+
+		headers := make(map[string]string, len(m.msg.Headers))
+		for _, h := range m.msg.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		commit := func() error {
+			if stale.Load() {
+				// redelivered by the new session
+				return nil
+			}
+			last, ok := offsets.settle(m)
+			if !ok {
+				return nil
+			}
+			return reader.CommitMessages(context.Background(), last)
+		}
+		handle(Delivery{
+			Message: Message{
+				Key:       string(m.msg.Key),
+				Body:      m.msg.Value,
+				Headers:   headers,
+				Timestamp: m.msg.Time,
+			},
+			ack: commit,
+			nack: func(requeue bool) error {
+				if !requeue {
+					return commit()
+				}
+				time.AfterFunc(kafkaRetryDelay, func() {
+					select {
+					case retries <- m:
+					case <-done:
+					}
+				})
+				return nil
+			},
+		})
+	}
+}
+
+// kafkaOffsets tracks the unsettled messages of a session per partition in
+// fetch order.
+type kafkaOffsets struct {
+	mu         sync.Mutex
+	partitions map[int][]*kafkaInflight
+}
+
+type kafkaInflight struct {
+	msg     kafka.Message
+	settled bool
+}
+
+func newKafkaOffsets() *kafkaOffsets {
+	return &kafkaOffsets{partitions: map[int][]*kafkaInflight{}}
+}
+
+func (o *kafkaOffsets) add(msg kafka.Message) *kafkaInflight {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m := &kafkaInflight{msg: msg}
+	o.partitions[msg.Partition] = append(o.partitions[msg.Partition], m)
+	return m
+}
+
+// settle marks m settled. It returns the last message of the settled messages
+// at the head of the partition, the one whose offset can be committed, and
+// false while an earlier message is still unsettled.
+func (o *kafkaOffsets) settle(m *kafkaInflight) (kafka.Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if m.settled {
+		return kafka.Message{}, false
+	}
+	m.settled = true
+
+	inflight := o.partitions[m.msg.Partition]
+	n := 0
+	for n < len(inflight) && inflight[n].settled {
+		n++
+	}
+	if n == 0 {
+		return kafka.Message{}, false
+	}
+	last := inflight[n-1].msg
+	o.partitions[m.msg.Partition] = inflight[n:]
+	return last, true
+}
+
+func (k *Kafka) closeReader(reader *kafka.Reader) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, r := range k.readers {
+		if r == reader {
+			k.readers = append(k.readers[:i], k.readers[i+1:]...)
+			break
+		}
+	}
+	if err := reader.Close(); err != nil {
+		slog.Error("Failed to close Kafka reader", "error", err)
+	}
+}
+
+// Close commits the pending offsets and closes the writer.
+func (k *Kafka) Close() error {
+	k.mu.Lock()
+	readers := k.readers
+	k.readers = nil
+	k.mu.Unlock()
+
+	var errs []error
+	for _, r := range readers {
+		errs = append(errs, r.Close())
+	}
+	errs = append(errs, k.writer.Close())
+	return errors.Join(errs...)
+}
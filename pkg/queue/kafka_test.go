@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaOffsets_settle(t *testing.T) {
+	t.Parallel()
+
+	o := newKafkaOffsets()
+	m0 := o.add(kafka.Message{Partition: 0, Offset: 10})
+	m1 := o.add(kafka.Message{Partition: 0, Offset: 11})
+	m2 := o.add(kafka.Message{Partition: 0, Offset: 12})
+	other := o.add(kafka.Message{Partition: 1, Offset: 5})
+
+	// m0 is nacked and stays unsettled, later acks must not commit past it
+	_, ok := o.settle(m1)
+	assert.False(t, ok)
+	_, ok = o.settle(m2)
+	assert.False(t, ok)
+
+	last, ok := o.settle(other)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), last.Offset)
+
+	last, ok = o.settle(m0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), last.Offset)
+
+	_, ok = o.settle(m0)
+	assert.False(t, ok, "settling twice commits nothing")
+	assert.Empty(t, o.partitions[0])
+}
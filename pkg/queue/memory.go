@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is an in-process queue for tests and the dev command, where the
+// provider API and the worker run in one binary. Messages are lost on exit.
+type Memory struct {
+	messages chan Message
+
+	mu      sync.Mutex
+	settled int
+}
+
+var (
+	_ UsagePublisher = (*Memory)(nil)
+	_ UsageConsumer  = (*Memory)(nil)
+)
+
+func NewMemory(size int) *Memory {
+	return &Memory{
+		messages: make(chan Message, size),
+	}
+}
+
+// Publish blocks while the queue is full.
+func (m *Memory) Publish(ctx context.Context, msgs ...Message) error {
+	for i, msg := range msgs {
+		select {
+		case m.messages <- msg:
+		case <-ctx.Done():
+			if i == 0 {
+				return ctx.Err()
+			}
+			failed := make([]int, 0, len(msgs)-i)
+			for j := i; j < len(msgs); j++ {
+				failed = append(failed, j)
+			}
+			return &PublishError{Failed: failed, Err: ctx.Err()}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Ready(ctx context.Context) error {
+	return nil
+}
+
+// Len returns the number of queued messages.
+func (m *Memory) Len() int {
+	return len(m.messages)
+}
+
+// Settled returns the number of acked or nacked deliveries.
+func (m *Memory) Settled() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settled
+}
+
+func (m *Memory) Consume(ctx context.Context, prefetch int, handle func(d Delivery)) error {
+	inflight := make(chan struct{}, prefetch)
+	for {
+		select {
+		case inflight <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case msg := <-m.messages:
+			var once sync.Once
+			settle := func(requeue bool) error {
+				once.Do(func() {
+					m.mu.Lock()
+					m.settled++
+					m.mu.Unlock()
+					if requeue {
+						// the publishing side may be gone, do not block the caller
+						go func() { m.messages <- msg }()
+					}
+					<-inflight
+				})
+				return nil
+			}
+			handle(Delivery{
+				Message: msg,
+				ack:     func() error { return settle(false) },
+				nack:    settle,
+			})
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_Consume(t *testing.T) {
+	t.Parallel()
+
+	m := NewMemory(10)
+	require.NoError(t, m.Publish(context.Background(),
+		Message{Key: "1", Body: []byte("a")},
+		Message{Key: "2", Body: []byte("b")},
+		Message{Key: "3", Body: []byte("c")},
+	))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	var pending []Delivery
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Consume(ctx, 2, func(d Delivery) {
+			got = append(got, string(d.Body))
+			pending = append(pending, d)
+			if len(got) == 2 {
+				cancel()
+			}
+		})
+	}()
+	<-done
+
+	assert.Equal(t, []string{"a", "b"}, got, "prefetch bounds unsettled deliveries")
+	assert.Equal(t, 1, m.Len())
+
+	require.NoError(t, pending[0].Ack())
+	require.NoError(t, pending[1].Nack(true))
+	assert.Equal(t, 2, m.Settled())
+	assert.Eventually(t, func() bool { return m.Len() == 2 }, time.Second, time.Millisecond, "nacked message is requeued")
+}
+
+func TestMemory_Publish_full(t *testing.T) {
+	t.Parallel()
+
+	m := NewMemory(1)
+	require.NoError(t, m.Publish(context.Background(), Message{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.Publish(ctx, Message{}), context.DeadlineExceeded)
+}
+
+func TestMemory_Publish_partly(t *testing.T) {
+	t.Parallel()
+
+	m := NewMemory(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := m.Publish(ctx, Message{Key: "1"}, Message{Key: "2"}, Message{Key: "3"}, Message{Key: "4"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []int{2, 3}, Failed(err, 4))
+}
+
+func TestFailed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want []int
+	}{
+		{name: "no error", err: nil, want: nil},
+		{name: "partly", err: fmt.Errorf("publish: %w", &PublishError{Failed: []int{1}, Err: errors.New("nacked")}), want: []int{1}},
+		{name: "other error", err: errors.New("closed"), want: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, Failed(tt.err, 3))
+		})
+	}
+}
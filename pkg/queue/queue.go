@@ -0,0 +1,86 @@
+// Package queue carries access logs from the provider API to the worker over
+// RabbitMQ, Kafka or an in-process channel.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message is one access log in transit.
+type Message struct {
+	// Key orders and partitions messages on Kafka, access logs use the account id.
+	Key  string
+	Body []byte
+	// Headers carry the trace context of the request.
+	Headers   map[string]string
+	Timestamp time.Time
+}
+
+// Delivery is a consumed message that must be settled with Ack or Nack.
+type Delivery struct {
+	Message
+
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+func (d Delivery) Ack() error {
+	return d.ack()
+}
+
+// Nack rejects the message. With requeue it is delivered again, possibly
+// together with later messages of the same consumer.
+func (d Delivery) Nack(requeue bool) error {
+	return d.nack(requeue)
+}
+
+type UsagePublisher interface {
+	// Publish returns once the broker has accepted all msgs. When it fails after
+	// accepting some of them, the error is a *PublishError.
+	Publish(ctx context.Context, msgs ...Message) error
+	// Ready reports whether the broker is reachable.
+	Ready(ctx context.Context) error
+}
+
+// PublishError is returned by Publish when the broker accepted only some of the messages.
+type PublishError struct {
+	// Failed are the indexes of the messages that may not have been accepted, ascending.
+	Failed []int
+	Err    error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("%s (%d messages failed)", e.Err, len(e.Failed))
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// Failed returns the indexes of the n messages of a Publish that returned err which may
+// not have been accepted: those of a *PublishError, otherwise all of them.
+func Failed(err error, n int) []int {
+	if err == nil {
+		return nil
+	}
+	var pe *PublishError
+	if errors.As(err, &pe) {
+		return pe.Failed
+	}
+	failed := make([]int, n)
+	for i := range failed {
+		failed[i] = i
+	}
+	return failed
+}
+
+type UsageConsumer interface {
+	// Consume calls handle for every message until ctx is cancelled and returns
+	// after the messages already received were handled. At most prefetch
+	// messages are unsettled at a time where the broker supports it.
+	Consume(ctx context.Context, prefetch int, handle func(d Delivery)) error
+	Ready(ctx context.Context) error
+}
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/streadway/amqp"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+)
+
+// RabbitMQ publishes to and consumes from a durable queue.
+type RabbitMQ struct {
+	mqConn      *rabbitmq.Conn
+	queue       string
+	consumerTag string
+}
+
+var (
+	_ UsagePublisher = (*RabbitMQ)(nil)
+	_ UsageConsumer  = (*RabbitMQ)(nil)
+)
+
+// NewRabbitMQ declares queue on mqConn, consumerTag names the consumer of Consume.
+func NewRabbitMQ(mqConn *rabbitmq.Conn, queue, consumerTag string) (*RabbitMQ, error) {
+	if err := mqConn.Declare(rabbitmq.DurableQueue(queue)); err != nil {
+		return nil, err
+	}
+	return &RabbitMQ{
+		mqConn:      mqConn,
+		queue:       queue,
+		consumerTag: consumerTag,
+	}, nil
+}
+
+func (r *RabbitMQ) Publish(ctx context.Context, msgs ...Message) error {
+	publishings := make([]amqp.Publishing, len(msgs))
+	for i, msg := range msgs {
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+		publishings[i] = amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         msg.Body,
+			DeliveryMode: amqp.Persistent,
+			Headers:      headers,
+			Timestamp:    msg.Timestamp,
+		}
+	}
+	err := r.mqConn.PublishBatch(ctx, "", r.queue, publishings)
+	var pe *rabbitmq.PublishError
+	if errors.As(err, &pe) {
+		return &PublishError{Failed: pe.Unconfirmed, Err: err}
+	}
+	return err
+}
+
+func (r *RabbitMQ) Ready(ctx context.Context) error {
+	if !r.mqConn.IsOpen() {
+		return errors.New("amqp connection closed")
+	}
+	return nil
+}
+
+func (r *RabbitMQ) Consume(ctx context.Context, prefetch int, handle func(d Delivery)) error {
+	return r.mqConn.Consume(ctx, rabbitmq.ConsumeOptions{
+		Queue:    r.queue,
+		Tag:      r.consumerTag,
+		Prefetch: prefetch,
+	}, func(d amqp.Delivery) {
+		headers := make(map[string]string, len(d.Headers))
+		for k, v := range d.Headers {
+			if s, ok := v.(string); ok {
+				headers[k] = s
+			}
+		}
+		handle(Delivery{
+			Message: Message{
+				Body:      d.Body,
+				Headers:   headers,
+				Timestamp: d.Timestamp,
+			},
+			ack: func() error {
+				return d.Ack(false)
+			},
+			nack: func(requeue bool) error {
+				return d.Nack(false, requeue)
+			},
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package rabbitmq
+
+import (
+	"github.com/streadway/amqp"
+)
+
+// Connection is the part of *amqp.Connection used by Conn.
+type Connection interface {
+	Channel() (Channel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	IsClosed() bool
+	Close() error
+}
+
+// Channel is the part of *amqp.Channel used by Conn and its callers.
+type Channel interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Close() error
+}
+
+// Dialer opens a broker connection. Tests replace it with a fake broker.
+type Dialer func(url string) (Connection, error)
+
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c *amqpConnection) Channel() (Channel, error) {
+	return c.Connection.Channel()
+}
+
+func DialAMQP(url string) (Connection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &amqpConnection{Connection: conn}, nil
+}
+
+// DurableQueue declares a durable queue. Pass it to Conn.Declare.
+func DurableQueue(name string) func(ch Channel) error {
+	return func(ch Channel) error {
+		_, err := ch.QueueDeclare(
+			name,
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		return err
+	}
+}
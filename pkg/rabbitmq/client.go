@@ -1,38 +1,262 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
 	"github.com/streadway/amqp"
 )
 
+var ErrClosed = errors.New("rabbitmq: connection closed")
+
+// Conn manages a broker connection. It reconnects when the connection is lost,
+// redeclares the topology, pools publisher channels and resubscribes consumers.
 type Conn struct {
-	Conn    *amqp.Connection
-	Channel *amqp.Channel
+	url        string
+	dial       Dialer
+	poolSize   int
+	newBackOff func() backoff.BackOff
+
+	mu         sync.Mutex
+	conn       Connection
+	generation uint64
+	// connected is closed while conn is usable and replaced when it is lost
+	connected chan struct{}
+	topology  []func(ch Channel) error
+	channels  []trackedChannel
+
+	slots chan struct{}
+	idle  chan *publisherChannel
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewConn(queueUrl string) (*Conn, error) {
-	conn, err := amqp.Dial(queueUrl)
-	if err != nil {
+// trackedChannel is a consumer channel and the generation of the connection it was opened on.
+type trackedChannel struct {
+	ch         Channel
+	generation uint64
+}
+
+type Option func(c *Conn)
+
+func WithDialer(dial Dialer) Option {
+	return func(c *Conn) {
+		c.dial = dial
+	}
+}
+
+// WithPublisherPoolSize sets how many channels publish concurrently.
+func WithPublisherPoolSize(n int) Option {
+	return func(c *Conn) {
+		c.poolSize = n
+	}
+}
+
+func WithReconnectBackOff(newBackOff func() backoff.BackOff) Option {
+	return func(c *Conn) {
+		c.newBackOff = newBackOff
+	}
+}
+
+// NewConn connects to url. Later connection losses are recovered in the background.
+func NewConn(url string, opts ...Option) (*Conn, error) {
+	c := &Conn{
+		url:      url,
+		dial:     DialAMQP,
+		poolSize: 8,
+		newBackOff: func() backoff.BackOff {
+			b := backoff.NewExponentialBackOff()
+			b.MaxInterval = 30 * time.Second
+			b.MaxElapsedTime = 0
+			return b
+		},
+		connected: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.slots = make(chan struct{}, c.poolSize)
+	c.idle = make(chan *publisherChannel, c.poolSize)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	if err := c.connect(); err != nil {
+		c.cancel()
 		return nil, err
 	}
+	return c, nil
+}
 
-	channel, err := conn.Channel()
+func (c *Conn) connect() error {
+	conn, err := c.dial(c.url)
 	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.declare(conn, c.topology...); err != nil {
 		conn.Close()
-		return nil, err
+		return err
+	}
+
+	c.conn = conn
+	c.generation++
+	close(c.connected)
+	// channels of the lost connection were closed with it
+	c.channels = slices.DeleteFunc(c.channels, func(t trackedChannel) bool {
+		return t.generation != c.generation
+	})
+
+	go c.watch(conn, conn.NotifyClose(make(chan *amqp.Error, 1)))
+	return nil
+}
+
+func (c *Conn) watch(conn Connection, notifyClose chan *amqp.Error) {
+	err := <-notifyClose
+	if c.ctx.Err() != nil {
+		return
 	}
-	channel.Qos(1, 0, false) // Set QoS to ensure fair dispatch
+	slog.Error("RabbitMQ connection lost, reconnecting", "error", err)
 
-	return &Conn{
-		Conn:    conn,
-		Channel: channel,
-	}, nil
+	c.mu.Lock()
+	if c.conn == conn {
+		c.connected = make(chan struct{})
+	}
+	c.mu.Unlock()
+
+	if err := backoff.RetryNotify(
+		c.connect,
+		backoff.WithContext(c.newBackOff(), c.ctx),
+		func(err error, d time.Duration) {
+			slog.Error("Failed to reconnect to RabbitMQ", "error", err, "retryIn", d)
+		},
+	); err != nil {
+		return
+	}
+	slog.Info("Reconnected to RabbitMQ")
+}
+
+// declare runs the topology on a short-lived channel of conn.
+func (c *Conn) declare(conn Connection, topology ...func(ch Channel) error) error {
+	if len(topology) == 0 {
+		return nil
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	for _, fn := range topology {
+		if err := fn(ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Declare runs fn now and again after every reconnect, e.g. DurableQueue.
+func (c *Conn) Declare(fn func(ch Channel) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.topology = append(c.topology, fn)
+	if !c.isConnected() {
+		// declared by the reconnect
+		return nil
+	}
+	return c.declare(c.conn, fn)
+}
+
+// current waits for a usable connection.
+func (c *Conn) current(ctx context.Context) (Connection, uint64, error) {
+	for {
+		c.mu.Lock()
+		conn, generation, connected := c.conn, c.generation, c.connected
+		c.mu.Unlock()
+
+		select {
+		case <-connected:
+			if !conn.IsClosed() {
+				return conn, generation, nil
+			}
+			// lost but not yet noticed by watch
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			}
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-c.ctx.Done():
+			return nil, 0, ErrClosed
+		}
+	}
+}
+
+func (c *Conn) isConnected() bool {
+	select {
+	case <-c.connected:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsOpen reports whether the connection is usable right now.
+func (c *Conn) IsOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx.Err() == nil && c.isConnected() && !c.conn.IsClosed()
+}
+
+// track closes ch, opened on the connection of generation, with the connection.
+// Consumer channels outlive Consume for late acks.
+func (c *Conn) track(ch Channel, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		// lost while subscribing
+		return
+	}
+	c.channels = append(c.channels, trackedChannel{ch: ch, generation: generation})
+}
+
+// untrack forgets ch once it closed, e.g. before resubscribing.
+func (c *Conn) untrack(ch Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels = slices.DeleteFunc(c.channels, func(t trackedChannel) bool {
+		return t.ch == ch
+	})
 }
 
 func (c *Conn) Close() {
-	if c.Channel != nil {
-		c.Channel.Close()
+	c.cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		select {
+		case pc := <-c.idle:
+			pc.ch.Close()
+			continue
+		default:
+		}
+		break
+	}
+	for _, t := range c.channels {
+		t.ch.Close()
 	}
-	if c.Conn != nil {
-		c.Conn.Close()
+	if c.conn != nil {
+		c.conn.Close()
 	}
 }
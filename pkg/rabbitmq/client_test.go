@@ -0,0 +1,164 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConn(t *testing.T, broker *fakeBroker, opts ...Option) *Conn {
+	t.Helper()
+
+	opts = append([]Option{
+		WithDialer(broker.dial),
+		WithReconnectBackOff(func() backoff.BackOff {
+			return backoff.NewConstantBackOff(5 * time.Millisecond)
+		}),
+	}, opts...)
+	conn, err := NewConn("amqp://fake", opts...)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func TestConn_Publish(t *testing.T) {
+	t.Parallel()
+
+	broker := newFakeBroker()
+	conn := newTestConn(t, broker, WithPublisherPoolSize(2))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := conn.Publish(context.Background(), "", "q", amqp.Publishing{Body: []byte(fmt.Sprint(i))})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	_, _, confirms, published := broker.stats()
+	assert.Equal(t, 20, published)
+	assert.LessOrEqual(t, confirms, 2, "publisher channels are reused")
+}
+
+func TestConn_PublishBatch(t *testing.T) {
+	t.Parallel()
+
+	broker := newFakeBroker()
+	conn := newTestConn(t, broker)
+
+	msgs := make([]amqp.Publishing, 50)
+	require.NoError(t, conn.PublishBatch(context.Background(), "", "q", msgs))
+	require.NoError(t, conn.PublishBatch(context.Background(), "", "q", msgs))
+
+	_, _, confirms, published := broker.stats()
+	assert.Equal(t, 100, published)
+	assert.Equal(t, 1, confirms, "the channel is reused after all confirms arrived")
+}
+
+func TestConn_Publish_nacked(t *testing.T) {
+	t.Parallel()
+
+	broker := newFakeBroker()
+	broker.nack = true
+	conn := newTestConn(t, broker)
+
+	err := conn.Publish(context.Background(), "", "q", amqp.Publishing{})
+	assert.ErrorIs(t, err, ErrNacked)
+}
+
+func TestConn_PublishBatch_partlyNacked(t *testing.T) {
+	t.Parallel()
+
+	broker := newFakeBroker()
+	broker.nackIf = func(msg amqp.Publishing) bool { return string(msg.Body) == "1" || string(msg.Body) == "3" }
+	conn := newTestConn(t, broker)
+
+	msgs := make([]amqp.Publishing, 5)
+	for i := range msgs {
+		msgs[i].Body = []byte(fmt.Sprint(i))
+	}
+	err := conn.PublishBatch(context.Background(), "", "q", msgs)
+	assert.ErrorIs(t, err, ErrNacked)
+
+	var pe *PublishError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, []int{1, 3}, pe.Unconfirmed)
+}
+
+func TestConn_reconnect(t *testing.T) {
+	t.Parallel()
+
+	broker := newFakeBroker()
+	conn := newTestConn(t, broker)
+	require.NoError(t, conn.Declare(DurableQueue("q")))
+	require.NoError(t, conn.Publish(context.Background(), "", "q", amqp.Publishing{}))
+	assert.True(t, conn.IsOpen())
+
+	broker.setDown(true)
+	broker.drop()
+	assert.Eventually(t, func() bool { return !conn.IsOpen() }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, conn.Publish(ctx, "", "q", amqp.Publishing{}), context.DeadlineExceeded)
+
+	broker.setDown(false)
+	assert.Eventually(t, conn.IsOpen, time.Second, time.Millisecond)
+	require.NoError(t, conn.Publish(context.Background(), "", "q", amqp.Publishing{}))
+
+	dials, declared, confirms, published := broker.stats()
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, map[string]int{"q": 2}, declared, "topology is redeclared")
+	assert.Equal(t, 2, confirms, "stale publisher channel is replaced")
+	assert.Equal(t, 2, published)
+}
+
+func TestConn_Consume_resubscribes(t *testing.T) {
+	t.Parallel()
+
+	broker := newFakeBroker()
+	conn := newTestConn(t, broker)
+
+	received := make(chan string, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- conn.Consume(ctx, ConsumeOptions{Queue: "q", Tag: "test", Prefetch: 1}, func(d amqp.Delivery) {
+			received <- string(d.Body)
+		})
+	}()
+
+	require.Eventually(t, func() bool { return broker.deliver("before") }, time.Second, time.Millisecond)
+	assert.Equal(t, "before", <-received)
+
+	broker.drop()
+	require.Eventually(t, func() bool { return broker.deliver("after") }, time.Second, time.Millisecond)
+	assert.Equal(t, "after", <-received)
+
+	for range 3 {
+		broker.drop()
+		require.Eventually(t, func() bool { return broker.deliver("again") }, time.Second, time.Millisecond)
+		assert.Equal(t, "again", <-received)
+	}
+	conn.mu.Lock()
+	assert.Len(t, conn.channels, 1, "channels of lost connections are not kept")
+	conn.mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Consume did not return after cancel")
+	}
+}
@@ -0,0 +1,102 @@
+package rabbitmq
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/streadway/amqp"
+)
+
+type ConsumeOptions struct {
+	Queue    string
+	Tag      string
+	Prefetch int
+}
+
+// Consume calls handle for every delivery until ctx is cancelled and
+// resubscribes whenever the channel or the connection is lost.
+// On cancellation the consumer is cancelled and Consume returns after the
+// deliveries already sent were handled. Their channel stays open until Close,
+// so that handle may ack them later.
+func (c *Conn) Consume(ctx context.Context, opts ConsumeOptions, handle func(d amqp.Delivery)) error {
+	b := c.newBackOff()
+	for {
+		conn, generation, err := c.current(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		deliveries, ch, err := subscribe(conn, opts)
+		if err != nil {
+			slog.Error("Failed to subscribe, retrying", "queue", opts.Queue, "error", err)
+			if !sleep(ctx, b.NextBackOff()) {
+				return nil
+			}
+			continue
+		}
+		c.track(ch, generation)
+		b.Reset()
+		slog.Info("Consumer subscribed", "queue", opts.Queue, "tag", opts.Tag)
+
+		stop := context.AfterFunc(ctx, func() {
+			slog.Info("Cancelling consumer", "tag", opts.Tag)
+			if err := ch.Cancel(opts.Tag, false); err != nil {
+				slog.Error("Failed to cancel consumer", "error", err)
+			}
+		})
+		for d := range deliveries {
+			handle(d)
+		}
+		stop()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.untrack(ch)
+		slog.Warn("Consumer channel closed, resubscribing", "queue", opts.Queue)
+	}
+}
+
+func subscribe(conn Connection, opts ConsumeOptions) (<-chan amqp.Delivery, Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ch.Qos(opts.Prefetch, 0, false); err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	deliveries, err := ch.Consume(
+		opts.Queue,
+		opts.Tag,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	return deliveries, ch, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d == backoff.Stop {
+		d = time.Second
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
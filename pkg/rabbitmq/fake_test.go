@@ -0,0 +1,260 @@
+package rabbitmq
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeBroker is an in-memory broker with a single queue namespace. It can drop
+// the connection and refuse dials to simulate an outage.
+type fakeBroker struct {
+	mu        sync.Mutex
+	down      bool
+	dials     int
+	declared  map[string]int
+	confirms  int
+	nack      bool
+	nackIf    func(amqp.Publishing) bool
+	published []amqp.Publishing
+	conn      *fakeConnection
+	consumer  *fakeChannel
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{declared: map[string]int{}}
+}
+
+func (b *fakeBroker) dial(url string) (Connection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.down {
+		return nil, errors.New("connection refused")
+	}
+	b.dials++
+	b.conn = &fakeConnection{broker: b}
+	return b.conn, nil
+}
+
+func (b *fakeBroker) setDown(down bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.down = down
+}
+
+// drop closes the current connection as if the broker went away.
+func (b *fakeBroker) drop() {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	conn.shutdown(&amqp.Error{Code: amqp.ConnectionForced, Reason: "broker restarted"})
+}
+
+// deliver sends body to the current consumer, it reports false without one.
+func (b *fakeBroker) deliver(body string) bool {
+	b.mu.Lock()
+	ch := b.consumer
+	b.mu.Unlock()
+	if ch == nil {
+		return false
+	}
+	return ch.deliver(amqp.Delivery{Body: []byte(body)})
+}
+
+func (b *fakeBroker) stats() (dials int, declared map[string]int, confirms int, published int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	declared = map[string]int{}
+	for k, v := range b.declared {
+		declared[k] = v
+	}
+	return b.dials, declared, b.confirms, len(b.published)
+}
+
+type fakeConnection struct {
+	broker *fakeBroker
+
+	mu       sync.Mutex
+	closed   bool
+	notify   []chan *amqp.Error
+	channels []*fakeChannel
+}
+
+func (c *fakeConnection) Channel() (Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &fakeChannel{broker: c.broker}
+	c.channels = append(c.channels, ch)
+	return ch, nil
+}
+
+func (c *fakeConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = append(c.notify, receiver)
+	return receiver
+}
+
+func (c *fakeConnection) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *fakeConnection) Close() error {
+	c.shutdown(nil)
+	return nil
+}
+
+func (c *fakeConnection) shutdown(err *amqp.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, ch := range c.channels {
+		ch.Close()
+	}
+	for _, receiver := range c.notify {
+		if err != nil {
+			receiver <- err
+		}
+		close(receiver)
+	}
+}
+
+type fakeChannel struct {
+	broker *fakeBroker
+
+	mu         sync.Mutex
+	closed     bool
+	seq        uint64
+	confirms   []chan amqp.Confirmation
+	notify     []chan *amqp.Error
+	deliveries chan amqp.Delivery
+}
+
+func (ch *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	ch.broker.declared[name]++
+	return amqp.Queue{Name: name}, nil
+}
+
+func (ch *fakeChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return nil
+}
+
+func (ch *fakeChannel) Confirm(noWait bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	ch.broker.confirms++
+	return nil
+}
+
+func (ch *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.confirms = append(ch.confirms, confirm)
+	return confirm
+}
+
+func (ch *fakeChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.notify = append(ch.notify, receiver)
+	return receiver
+}
+
+func (ch *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.broker.mu.Lock()
+	ch.broker.published = append(ch.broker.published, msg)
+	ack := !ch.broker.nack && (ch.broker.nackIf == nil || !ch.broker.nackIf(msg))
+	ch.broker.mu.Unlock()
+
+	ch.seq++
+	for _, confirm := range ch.confirms {
+		confirm <- amqp.Confirmation{DeliveryTag: ch.seq, Ack: ack}
+	}
+	return nil
+}
+
+func (ch *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch.deliveries = make(chan amqp.Delivery, 16)
+
+	ch.broker.mu.Lock()
+	ch.broker.consumer = ch
+	ch.broker.mu.Unlock()
+	return ch.deliveries, nil
+}
+
+func (ch *fakeChannel) deliver(d amqp.Delivery) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.closed || ch.deliveries == nil {
+		return false
+	}
+	ch.deliveries <- d
+	return true
+}
+
+func (ch *fakeChannel) Cancel(consumer string, noWait bool) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.stopConsuming()
+	return nil
+}
+
+func (ch *fakeChannel) stopConsuming() {
+	if ch.deliveries == nil {
+		return
+	}
+	close(ch.deliveries)
+	ch.deliveries = nil
+
+	ch.broker.mu.Lock()
+	if ch.broker.consumer == ch {
+		ch.broker.consumer = nil
+	}
+	ch.broker.mu.Unlock()
+}
+
+func (ch *fakeChannel) Close() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.closed {
+		return nil
+	}
+	ch.closed = true
+	ch.stopConsuming()
+	for _, confirm := range ch.confirms {
+		close(confirm)
+	}
+	for _, receiver := range ch.notify {
+		close(receiver)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	ErrNacked        = errors.New("rabbitmq: publish nacked by broker")
+	errChannelClosed = errors.New("rabbitmq: channel closed before confirm")
+)
+
+// publisherChannel is a channel in confirm mode used by one publisher at a time,
+// so that each publish is followed by exactly one confirmation.
+type publisherChannel struct {
+	ch         Channel
+	confirms   chan amqp.Confirmation
+	generation uint64
+}
+
+// Publish sends msg on a pooled channel and waits for the broker confirm.
+// amqp.Channel is not safe for concurrent publishing, the pool serializes it.
+func (c *Conn) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	return c.PublishBatch(ctx, exchange, key, []amqp.Publishing{msg})
+}
+
+// PublishError is returned by PublishBatch when some msgs may not have been delivered.
+type PublishError struct {
+	// Unconfirmed are the indexes of the msgs that were nacked or not confirmed, ascending.
+	// The other msgs were confirmed by the broker.
+	Unconfirmed []int
+	Err         error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("%s (%d unconfirmed)", e.Err, len(e.Unconfirmed))
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// PublishBatch sends msgs on one pooled channel and waits for all confirms.
+// When some of msgs were sent before it failed, the error is a *PublishError.
+func (c *Conn) PublishBatch(ctx context.Context, exchange, key string, msgs []amqp.Publishing) error {
+	pc, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	reusable := false
+	defer func() {
+		c.release(pc, reusable)
+	}()
+
+	// a channel confirms in publish order and is reused only when nothing is pending,
+	// so the n-th confirm received is the one of msgs[n]
+	sent, received := 0, 0
+	var nacked []int
+	receive := func(confirm amqp.Confirmation, ok bool) error {
+		if !ok {
+			return errChannelClosed
+		}
+		if !confirm.Ack {
+			nacked = append(nacked, received)
+		}
+		received++
+		return nil
+	}
+	fail := func(err error) error {
+		unconfirmed := nacked
+		for i := received; i < len(msgs); i++ {
+			unconfirmed = append(unconfirmed, i)
+		}
+		return &PublishError{Unconfirmed: unconfirmed, Err: err}
+	}
+
+	for _, msg := range msgs {
+		if err := pc.ch.Publish(exchange, key, false, false, msg); err != nil {
+			return fail(err)
+		}
+		sent++
+
+		// take the confirms that already arrived, the library blocks on a full confirm channel
+	drain:
+		for received < sent {
+			select {
+			case confirm, ok := <-pc.confirms:
+				if err := receive(confirm, ok); err != nil {
+					return fail(err)
+				}
+			default:
+				break drain
+			}
+		}
+	}
+
+	for received < sent {
+		select {
+		case confirm, ok := <-pc.confirms:
+			if err := receive(confirm, ok); err != nil {
+				return fail(err)
+			}
+		case <-ctx.Done():
+			// confirms are still pending, the channel must not be reused
+			return fail(ctx.Err())
+		}
+	}
+
+	reusable = true
+	if len(nacked) > 0 {
+		return fail(ErrNacked)
+	}
+	return nil
+}
+
+func (c *Conn) acquire(ctx context.Context) (*publisherChannel, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	conn, generation, err := c.current(ctx)
+	if err != nil {
+		<-c.slots
+		return nil, err
+	}
+
+	for {
+		select {
+		case pc := <-c.idle:
+			if pc.generation == generation {
+				return pc, nil
+			}
+			pc.ch.Close()
+			continue
+		default:
+		}
+		break
+	}
+
+	pc, err := openPublisher(conn, generation)
+	if err != nil {
+		<-c.slots
+		return nil, err
+	}
+	return pc, nil
+}
+
+func openPublisher(conn Connection, generation uint64) (*publisherChannel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
+	return &publisherChannel{
+		ch:         ch,
+		confirms:   ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		generation: generation,
+	}, nil
+}
+
+func (c *Conn) release(pc *publisherChannel, reusable bool) {
+	defer func() {
+		<-c.slots
+	}()
+
+	if reusable && c.ctx.Err() == nil {
+		select {
+		case c.idle <- pc:
+			return
+		default:
+		}
+	}
+	pc.ch.Close()
+}
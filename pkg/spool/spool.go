@@ -0,0 +1,299 @@
+// Package spool is a local write-ahead log of opaque records, split into
+// append-only segment files and replayed oldest first.
+package spool
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrFull is returned by Append when the record would exceed the disk budget.
+var ErrFull = errors.New("spool: full")
+
+const (
+	// headerSize is the record length and the CRC-32 of the record, both big endian.
+	headerSize    = 8
+	segmentSuffix = ".seg"
+	cursorFile    = "cursor"
+)
+
+type Spool struct {
+	dir             string
+	maxSegmentBytes int64
+	maxBytes        int64
+
+	// replayMu serializes Replay
+	replayMu sync.Mutex
+
+	mu         sync.Mutex
+	segments   []uint64
+	sizes      map[uint64]int64
+	active     *os.File
+	activeId   uint64
+	activeSize int64
+	size       int64
+	// cursor is the offset in the oldest segment up to which records were replayed
+	cursor int64
+}
+
+// Open opens or creates the spool in dir. Segments are rotated at
+// maxSegmentBytes and all segments together are kept below maxBytes.
+func Open(dir string, maxSegmentBytes, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Spool{
+		dir:             dir,
+		maxSegmentBytes: maxSegmentBytes,
+		maxBytes:        maxBytes,
+		sizes:           map[uint64]int64{},
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		id, ok := parseSegmentName(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, id)
+		s.sizes[id] = info.Size()
+		s.size += info.Size()
+	}
+	slices.Sort(s.segments)
+
+	cursor, err := s.readCursor()
+	if err != nil {
+		return nil, err
+	}
+	s.cursor = cursor
+	return s, nil
+}
+
+func segmentName(id uint64) string {
+	return fmt.Sprintf("%020d%s", id, segmentSuffix)
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasSuffix(name, segmentSuffix) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+	return id, err == nil
+}
+
+// Size returns the bytes on disk, including records already replayed from the oldest segment.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Append durably writes record. It returns ErrFull when the disk budget is exhausted.
+func (s *Spool) Append(record []byte) error {
+	n := int64(headerSize + len(record))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+n > s.maxBytes {
+		return ErrFull
+	}
+	if s.active == nil || (s.activeSize > 0 && s.activeSize+n > s.maxSegmentBytes) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, n)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(record))
+	copy(buf[headerSize:], record)
+
+	if _, err := s.active.Write(buf); err != nil {
+		return err
+	}
+	if err := s.active.Sync(); err != nil {
+		return err
+	}
+	s.activeSize += n
+	s.sizes[s.activeId] += n
+	s.size += n
+	return nil
+}
+
+// rotate seals the active segment and starts a new one. A spool never appends to
+// a segment from a previous run, whose tail may be torn.
+func (s *Spool) rotate() error {
+	if err := s.seal(); err != nil {
+		return err
+	}
+
+	var id uint64
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1] + 1
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, segmentName(id)), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segments = append(s.segments, id)
+	s.sizes[id] = 0
+	s.active = f
+	s.activeId = id
+	s.activeSize = 0
+	return nil
+}
+
+func (s *Spool) seal() error {
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
+
+// Replay calls fn for every record, oldest first, and deletes segments once all
+// of their records were replayed. When fn fails or ctx is done, Replay stops and
+// the next call resumes at the failed record. A crash during Replay may replay
+// records of the current segment again.
+func (s *Spool) Replay(ctx context.Context, fn func(record []byte) error) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		id := s.segments[0]
+		if s.active != nil && id == s.activeId {
+			if s.activeSize == 0 {
+				s.mu.Unlock()
+				return nil
+			}
+			// new records go to the next segment
+			if err := s.seal(); err != nil {
+				s.mu.Unlock()
+				return err
+			}
+		}
+		offset := s.cursor
+		s.mu.Unlock()
+
+		offset, err := s.replaySegment(ctx, id, offset, fn)
+		if err != nil {
+			if cerr := s.saveCursor(offset); cerr != nil {
+				slog.Error("Failed to save spool cursor", "error", cerr)
+			}
+			return err
+		}
+		if err := s.remove(id); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Spool) replaySegment(ctx context.Context, id uint64, offset int64, fn func(record []byte) error) (int64, error) {
+	f, err := os.Open(filepath.Join(s.dir, segmentName(id)))
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	r := bufio.NewReader(f)
+	header := make([]byte, headerSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return offset, err
+		}
+
+		if _, err := io.ReadFull(r, header); err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Warn("Skipping torn spool record", "segment", segmentName(id), "offset", offset)
+			}
+			return offset, nil
+		}
+		record := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(r, record); err != nil || crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:8]) {
+			// only the tail of a segment written before a crash can be torn
+			slog.Warn("Skipping torn spool record", "segment", segmentName(id), "offset", offset)
+			return offset, nil
+		}
+
+		if err := fn(record); err != nil {
+			return offset, err
+		}
+		offset += int64(headerSize + len(record))
+	}
+}
+
+func (s *Spool) remove(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(s.dir, segmentName(id))); err != nil {
+		return err
+	}
+	s.segments = s.segments[1:]
+	s.size -= s.sizes[id]
+	delete(s.sizes, id)
+	s.cursor = 0
+	if err := os.Remove(filepath.Join(s.dir, cursorFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Spool) saveCursor(offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cursor = offset
+	tmp := filepath.Join(s.dir, cursorFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, cursorFile))
+}
+
+func (s *Spool) readCursor() (int64, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, cursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// Close closes the active segment. Its records are replayed after the next Open.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seal()
+}
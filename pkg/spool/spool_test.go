@@ -0,0 +1,110 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func replayAll(t *testing.T, s *Spool) []string {
+	t.Helper()
+
+	var got []string
+	require.NoError(t, s.Replay(context.Background(), func(record []byte) error {
+		got = append(got, string(record))
+		return nil
+	}))
+	return got
+}
+
+func TestSpool_Replay(t *testing.T) {
+	t.Parallel()
+
+	s, err := Open(t.TempDir(), 32, 1024)
+	require.NoError(t, err)
+
+	var want []string
+	for i := range 10 {
+		want = append(want, fmt.Sprintf("record-%d", i))
+		require.NoError(t, s.Append([]byte(want[i])))
+	}
+	assert.Len(t, s.segments, 5, "segments rotate at maxSegmentBytes")
+
+	assert.Equal(t, want, replayAll(t, s))
+	assert.Zero(t, s.Size())
+	assert.Empty(t, replayAll(t, s))
+
+	require.NoError(t, s.Append([]byte("after replay")))
+	assert.Equal(t, []string{"after replay"}, replayAll(t, s))
+}
+
+func TestSpool_Replay_resumesAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s, err := Open(dir, 1024, 1024)
+	require.NoError(t, err)
+	for _, r := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Append([]byte(r)))
+	}
+
+	var got []string
+	errBroker := errors.New("broker down")
+	err = s.Replay(context.Background(), func(record []byte) error {
+		if string(record) == "b" {
+			return errBroker
+		}
+		got = append(got, string(record))
+		return nil
+	})
+	assert.ErrorIs(t, err, errBroker)
+	assert.Equal(t, []string{"a"}, got)
+	require.NoError(t, s.Close())
+
+	// the cursor survives a restart
+	s, err = Open(dir, 1024, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, replayAll(t, s))
+}
+
+func TestSpool_Append_full(t *testing.T) {
+	t.Parallel()
+
+	s, err := Open(t.TempDir(), 1024, 2*(headerSize+4))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Append([]byte("1234")))
+	require.NoError(t, s.Append([]byte("5678")))
+	assert.ErrorIs(t, s.Append([]byte("9")), ErrFull)
+
+	assert.Equal(t, []string{"1234", "5678"}, replayAll(t, s))
+	assert.NoError(t, s.Append([]byte("9")), "replay frees the budget")
+}
+
+func TestSpool_Replay_tornTail(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s, err := Open(dir, 1024, 1024)
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]byte("complete")))
+	require.NoError(t, s.Append([]byte("torn")))
+	require.NoError(t, s.Close())
+
+	// simulate a crash in the middle of the last write
+	path := filepath.Join(dir, segmentName(0))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-2))
+
+	s, err = Open(dir, 1024, 1024)
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]byte("next run")))
+	assert.Equal(t, []string{"complete", "next run"}, replayAll(t, s))
+}
@@ -2,13 +2,38 @@ package types
 
 import "time"
 
+// AccessLogQueue is the queue ApiAccessLog messages are published to.
+const AccessLogQueue = "api1_queue"
+
+// ApiAccessLog is an authenticated request to a metered handler. Fields added later are zero in messages and
+// Parquet objects written before them.
 type ApiAccessLog struct {
-	AccountId  int64     `json:"account_id"`
-	Timestamp  time.Time `json:"timestamp"`
-	ClientIP   string    `json:"client_ip"`
-	Path       string    `json:"path"`
-	Method     string    `json:"method"`
-	StatusCode int       `json:"status_code"`
-	Latency    int64     `json:"latency"`
-	UserAgent  string    `json:"user_agent"`
+	AccountId     int64     `json:"account_id"`
+	Timestamp     time.Time `json:"timestamp"`
+	ClientIP      string    `json:"client_ip"`
+	Path          string    `json:"path"`
+	Method        string    `json:"method"`
+	StatusCode    int       `json:"status_code"`
+	Latency       int64     `json:"latency"` // nanoseconds
+	UserAgent     string    `json:"user_agent"`
+	RequestId     string    `json:"request_id,omitempty"`
+	ApiKeyId      int64     `json:"api_key_id,omitempty"`
+	Region        string    `json:"region,omitempty"`
+	ResponseBytes int64     `json:"response_bytes,omitempty"`
+	Meter         string    `json:"meter,omitempty"`
+	Usage         int64     `json:"usage,omitempty"`
+	NonBillable   bool      `json:"non_billable,omitempty"` // logged but not billed, e.g. a 4xx of a meter billing 2xx
+}
+
+// BillableUsage returns the usage the request is billed for, zero if it is not billable.
+// Logs written before meters were introduced bill one call. It is never negative, so it
+// converts to the unsigned usage columns.
+func (l *ApiAccessLog) BillableUsage() int64 {
+	switch {
+	case l.NonBillable:
+		return 0
+	case l.Meter == "":
+		return 1
+	}
+	return max(l.Usage, 0)
 }
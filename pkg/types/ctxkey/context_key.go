@@ -2,6 +2,9 @@ package ctxkey
 
 type (
 	ApiKey    struct{}
+	ApiKeyId  struct{}
 	AccountId struct{}
+	Usage     struct{}
 	Txn       struct{}
+	Actor     struct{}
 )
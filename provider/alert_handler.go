@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/szks-repo/usage-based-billing-sample/alert"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+type AlertHandler struct {
+	dbConn *sql.DB
+}
+
+func NewAlertHandler(dbConn *sql.DB) *AlertHandler {
+	return &AlertHandler{
+		dbConn: dbConn,
+	}
+}
+
+type alertView struct {
+	ID         uint64     `json:"id"`
+	Kind       alert.Kind `json:"kind"`
+	Limit      float64    `json:"limit"`
+	Thresholds []uint16   `json:"thresholds"`
+}
+
+func newAlertView(a *dto.UsageAlert) *alertView {
+	thresholds, _ := alert.ParseThresholds(a.Thresholds)
+	return &alertView{
+		ID:         a.ID,
+		Kind:       alert.Kind(a.Kind),
+		Limit:      a.LimitValue,
+		Thresholds: thresholds,
+	}
+}
+
+func (h *AlertHandler) HandleListAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	alerts, err := dto.UsageAlertByAccountID(ctx, h.dbConn, uint64(accountId))
+	if err != nil {
+		slog.Error("Failed to UsageAlertByAccountID", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to list alerts")
+		return
+	}
+
+	views := make([]*alertView, 0, len(alerts))
+	for _, a := range alerts {
+		views = append(views, newAlertView(a))
+	}
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"alerts": views})
+}
+
+// HandleCreateAlert accepts {"kind": "quota"|"budget", "limit": 100000, "thresholds": [50, 80, 100]}.
+func (h *AlertHandler) HandleCreateAlert(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	var req alertView
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httplib.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	kind, err := alert.ParseKind(string(req.Kind))
+	if err != nil {
+		httplib.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Limit <= 0 {
+		httplib.WriteError(w, http.StatusBadRequest, "limit must be positive")
+		return
+	}
+	if len(req.Thresholds) == 0 {
+		req.Thresholds = []uint16{50, 80, 100}
+	}
+	thresholds, err := alert.ParseThresholds(alert.FormatThresholds(req.Thresholds))
+	if err != nil {
+		httplib.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	t := now.FromContext(ctx)
+	created := &dto.UsageAlert{
+		AccountID:  uint64(accountId),
+		Kind:       string(kind),
+		LimitValue: req.Limit,
+		Thresholds: alert.FormatThresholds(thresholds),
+		CreatedAt:  t,
+		UpdatedAt:  t,
+	}
+	if err := created.Insert(ctx, h.dbConn); err != nil {
+		slog.Error("Failed to insert usage_alert", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to create alert")
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusCreated, newAlertView(created))
+}
+
+func (h *AlertHandler) HandleDeleteAlert(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httplib.WriteError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	a, err := dto.UsageAlertByID(ctx, h.dbConn, id)
+	if err != nil || a.AccountID != uint64(accountId) {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			httplib.WriteError(w, http.StatusNotFound, "alert not found")
+			return
+		}
+		slog.Error("Failed to UsageAlertByID", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to delete alert")
+		return
+	}
+	if err := a.Delete(ctx, h.dbConn); err != nil {
+		slog.Error("Failed to delete usage_alert", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to delete alert")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
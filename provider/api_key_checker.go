@@ -7,23 +7,32 @@ import (
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
 )
 
+// ApiKey is an active api key.
+type ApiKey struct {
+	Id        int64
+	AccountId int64
+}
+
 // todo add layer
 type ApiKeyChecker interface {
-	Check(ctx context.Context, apiKey string) (int64, error)
+	Check(ctx context.Context, apiKey string) (ApiKey, error)
 }
 
 type apiKeyChecker struct {
 	dbConn       *sql.DB
-	lruCache     *expirable.LRU[string, int64]
+	lruCache     *expirable.LRU[string, ApiKey]
 	cacheExpires time.Duration
 }
 
 func NewApiKeyChecker(
 	dbConn *sql.DB,
-	lruCache *expirable.LRU[string, int64],
+	lruCache *expirable.LRU[string, ApiKey],
 	cacheExpires time.Duration,
 ) ApiKeyChecker {
 	return &apiKeyChecker{
@@ -33,30 +42,38 @@ func NewApiKeyChecker(
 	}
 }
 
-func (c *apiKeyChecker) Check(ctx context.Context, apiKey string) (int64, error) {
+func (c *apiKeyChecker) Check(ctx context.Context, apiKey string) (_ ApiKey, err error) {
 	slog.Info("apiKeyChecker.Check", "apiKey", apiKey)
 
+	ctx, span := tracing.Tracer().Start(ctx, "apiKeyChecker.Check")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	if fromCache, ok := c.lruCache.Get(apiKey); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
 		return fromCache, nil
 	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
 
 	now := now.FromContext(ctx)
-	var accountId int64
+	var key ApiKey
 	var expiredAt time.Time
-	row := c.dbConn.QueryRowContext(ctx, `SELECT a.account_id, a.expired_at FROM active_api_key a WHERE a.api_key = ? AND a.expired_at > ?`, apiKey, now)
+	row := c.dbConn.QueryRowContext(ctx, `SELECT a.id, a.account_id, a.expired_at FROM active_api_key a WHERE a.api_key = ? AND a.expired_at > ?`, apiKey, now)
 	if err := row.Scan(
-		&accountId,
+		&key.Id,
+		&key.AccountId,
 		&expiredAt,
 	); err != nil {
-		return 0, err
+		return ApiKey{}, err
 	}
 
-	slog.Debug("query end", "accountId", accountId, "expiredAt", expiredAt)
+	slog.Debug("query end", "accountId", key.AccountId, "expiredAt", expiredAt)
 	if c.shouldCache(now, expiredAt, c.cacheExpires) {
-		c.lruCache.Add(apiKey, accountId)
+		c.lruCache.Add(apiKey, key)
 	}
 
-	return accountId, nil
+	return key, nil
 }
 
 func (c *apiKeyChecker) shouldCache(now, expriedAt time.Time, cacheExpires time.Duration) bool {
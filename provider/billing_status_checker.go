@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/szks-repo/usage-based-billing-sample/budget"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
+)
+
+// BillingStatusChecker returns the billing status kept up to date by budget.Evaluator.
+type BillingStatusChecker interface {
+	Check(ctx context.Context, accountId int64) (budget.Status, error)
+}
+
+type billingStatusChecker struct {
+	dbConn   *sql.DB
+	lruCache *expirable.LRU[int64, budget.Status]
+}
+
+// NewBillingStatusChecker caches statuses in lruCache, whose TTL bounds how long
+// a suspension or its lift takes to reach the API.
+func NewBillingStatusChecker(
+	dbConn *sql.DB,
+	lruCache *expirable.LRU[int64, budget.Status],
+) BillingStatusChecker {
+	return &billingStatusChecker{
+		dbConn:   dbConn,
+		lruCache: lruCache,
+	}
+}
+
+func (c *billingStatusChecker) Check(ctx context.Context, accountId int64) (_ budget.Status, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "billingStatusChecker.Check")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if fromCache, ok := c.lruCache.Get(accountId); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return fromCache, nil
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	var s string
+	if err := c.dbConn.QueryRowContext(
+		ctx,
+		"SELECT status FROM account_billing_status WHERE account_id = ?",
+		accountId,
+	).Scan(&s); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+		s = string(budget.StatusActive)
+	}
+
+	status, err := budget.ParseStatus(s)
+	if err != nil {
+		return "", err
+	}
+
+	c.lruCache.Add(accountId, status)
+	return status, nil
+}
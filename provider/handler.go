@@ -13,12 +13,6 @@ func NewApiHandler() *ApiHandler {
 	return &ApiHandler{}
 }
 
-func (h *ApiHandler) HandleHelth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "healthy"}`))
-}
-
 func (h *ApiHandler) HandleApi1(w http.ResponseWriter, r *http.Request) {
 	time.Sleep(time.Millisecond * time.Duration(rand.IntN(50)))
 
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice"
+	"github.com/szks-repo/usage-based-billing-sample/invoice/render"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+type InvoicePreviewer interface {
+	Preview(ctx context.Context, accountId uint64) (*invoice.Preview, error)
+}
+
+type InvoiceHandler struct {
+	previewer InvoicePreviewer
+	dbConn    *sql.DB
+	renderer  *render.Renderer
+}
+
+func NewInvoiceHandler(previewer InvoicePreviewer, dbConn *sql.DB, renderer *render.Renderer) *InvoiceHandler {
+	return &InvoiceHandler{
+		previewer: previewer,
+		dbConn:    dbConn,
+		renderer:  renderer,
+	}
+}
+
+func (h *InvoiceHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	preview, err := h.previewer.Preview(ctx, uint64(accountId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httplib.WriteError(w, http.StatusNotFound, "no active subscription")
+			return
+		}
+		slog.Error("Failed to preview invoice", "accountId", accountId, "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to preview invoice")
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, preview)
+}
+
+func (h *InvoiceHandler) HandleListInvoices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	invoices, err := dto.InvoiceByAccountID(ctx, h.dbConn, uint64(accountId))
+	if err != nil {
+		slog.Error("Failed to InvoiceByAccountID", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to list invoices")
+		return
+	}
+	if invoices == nil {
+		invoices = []*dto.Invoice{}
+	}
+	httplib.WriteJSON(w, http.StatusOK, map[string]any{"invoices": invoices})
+}
+
+// HandleGetInvoice returns the document of an invoice of the account, ?format=pdf (default) or html.
+func (h *InvoiceHandler) HandleGetInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		httplib.WriteError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	format := render.FormatPDF
+	if v := r.URL.Query().Get("format"); v != "" {
+		if format, err = render.ParseFormat(v); err != nil {
+			httplib.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	doc, err := render.Load(ctx, h.dbConn, id)
+	if err != nil || doc.AccountId != uint64(accountId) {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			httplib.WriteError(w, http.StatusNotFound, "invoice not found")
+			return
+		}
+		slog.Error("Failed to load invoice", "invoiceId", id, "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to render invoice")
+		return
+	}
+
+	// documents are small, render them fully so that a failure is still a proper error
+	var buf bytes.Buffer
+	if err := h.renderer.Render(&buf, doc, format); err != nil {
+		slog.Error("Failed to render invoice", "invoiceId", id, "format", format, "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to render invoice")
+		return
+	}
+	disposition := "attachment"
+	if format == render.FormatHTML {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`%s; filename="%s.%s"`, disposition, doc.Number(), format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+// Unit is the quantity a meter bills.
+type Unit string
+
+const (
+	// UnitCalls bills Weight per request.
+	UnitCalls Unit = "calls"
+	// UnitBytes bills the response bytes, one unit per started Per bytes.
+	UnitBytes Unit = "bytes"
+	// UnitCompute bills the latency, one compute unit per started Per nanoseconds.
+	UnitCompute Unit = "compute"
+	// UnitCustom bills the quantity reported by the handler with ReportUsage, one unit per started Per.
+	UnitCustom Unit = "custom"
+)
+
+// Meter computes the usage of a request. The usage is multiplied by Weight.
+// Only requests whose status is in Billable are billed; the others are logged as non-billable.
+type Meter struct {
+	Name     string
+	Unit     Unit
+	Per      int64
+	Weight   int64
+	Billable StatusClasses
+}
+
+// StatusClasses is a set of HTTP status classes, bit n standing for nxx.
+type StatusClasses uint8
+
+// Success is the default billable status class, 2xx.
+const Success StatusClasses = 1 << 2
+
+// Contains reports whether the class of status is in c.
+func (c StatusClasses) Contains(status int) bool {
+	class := status / 100
+	return class >= 1 && class <= 5 && c&(1<<class) != 0
+}
+
+// parseStatusClasses parses classes joined by "+", e.g. 2xx+4xx.
+func parseStatusClasses(s string) (StatusClasses, error) {
+	var c StatusClasses
+	for class := range strings.SplitSeq(s, "+") {
+		n, ok := strings.CutSuffix(class, "xx")
+		if !ok || len(n) != 1 || n[0] < '1' || n[0] > '5' {
+			return 0, fmt.Errorf("status class must be 1xx to 5xx: %q", class)
+		}
+		c |= 1 << (n[0] - '0')
+	}
+	return c, nil
+}
+
+// usage returns the billed usage of a request.
+func (m Meter) usage(latency time.Duration, responseBytes, reported int64) int64 {
+	var quantity int64
+	switch m.Unit {
+	case UnitCalls:
+		quantity = 1
+	case UnitBytes:
+		quantity = responseBytes
+	case UnitCompute:
+		quantity = int64(latency)
+	case UnitCustom:
+		quantity = max(reported, 0)
+	}
+	per := max(m.Per, 1)
+	return (quantity + per - 1) / per * max(m.Weight, 1)
+}
+
+// Meters are the meters of the billed handlers by name.
+type Meters map[string]Meter
+
+// Get returns the meter of a handler, which bills one call per 2xx response unless configured otherwise.
+func (m Meters) Get(name string) Meter {
+	if meter, ok := m[name]; ok {
+		return meter
+	}
+	return Meter{Name: name, Unit: UnitCalls, Per: 1, Weight: 1, Billable: Success}
+}
+
+// ParseMeters parses comma separated meters of the form name=unit[/per][*weight][@classes], e.g.
+//
+//	api1=calls*2,api2=compute/100ms,download=bytes/1024,llm=custom,lookup=calls@2xx+4xx
+//
+// per is a duration for compute and a number for bytes and custom; calls takes no per.
+// classes are the billable status classes joined by "+", 2xx by default.
+func ParseMeters(s string) (Meters, error) {
+	meters := make(Meters)
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid meter %q: want name=unit[/per][*weight][@classes]", entry)
+		}
+		if _, ok := meters[name]; ok {
+			return nil, fmt.Errorf("duplicate meter %q", name)
+		}
+		meter, err := parseMeter(name, spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meter %q: %w", entry, err)
+		}
+		meters[name] = meter
+	}
+	return meters, nil
+}
+
+func parseMeter(name, spec string) (Meter, error) {
+	m := Meter{Name: name, Per: 1, Weight: 1, Billable: Success}
+
+	spec, classes, ok := strings.Cut(spec, "@")
+	if ok {
+		billable, err := parseStatusClasses(classes)
+		if err != nil {
+			return Meter{}, err
+		}
+		m.Billable = billable
+	}
+
+	spec, weight, ok := strings.Cut(spec, "*")
+	if ok {
+		w, err := strconv.ParseInt(weight, 10, 64)
+		if err != nil || w < 1 {
+			return Meter{}, fmt.Errorf("weight must be a positive integer: %q", weight)
+		}
+		m.Weight = w
+	}
+
+	unit, per, hasPer := strings.Cut(spec, "/")
+	m.Unit = Unit(unit)
+	switch m.Unit {
+	case UnitCalls:
+		if hasPer {
+			return Meter{}, fmt.Errorf("%s takes no per", m.Unit)
+		}
+	case UnitCompute:
+		if !hasPer {
+			return Meter{}, fmt.Errorf("%s needs a per duration, e.g. compute/100ms", m.Unit)
+		}
+		d, err := time.ParseDuration(per)
+		if err != nil || d <= 0 {
+			return Meter{}, fmt.Errorf("per must be a positive duration: %q", per)
+		}
+		m.Per = int64(d)
+	case UnitBytes, UnitCustom:
+		if hasPer {
+			p, err := strconv.ParseInt(per, 10, 64)
+			if err != nil || p < 1 {
+				return Meter{}, fmt.Errorf("per must be a positive integer: %q", per)
+			}
+			m.Per = p
+		}
+	default:
+		return Meter{}, fmt.Errorf("unknown unit %q: want calls, bytes, compute or custom", unit)
+	}
+	return m, nil
+}
+
+// ErrNegativeUsage is returned by ReportUsage for a quantity below zero.
+var ErrNegativeUsage = errors.New("reported usage must not be negative")
+
+// ReportUsage adds quantity, e.g. the tokens processed, to the usage of a request
+// billed by a custom meter. It does nothing outside a billed request.
+// A negative quantity is not added and returns ErrNegativeUsage, which the handler
+// answers with 400 Bad Request.
+func ReportUsage(ctx context.Context, quantity int64) error {
+	if quantity < 0 {
+		return fmt.Errorf("%w: %d", ErrNegativeUsage, quantity)
+	}
+	if reported, ok := ctx.Value(ctxkey.Usage{}).(*atomic.Int64); ok {
+		reported.Add(quantity)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+)
+
+func TestParseMeters(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseMeters(" api1=calls*2, api2=compute/100ms,download=bytes/1024,llm=custom,lookup=calls*3@2xx+4xx")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, got, Meters{
+		"api1":     {Name: "api1", Unit: UnitCalls, Per: 1, Weight: 2, Billable: Success},
+		"api2":     {Name: "api2", Unit: UnitCompute, Per: int64(100 * time.Millisecond), Weight: 1, Billable: Success},
+		"download": {Name: "download", Unit: UnitBytes, Per: 1024, Weight: 1, Billable: Success},
+		"llm":      {Name: "llm", Unit: UnitCustom, Per: 1, Weight: 1, Billable: Success},
+		"lookup":   {Name: "lookup", Unit: UnitCalls, Per: 1, Weight: 3, Billable: 1<<2 | 1<<4},
+	})
+	assert.DeepEqual(t, got.Get("other"), Meter{Name: "other", Unit: UnitCalls, Per: 1, Weight: 1, Billable: Success})
+
+	for _, s := range []string{
+		"api1",
+		"=calls",
+		"api1=calls,api1=bytes",
+		"api1=requests",
+		"api1=calls/2",
+		"api1=calls*0",
+		"api1=compute",
+		"api1=compute/0s",
+		"api1=bytes/kib",
+		"api1=calls@",
+		"api1=calls@404",
+		"api1=calls@6xx",
+	} {
+		_, err := ParseMeters(s)
+		assert.Error(t, err)
+	}
+}
+
+func TestMeter_usage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		meter         Meter
+		latency       time.Duration
+		responseBytes int64
+		reported      int64
+		want          int64
+	}{
+		{meter: Meter{Unit: UnitCalls}, want: 1},
+		{meter: Meter{Unit: UnitCalls, Weight: 3}, responseBytes: 100, want: 3},
+		{meter: Meter{Unit: UnitBytes, Per: 1024}, responseBytes: 1025, want: 2},
+		{meter: Meter{Unit: UnitBytes, Per: 1024}, responseBytes: 0, want: 0},
+		{meter: Meter{Unit: UnitCompute, Per: int64(100 * time.Millisecond)}, latency: 250 * time.Millisecond, want: 3},
+		{meter: Meter{Unit: UnitCompute, Per: int64(100 * time.Millisecond), Weight: 2}, latency: time.Millisecond, want: 2},
+		{meter: Meter{Unit: UnitCustom, Per: 1000}, reported: 1500, want: 2},
+		{meter: Meter{Unit: UnitCustom}, reported: 42, want: 42},
+		{meter: Meter{Unit: UnitCustom}, reported: -42, want: 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.meter.usage(tt.latency, tt.responseBytes, tt.reported))
+	}
+}
+
+func TestStatusClasses_Contains(t *testing.T) {
+	t.Parallel()
+
+	c := Success | 1<<4
+	for status, want := range map[int]bool{99: false, 100: false, 200: true, 299: true, 302: false, 404: true, 500: false, 600: false} {
+		assert.Equal(t, want, c.Contains(status))
+	}
+}
+
+func TestReportUsage_notBilled(t *testing.T) {
+	t.Parallel()
+
+	// must not panic outside a billed request
+	assert.NoError(t, ReportUsage(context.Background(), 1))
+}
+
+func TestReportUsage_negative(t *testing.T) {
+	t.Parallel()
+
+	var reported atomic.Int64
+	ctx := context.WithValue(context.Background(), ctxkey.Usage{}, &reported)
+	assert.NoError(t, ReportUsage(ctx, 3))
+	assert.That(t, errors.Is(ReportUsage(ctx, -5), ErrNegativeUsage))
+	assert.Equal(t, int64(3), reported.Load())
+}
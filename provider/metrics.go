@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// requestsTotal is labelled by billing status in place of an account tier, which the
+	// schema does not have: active, throttled or suspended tells apart the accounts whose
+	// traffic is billed, slowed down or rejected, with a bounded cardinality.
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "provider_requests_total",
+		Help: "Billed API requests by route, status code and billing status of the account.",
+	}, []string{"path", "status", "billing_status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "provider_request_duration_seconds",
+		Help:    "Latency of billed API requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"path"})
+
+	billedUsageTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "provider_billed_usage_total",
+		Help: "Usage of billed requests by meter, in the unit of the meter.",
+	}, []string{"meter"})
+
+	publishRetriesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "provider_access_log_publish_retries_total",
+		Help: "Retried access log publishes to the broker.",
+	})
+
+	publishFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "provider_access_log_publish_failures_total",
+		Help: "Access logs of batches that could not be published to the broker after all retries.",
+	})
+
+	spooledTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "provider_access_log_spooled_total",
+		Help: "Access logs written to the local spool because the broker was unavailable or the publish queue was full.",
+	})
+
+	droppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "provider_access_log_dropped_total",
+		Help: "Access logs that will not be billed by reason: queue_full, no_spool, spool_failed or undecodable.",
+	}, []string{"reason"})
+
+	replayedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "provider_access_log_replayed_total",
+		Help: "Spooled access logs published to the broker after it recovered.",
+	})
+
+	publishQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "provider_access_log_publish_queue_depth",
+		Help: "Access logs waiting in memory to be published.",
+	})
+
+	publishBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "provider_access_log_publish_batch_size",
+		Help:    "Access logs per published batch.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 6),
+	})
+
+	spoolBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "provider_access_log_spool_bytes",
+		Help: "Disk usage of the access log spool.",
+	})
+)
+
+// observeRequest uses the route pattern instead of the raw path to bound the cardinality.
+func observeRequest(pattern string, statusCode int, billingStatus string, elapsed time.Duration) {
+	requestsTotal.WithLabelValues(pattern, strconv.Itoa(statusCode), billingStatus).Inc()
+	requestDuration.WithLabelValues(pattern).Observe(elapsed.Seconds())
+}
@@ -5,99 +5,211 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
-	"github.com/streadway/amqp"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/szks-repo/usage-based-billing-sample/budget"
 	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
-	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
 )
 
 type Middleware interface {
-	Wrap(next http.Handler) http.Handler
+	// Wrap authenticates the request and publishes its access log with the usage of meter for billing.
+	Wrap(meter Meter, next http.Handler) http.Handler
+	// Authenticate only checks the api key. Requests through it are not billed.
+	Authenticate(next http.Handler) http.Handler
 }
 
 type middleware struct {
-	apiKeyChecker ApiKeyChecker
-	mqConn        *rabbitmq.Conn
-	queue         amqp.Queue
+	apiKeyChecker        ApiKeyChecker
+	billingStatusChecker BillingStatusChecker
+	throttle             *throttle
+	region               string
+	publisher            *AccessLogPublisher
 }
 
+// NewMiddleware limits throttled accounts to throttleLimit billed requests per throttleWindow.
+// region is recorded in the access logs.
 func NewMiddleware(
 	apiKeyChecker ApiKeyChecker,
-	mqConn *rabbitmq.Conn,
-	queue amqp.Queue,
+	billingStatusChecker BillingStatusChecker,
+	throttleLimit int,
+	throttleWindow time.Duration,
+	region string,
+	publisher *AccessLogPublisher,
 ) Middleware {
 	return &middleware{
-		apiKeyChecker: apiKeyChecker,
-		mqConn:        mqConn,
-		queue:         queue,
+		apiKeyChecker:        apiKeyChecker,
+		billingStatusChecker: billingStatusChecker,
+		throttle:             newThrottle(throttleLimit, throttleWindow),
+		region:               region,
+		publisher:            publisher,
 	}
 }
 
-func (mw *middleware) Wrap(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := now.FromContext(r.Context())
+// requestIdHeader carries the id of a request. An id sent by the client is kept,
+// otherwise a new one is generated. It is returned in the response and recorded in the access log.
+const requestIdHeader = "X-Request-Id"
 
-		apiKey := r.Header.Get("x-api-key")
+func (mw *middleware) authenticate(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	apiKey := r.Header.Get("x-api-key")
 
-		accountId, err := mw.apiKeyChecker.Check(r.Context(), apiKey)
-		if err != nil {
-			slog.Info("Invalid api key", "apiKey", apiKey, "error", err)
-			http.Error(w, "Unauthorized: missing or invalid api key", http.StatusUnauthorized)
-			return
-		}
+	key, err := mw.apiKeyChecker.Check(r.Context(), apiKey)
+	if err != nil {
+		slog.Info("Invalid api key", "apiKey", apiKey, "error", err)
+		http.Error(w, "Unauthorized: missing or invalid api key", http.StatusUnauthorized)
+		return nil, false
+	}
 
-		ctx := context.WithValue(r.Context(), ctxkey.ApiKey{}, apiKey)
-		ctx = context.WithValue(ctx, ctxkey.AccountId{}, accountId)
+	ctx := context.WithValue(r.Context(), ctxkey.ApiKey{}, apiKey)
+	ctx = context.WithValue(ctx, ctxkey.ApiKeyId{}, key.Id)
+	ctx = context.WithValue(ctx, ctxkey.AccountId{}, key.AccountId)
+	return ctx, true
+}
 
-		w2 := httplib.NewResponseWriterWrapper(w)
+// checkBudget rejects requests of accounts restricted for exceeding their budget, see budget.Decide.
+// Errors fail open so that a billing status outage does not take the API down.
+func (mw *middleware) checkBudget(w http.ResponseWriter, r *http.Request, accountId int64, t time.Time) (budget.Status, bool) {
+	status, err := mw.billingStatusChecker.Check(r.Context(), accountId)
+	if err != nil {
+		slog.Error("Failed to check billing status", "accountId", accountId, "error", err)
+		return budget.StatusActive, true
+	}
 
-		next.ServeHTTP(w2, r.WithContext(ctx))
-		slog.Info("End main handler", "path", r.URL.Path, "satusCode", w2.StatusCode())
-		if w2.StatusCode() < 200 || w2.StatusCode() >= 300 {
-			return
+	switch status {
+	case budget.StatusSuspended:
+		http.Error(w, "Payment Required: account suspended for exceeding its budget", http.StatusPaymentRequired)
+		return status, false
+	case budget.StatusThrottled:
+		if !mw.throttle.Allow(accountId, t) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(mw.throttle.window.Seconds())))
+			http.Error(w, "Too Many Requests: account throttled for exceeding its budget", http.StatusTooManyRequests)
+			return status, false
 		}
+	}
+	return status, true
+}
 
-		ts := now.FromContext(ctx)
-		payload, err := json.Marshal(&types.ApiAccessLog{
-			AccountId:  accountId,
-			Timestamp:  ts,
-			ClientIP:   r.RemoteAddr,
-			Path:       r.URL.Path,
-			Method:     r.Method,
-			StatusCode: w2.StatusCode(),
-			Latency:    int64(time.Since(start)),
-			UserAgent:  r.UserAgent(),
-		})
-		if err != nil {
-			slog.Error("Failed to json.Marshal", "error", err)
+func (mw *middleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := mw.authenticate(w, r)
+		if !ok {
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (mw *middleware) Wrap(meter Meter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := now.FromContext(r.Context())
+
+		spanCtx, span := tracing.Tracer().Start(
+			otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header)),
+			r.Pattern,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", r.Pattern),
+			),
+		)
+		defer span.End()
+		r = r.WithContext(spanCtx)
+
+		requestId := r.Header.Get(requestIdHeader)
+		if requestId == "" {
+			requestId = uuid.Must(uuid.NewV7()).String()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+		span.SetAttributes(attribute.String("http.request.id", requestId))
 
-		if err := backoff.Retry(func() error {
-			return mw.mqConn.Channel.Publish(
-				"",            // exchange
-				mw.queue.Name, // routing key
-				false,         // mandatory
-				false,         // immediate
-				amqp.Publishing{
-					ContentType:  "application/json",
-					Body:         payload,
-					DeliveryMode: amqp.Persistent,
-					Headers: map[string]any{
-						"timestamp": ts,
-					},
-					Timestamp: ts,
-				},
+		w2 := httplib.NewResponseWriterWrapper(w)
+		billingStatus := "unauthenticated"
+		defer func() {
+			observeRequest(r.Pattern, w2.StatusCode(), billingStatus, time.Since(start))
+			span.SetAttributes(
+				attribute.Int("http.response.status_code", w2.StatusCode()),
+				attribute.String("billing.status", billingStatus),
 			)
-		}, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 5)); err != nil {
-			slog.Error("Failed to publish message to RabbitMQ", "error", err)
+			if w2.StatusCode() >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(w2.StatusCode()))
+			}
+		}()
+
+		ctx, ok := mw.authenticate(w2, r)
+		if !ok {
+			return
+		}
+		accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+		span.SetAttributes(attribute.Int64("account.id", accountId))
+		status, ok := mw.checkBudget(w2, r, accountId, start)
+		billingStatus = string(status)
+		if !ok {
+			// rejected by the billing status, logged for analytics but never billed
+			mw.logAccess(ctx, r, w2, meter, start, requestId, 0, false)
 			return
 		}
+
+		var reported atomic.Int64
+		ctx = context.WithValue(ctx, ctxkey.Usage{}, &reported)
+		next.ServeHTTP(w2, r.WithContext(ctx))
+		slog.Info("End main handler", "path", r.URL.Path, "satusCode", w2.StatusCode())
+
+		mw.logAccess(ctx, r, w2, meter, start, requestId, reported.Load(), meter.Billable.Contains(w2.StatusCode()))
 	})
 }
+
+// logAccess publishes the access log of an authenticated request. Every request is
+// logged; billable tells whether its usage is billed.
+func (mw *middleware) logAccess(
+	ctx context.Context,
+	r *http.Request,
+	w *httplib.ResponseWriterWrapper,
+	meter Meter,
+	start time.Time,
+	requestId string,
+	reported int64,
+	billable bool,
+) {
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+	ts := now.FromContext(ctx)
+	latency := time.Since(start)
+	usage := meter.usage(latency, int64(w.BytesWritten()), reported)
+	if billable {
+		billedUsageTotal.WithLabelValues(meter.Name).Add(float64(usage))
+	}
+	payload, err := json.Marshal(&types.ApiAccessLog{
+		AccountId:     accountId,
+		Timestamp:     ts,
+		ClientIP:      r.RemoteAddr,
+		Path:          r.URL.Path,
+		Method:        r.Method,
+		StatusCode:    w.StatusCode(),
+		Latency:       int64(latency),
+		UserAgent:     r.UserAgent(),
+		RequestId:     requestId,
+		ApiKeyId:      ctx.Value(ctxkey.ApiKeyId{}).(int64),
+		Region:        mw.region,
+		ResponseBytes: int64(w.BytesWritten()),
+		Meter:         meter.Name,
+		Usage:         usage,
+		NonBillable:   !billable,
+	})
+	if err != nil {
+		slog.Error("Failed to json.Marshal", "error", err)
+		return
+	}
+
+	mw.publisher.Enqueue(ctx, accountId, payload, ts)
+}
@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zeebo/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/budget"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+type stubApiKeyChecker map[string]int64
+
+func (c stubApiKeyChecker) Check(ctx context.Context, apiKey string) (ApiKey, error) {
+	if id, ok := c[apiKey]; ok {
+		return ApiKey{Id: id * 10, AccountId: id}, nil
+	}
+	return ApiKey{}, errors.New("not found")
+}
+
+type stubBillingStatusChecker map[int64]budget.Status
+
+func (c stubBillingStatusChecker) Check(ctx context.Context, accountId int64) (budget.Status, error) {
+	return c[accountId], nil
+}
+
+// newTestPublisher returns a started publisher to a memory queue.
+func newTestPublisher() (*AccessLogPublisher, *queue.Memory) {
+	q := queue.NewMemory(10)
+	publisher := NewAccessLogPublisher(q, "q", nil, AccessLogPublisherOptions{
+		QueueSize:     10,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+		Backpressure:  BackpressureBlock,
+	})
+	publisher.Start()
+	return publisher, q
+}
+
+// publishedLogs stops publisher and returns the n access logs it published to q.
+func publishedLogs(t *testing.T, publisher *AccessLogPublisher, q *queue.Memory, n int) []types.ApiAccessLog {
+	t.Helper()
+
+	assert.NoError(t, publisher.Stop(context.Background()))
+	assert.Equal(t, n, q.Len())
+
+	var logs []types.ApiAccessLog
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Consume(ctx, 10, func(d queue.Delivery) {
+		var l types.ApiAccessLog
+		assert.NoError(t, json.Unmarshal(d.Body, &l))
+		logs = append(logs, l)
+		if len(logs) == n {
+			cancel()
+		}
+	})
+	return logs
+}
+
+func TestMiddleware_Wrap_rejected(t *testing.T) {
+	t.Parallel()
+
+	publisher, q := newTestPublisher()
+	mw := NewMiddleware(
+		stubApiKeyChecker{"suspended": 1, "throttled": 2},
+		stubBillingStatusChecker{1: budget.StatusSuspended, 2: budget.StatusThrottled},
+		0,
+		time.Minute,
+		"ap-northeast-1",
+		publisher,
+	)
+	mux := http.NewServeMux()
+	mux.Handle("GET /test/rejected", mw.Wrap(Meters{}.Get("rejected"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not be called")
+	})))
+
+	tests := []struct {
+		apiKey        string
+		want          int
+		billingStatus string
+	}{
+		{apiKey: "unknown", want: http.StatusUnauthorized, billingStatus: "unauthenticated"},
+		{apiKey: "suspended", want: http.StatusPaymentRequired, billingStatus: "suspended"},
+		{apiKey: "throttled", want: http.StatusTooManyRequests, billingStatus: "throttled"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/test/rejected", nil)
+		req.Header.Set("x-api-key", tt.apiKey)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.want, rec.Code)
+		assert.Equal(t, 1.0, testutil.ToFloat64(requestsTotal.WithLabelValues(
+			"GET /test/rejected",
+			strconv.Itoa(tt.want),
+			tt.billingStatus,
+		)))
+	}
+
+	// unauthenticated requests have no account to log them for
+	logs := publishedLogs(t, publisher, q, 2)
+	for i, want := range []int{http.StatusPaymentRequired, http.StatusTooManyRequests} {
+		assert.Equal(t, want, logs[i].StatusCode)
+		assert.True(t, logs[i].NonBillable)
+		assert.Equal(t, int64(0), logs[i].BillableUsage())
+	}
+}
+
+func TestMiddleware_Wrap_accessLog(t *testing.T) {
+	t.Parallel()
+
+	publisher, q := newTestPublisher()
+	mw := NewMiddleware(
+		stubApiKeyChecker{"active": 3},
+		stubBillingStatusChecker{},
+		0,
+		time.Minute,
+		"ap-northeast-1",
+		publisher,
+	)
+	mux := http.NewServeMux()
+	mux.Handle("GET /test/billed", mw.Wrap(Meter{Name: "tokens", Unit: UnitCustom, Per: 10, Weight: 2, Billable: Success}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportUsage(r.Context(), 15)
+		ReportUsage(r.Context(), 10)
+		w.Write([]byte("hello"))
+	})))
+
+	var requestIds []string
+	for _, requestId := range []string{"req-1", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/test/billed", nil)
+		req.Header.Set("x-api-key", "active")
+		req.Header.Set(requestIdHeader, requestId)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		requestIds = append(requestIds, rec.Header().Get(requestIdHeader))
+	}
+	assert.Equal(t, "req-1", requestIds[0])
+	assert.That(t, requestIds[1] != "")
+
+	for i, l := range publishedLogs(t, publisher, q, 2) {
+		assert.Equal(t, requestIds[i], l.RequestId)
+		assert.Equal(t, int64(3), l.AccountId)
+		assert.Equal(t, int64(30), l.ApiKeyId)
+		assert.Equal(t, "ap-northeast-1", l.Region)
+		assert.Equal(t, int64(5), l.ResponseBytes)
+		assert.Equal(t, "tokens", l.Meter)
+		assert.Equal(t, int64(6), l.BillableUsage())
+	}
+}
+
+func TestMiddleware_Wrap_billableStatus(t *testing.T) {
+	t.Parallel()
+
+	meters, err := ParseMeters("lookup=calls@2xx+4xx")
+	assert.NoError(t, err)
+
+	publisher, q := newTestPublisher()
+	mw := NewMiddleware(stubApiKeyChecker{"active": 3}, stubBillingStatusChecker{}, 0, time.Minute, "", publisher)
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("GET /test/lookup", mw.Wrap(meters.Get("lookup"), notFound))
+	mux.Handle("GET /test/default", mw.Wrap(meters.Get("default"), notFound))
+	mux.Handle("GET /test/error", mw.Wrap(meters.Get("lookup"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})))
+
+	for _, path := range []string{"/test/lookup", "/test/default", "/test/error"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("x-api-key", "active")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var got []int64
+	for _, l := range publishedLogs(t, publisher, q, 3) {
+		got = append(got, l.BillableUsage())
+	}
+	assert.DeepEqual(t, got, []int64{1, 0, 0})
+}
+
+func TestMiddleware_Wrap_negativeUsage(t *testing.T) {
+	t.Parallel()
+
+	publisher, q := newTestPublisher()
+	mw := NewMiddleware(stubApiKeyChecker{"active": 3}, stubBillingStatusChecker{}, 0, time.Minute, "", publisher)
+	mux := http.NewServeMux()
+	mux.Handle("GET /test/billed", mw.Wrap(Meter{Name: "tokens", Unit: UnitCustom, Per: 1, Weight: 1, Billable: Success}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportUsage(r.Context(), 15)
+		if err := ReportUsage(r.Context(), -20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("hello"))
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/test/billed", nil)
+	req.Header.Set("x-api-key", "active")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	l := publishedLogs(t, publisher, q, 1)[0]
+	assert.Equal(t, int64(15), l.Usage)
+	assert.Equal(t, int64(0), l.BillableUsage())
+}
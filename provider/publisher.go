@@ -0,0 +1,286 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/spool"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
+)
+
+// BackpressurePolicy decides what Enqueue does when the publish queue is full.
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock waits for space, holding the request.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureDrop drops the access log, it is not billed.
+	BackpressureDrop BackpressurePolicy = "drop"
+	// BackpressureSpool appends the access log to the spool.
+	BackpressureSpool BackpressurePolicy = "spool"
+)
+
+func ParseBackpressurePolicy(s string) (BackpressurePolicy, error) {
+	switch p := BackpressurePolicy(s); p {
+	case BackpressureBlock, BackpressureDrop, BackpressureSpool:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown backpressure policy: %q", s)
+}
+
+// accessLogMessage is an access log waiting to be published. It is also the spool record.
+type accessLogMessage struct {
+	AccountId int64     `json:"account_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Headers carry the trace context of the request, so that the worker continues its trace.
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload json.RawMessage   `json:"payload"`
+
+	link trace.Link
+}
+
+func (m *accessLogMessage) message() queue.Message {
+	return queue.Message{
+		Key:       strconv.FormatInt(m.AccountId, 10),
+		Body:      m.Payload,
+		Headers:   m.Headers,
+		Timestamp: m.Timestamp,
+	}
+}
+
+type AccessLogPublisherOptions struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Backpressure  BackpressurePolicy
+}
+
+// AccessLogPublisher publishes access logs in batches off the request path, keyed by account.
+// Batches that fail after all retries are spooled.
+type AccessLogPublisher struct {
+	publisher     queue.UsagePublisher
+	queue         string
+	spool         *spool.Spool
+	batchSize     int
+	flushInterval time.Duration
+	backpressure  BackpressurePolicy
+
+	messages chan *accessLogMessage
+	// stopping unblocks Enqueue, drain tells run to publish what is left
+	stopping chan struct{}
+	drain    chan struct{}
+	done     chan struct{}
+
+	// mu guards stopped, Enqueue holds it while sending to messages
+	mu      sync.RWMutex
+	stopped bool
+
+	// ctx is cancelled when Stop times out, the remaining logs are spooled
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewAccessLogPublisher returns a publisher that spools to accessLogSpool, nil drops instead.
+// queueName only names the publish spans.
+func NewAccessLogPublisher(
+	publisher queue.UsagePublisher,
+	queueName string,
+	accessLogSpool *spool.Spool,
+	opts AccessLogPublisherOptions,
+) *AccessLogPublisher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AccessLogPublisher{
+		publisher:     publisher,
+		queue:         queueName,
+		spool:         accessLogSpool,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		backpressure:  opts.Backpressure,
+		messages:      make(chan *accessLogMessage, opts.QueueSize),
+		stopping:      make(chan struct{}),
+		drain:         make(chan struct{}),
+		done:          make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Start publishes enqueued access logs until Stop.
+func (p *AccessLogPublisher) Start() {
+	go p.run()
+}
+
+// Enqueue queues an access log of the request in ctx, applying the backpressure
+// policy when the queue is full.
+func (p *AccessLogPublisher) Enqueue(ctx context.Context, accountId int64, payload []byte, ts time.Time) {
+	m := &accessLogMessage{
+		AccountId: accountId,
+		Timestamp: ts,
+		Headers:   map[string]string{},
+		Payload:   payload,
+		link:      trace.LinkFromContext(ctx),
+	}
+	tracing.InjectHeaders(ctx, m.Headers)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		p.spoolAccessLog(m)
+		return
+	}
+
+	select {
+	case p.messages <- m:
+		publishQueueDepth.Set(float64(len(p.messages)))
+		return
+	default:
+	}
+
+	switch p.backpressure {
+	case BackpressureBlock:
+		select {
+		case p.messages <- m:
+		case <-p.stopping:
+			p.spoolAccessLog(m)
+		}
+	case BackpressureSpool:
+		p.spoolAccessLog(m)
+	default:
+		droppedTotal.WithLabelValues("queue_full").Inc()
+	}
+}
+
+func (p *AccessLogPublisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*accessLogMessage, 0, p.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			p.flush(batch)
+			batch = batch[:0]
+		}
+		publishQueueDepth.Set(float64(len(p.messages)))
+	}
+
+	for {
+		select {
+		case m := <-p.messages:
+			batch = append(batch, m)
+			if len(batch) >= p.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-p.drain:
+			for {
+				select {
+				case m := <-p.messages:
+					batch = append(batch, m)
+					if len(batch) >= p.batchSize {
+						flush()
+					}
+					continue
+				default:
+				}
+				break
+			}
+			flush()
+			return
+		}
+	}
+}
+
+// flush publishes batch in one span linked to the requests of its access logs.
+// Retries and the spool only take the messages the broker did not confirm.
+func (p *AccessLogPublisher) flush(batch []*accessLogMessage) {
+	links := make([]trace.Link, 0, min(len(batch), maxPublishLinks))
+	for _, m := range batch {
+		if len(links) < maxPublishLinks && m.link.SpanContext.IsValid() {
+			links = append(links, m.link)
+		}
+	}
+
+	ctx, span := tracing.Tracer().Start(
+		p.ctx,
+		p.queue+" publish",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", p.queue),
+			attribute.Int("messaging.batch.message_count", len(batch)),
+		),
+	)
+
+	publishBatchSize.Observe(float64(len(batch)))
+	pending := batch
+	err := backoff.RetryNotify(func() error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		msgs := make([]queue.Message, len(pending))
+		for i, m := range pending {
+			msgs[i] = m.message()
+		}
+		err := p.publisher.Publish(ctx, msgs...)
+		if err != nil {
+			pending = pick(pending, queue.Failed(err, len(msgs)))
+		}
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 5), ctx), func(err error, d time.Duration) {
+		publishRetriesTotal.Inc()
+		span.AddEvent("retry", trace.WithAttributes(attribute.String("error", err.Error()), attribute.Int("messaging.batch.failed_count", len(pending))))
+	})
+	tracing.End(span, err)
+	if err != nil {
+		publishFailuresTotal.Add(float64(len(pending)))
+		slog.Error("Failed to publish access logs", "error", err, "count", len(pending), "batch", len(batch))
+		for _, m := range pending {
+			p.spoolAccessLog(m)
+		}
+	}
+}
+
+// pick returns the messages at indexes.
+func pick(messages []*accessLogMessage, indexes []int) []*accessLogMessage {
+	picked := make([]*accessLogMessage, len(indexes))
+	for i, index := range indexes {
+		picked[i] = messages[index]
+	}
+	return picked
+}
+
+// maxPublishLinks caps the links of a publish span.
+const maxPublishLinks = 128
+
+// Stop publishes the queued access logs. When ctx is done first, the rest is
+// spooled and ctx.Err() is returned. Access logs enqueued after Stop are spooled.
+func (p *AccessLogPublisher) Stop(ctx context.Context) error {
+	close(p.stopping)
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	close(p.drain)
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-p.done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zeebo/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/spool"
+)
+
+func TestParseBackpressurePolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"block", "drop", "spool"} {
+		p, err := ParseBackpressurePolicy(s)
+		assert.NoError(t, err)
+		assert.Equal(t, string(p), s)
+	}
+	_, err := ParseBackpressurePolicy("wait")
+	assert.Error(t, err)
+}
+
+// The publisher is not started, so the queue of size 1 stays full after the first Enqueue.
+func TestAccessLogPublisher_Enqueue_queueFull(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("drop", func(t *testing.T) {
+		p := NewAccessLogPublisher(nil, "q", nil, AccessLogPublisherOptions{
+			QueueSize:    1,
+			BatchSize:    1,
+			Backpressure: BackpressureDrop,
+		})
+		before := testutil.ToFloat64(droppedTotal.WithLabelValues("queue_full"))
+		p.Enqueue(context.Background(), 1, []byte(`{"account_id":1}`), ts)
+		p.Enqueue(context.Background(), 2, []byte(`{"account_id":2}`), ts)
+		assert.Equal(t, testutil.ToFloat64(droppedTotal.WithLabelValues("queue_full"))-before, 1.0)
+		assert.Equal(t, len(p.messages), 1)
+	})
+
+	t.Run("spool", func(t *testing.T) {
+		s, err := spool.Open(t.TempDir(), 1<<10, 1<<10)
+		assert.NoError(t, err)
+		p := NewAccessLogPublisher(nil, "q", s, AccessLogPublisherOptions{
+			QueueSize:    1,
+			BatchSize:    1,
+			Backpressure: BackpressureSpool,
+		})
+		p.Enqueue(context.Background(), 1, []byte(`{"account_id":1}`), ts)
+		p.Enqueue(context.Background(), 2, []byte(`{"account_id":2}`), ts)
+
+		var got []accessLogMessage
+		assert.NoError(t, s.Replay(context.Background(), func(record []byte) error {
+			var m accessLogMessage
+			if err := json.Unmarshal(record, &m); err != nil {
+				return err
+			}
+			got = append(got, m)
+			return nil
+		}))
+		assert.Equal(t, len(got), 1)
+		assert.True(t, got[0].Timestamp.Equal(ts))
+		assert.Equal(t, got[0].AccountId, int64(2))
+		assert.Equal(t, string(got[0].Payload), `{"account_id":2}`)
+	})
+}
+
+func TestAccessLogPublisher_Stop(t *testing.T) {
+	t.Parallel()
+
+	q := queue.NewMemory(10)
+	p := NewAccessLogPublisher(q, "q", nil, AccessLogPublisherOptions{
+		QueueSize:     10,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+		Backpressure:  BackpressureBlock,
+	})
+	p.Start()
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	p.Enqueue(context.Background(), 1, []byte(`{"account_id":1}`), ts)
+	p.Enqueue(context.Background(), 2, []byte(`{"account_id":2}`), ts)
+	assert.NoError(t, p.Stop(context.Background()))
+
+	var keys []string
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Consume(ctx, 10, func(d queue.Delivery) {
+		keys = append(keys, d.Key)
+		if len(keys) == 2 {
+			cancel()
+		}
+	})
+	assert.DeepEqual(t, keys, []string{"1", "2"})
+}
+
+// partialPublisher accepts every message except those with a key in reject, which it rejects
+// on the first attempt only.
+type partialPublisher struct {
+	reject   map[string]bool
+	attempts int
+	accepted []string
+}
+
+func (p *partialPublisher) Publish(ctx context.Context, msgs ...queue.Message) error {
+	p.attempts++
+	var failed []int
+	for i, m := range msgs {
+		if p.attempts == 1 && p.reject[m.Key] {
+			failed = append(failed, i)
+			continue
+		}
+		p.accepted = append(p.accepted, m.Key)
+	}
+	if len(failed) > 0 {
+		return &queue.PublishError{Failed: failed, Err: errors.New("nacked")}
+	}
+	return nil
+}
+
+func (p *partialPublisher) Ready(ctx context.Context) error {
+	return nil
+}
+
+func TestAccessLogPublisher_flush_partial(t *testing.T) {
+	t.Parallel()
+
+	publisher := &partialPublisher{reject: map[string]bool{"2": true}}
+	p := NewAccessLogPublisher(publisher, "q", nil, AccessLogPublisherOptions{
+		QueueSize:    10,
+		BatchSize:    10,
+		Backpressure: BackpressureBlock,
+	})
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	var batch []*accessLogMessage
+	for _, id := range []int64{1, 2, 3} {
+		batch = append(batch, &accessLogMessage{AccountId: id, Timestamp: ts, Payload: json.RawMessage(`{}`)})
+	}
+	p.flush(batch)
+
+	assert.Equal(t, publisher.attempts, 2)
+	assert.DeepEqual(t, publisher.accepted, []string{"1", "3", "2"})
+}
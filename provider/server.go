@@ -1,18 +1,42 @@
 package provider
 
 import (
+	"database/sql"
 	"net/http"
+
+	"github.com/szks-repo/usage-based-billing-sample/invoice/render"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/health"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
 )
 
 func NewApiServer(
 	port string,
 	mw Middleware,
+	meters Meters,
+	usageReader usage.Reader,
+	invoicePreviewer InvoicePreviewer,
+	invoiceRenderer *render.Renderer,
+	dbConn *sql.DB,
+	checker *health.Checker,
 ) *http.Server {
 	handler := NewApiHandler()
+	usageHandler := NewUsageHandler(usageReader)
+	invoiceHandler := NewInvoiceHandler(invoicePreviewer, dbConn, invoiceRenderer)
+	alertHandler := NewAlertHandler(dbConn)
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /api/v1/health", handler.HandleHelth)
-	mux.Handle("GET /api/v1/one", mw.Wrap(http.HandlerFunc(handler.HandleApi1)))
-	mux.Handle("GET /api/v1/two", mw.Wrap(http.HandlerFunc(handler.HandleApi2)))
+	mux.HandleFunc("GET /api/v1/health", checker.HandleReadiness)
+	checker.Register(mux)
+	mux.Handle("GET /api/v1/one", mw.Wrap(meters.Get("api1"), http.HandlerFunc(handler.HandleApi1)))
+	mux.Handle("GET /api/v1/two", mw.Wrap(meters.Get("api2"), http.HandlerFunc(handler.HandleApi2)))
+	mux.Handle("GET /api/v1/usage", mw.Authenticate(http.HandlerFunc(usageHandler.HandleListUsage)))
+	mux.Handle("GET /api/v1/usage/current", mw.Authenticate(http.HandlerFunc(usageHandler.HandleCurrentUsage)))
+	mux.Handle("GET /api/v1/usage/free-credit", mw.Authenticate(http.HandlerFunc(usageHandler.HandleFreeCredit)))
+	mux.Handle("GET /api/v1/invoice/preview", mw.Authenticate(http.HandlerFunc(invoiceHandler.HandlePreview)))
+	mux.Handle("GET /api/v1/invoices", mw.Authenticate(http.HandlerFunc(invoiceHandler.HandleListInvoices)))
+	mux.Handle("GET /api/v1/invoices/{id}", mw.Authenticate(http.HandlerFunc(invoiceHandler.HandleGetInvoice)))
+	mux.Handle("GET /api/v1/alerts", mw.Authenticate(http.HandlerFunc(alertHandler.HandleListAlerts)))
+	mux.Handle("POST /api/v1/alerts", mw.Authenticate(http.HandlerFunc(alertHandler.HandleCreateAlert)))
+	mux.Handle("DELETE /api/v1/alerts/{id}", mw.Authenticate(http.HandlerFunc(alertHandler.HandleDeleteAlert)))
 
 	server := &http.Server{
 		Addr:    port,
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/spool"
+)
+
+func (p *AccessLogPublisher) spoolAccessLog(m *accessLogMessage) {
+	if p.spool == nil {
+		droppedTotal.WithLabelValues("no_spool").Inc()
+		return
+	}
+
+	record, err := json.Marshal(m)
+	if err == nil {
+		err = p.spool.Append(record)
+	}
+	spoolBytes.Set(float64(p.spool.Size()))
+	if err != nil {
+		droppedTotal.WithLabelValues("spool_failed").Inc()
+		slog.Error("Failed to spool access log, it will not be billed", "error", err, "payload", string(m.Payload))
+		return
+	}
+	spooledTotal.Inc()
+}
+
+// SpoolForwarder publishes the spooled access logs once the broker is available again.
+type SpoolForwarder struct {
+	spool     *spool.Spool
+	publisher queue.UsagePublisher
+}
+
+func NewSpoolForwarder(accessLogSpool *spool.Spool, publisher queue.UsagePublisher) *SpoolForwarder {
+	return &SpoolForwarder{
+		spool:     accessLogSpool,
+		publisher: publisher,
+	}
+}
+
+// Run replays the spool every interval until ctx is cancelled.
+func (f *SpoolForwarder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Forward(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Failed to forward spooled access logs", "error", err)
+			}
+		}
+	}
+}
+
+// Forward publishes spooled access logs in order and stops at the first failure.
+func (f *SpoolForwarder) Forward(ctx context.Context) error {
+	defer func() {
+		spoolBytes.Set(float64(f.spool.Size()))
+	}()
+	if f.spool.Size() == 0 || f.publisher.Ready(ctx) != nil {
+		return nil
+	}
+
+	return f.spool.Replay(ctx, func(record []byte) error {
+		var m accessLogMessage
+		if err := json.Unmarshal(record, &m); err != nil {
+			// a record that cannot be decoded would block the spool forever
+			slog.Error("Dropping undecodable spooled access log", "error", err, "record", string(record))
+			droppedTotal.WithLabelValues("undecodable").Inc()
+			return nil
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := f.publisher.Publish(publishCtx, m.message()); err != nil {
+			return err
+		}
+		replayedTotal.Inc()
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle is a fixed window limiter per account for throttled accounts.
+type throttle struct {
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	counters map[int64]*throttleCounter
+}
+
+type throttleCounter struct {
+	windowStart time.Time
+	count       int
+}
+
+func newThrottle(limit int, window time.Duration) *throttle {
+	return &throttle{
+		limit:    limit,
+		window:   window,
+		counters: make(map[int64]*throttleCounter),
+	}
+}
+
+func (t *throttle) Allow(accountId int64, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	windowStart := now.Truncate(t.window)
+	c, ok := t.counters[accountId]
+	if !ok || !c.windowStart.Equal(windowStart) {
+		if len(t.counters) > 10000 {
+			t.evict(windowStart)
+		}
+		c = &throttleCounter{windowStart: windowStart}
+		t.counters[accountId] = c
+	}
+	if c.count >= t.limit {
+		return false
+	}
+	c.count++
+	return true
+}
+
+func (t *throttle) evict(windowStart time.Time) {
+	for accountId, c := range t.counters {
+		if c.windowStart.Before(windowStart) {
+			delete(t.counters, accountId)
+		}
+	}
+}
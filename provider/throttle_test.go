@@ -0,0 +1,21 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestThrottle_Allow(t *testing.T) {
+	t.Parallel()
+
+	th := newThrottle(2, time.Minute)
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.True(t, th.Allow(1, base))
+	assert.True(t, th.Allow(1, base.Add(10*time.Second)))
+	assert.False(t, th.Allow(1, base.Add(20*time.Second)))
+	assert.True(t, th.Allow(2, base.Add(20*time.Second)))
+	assert.True(t, th.Allow(1, base.Add(time.Minute)))
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	httplib "github.com/szks-repo/usage-based-billing-sample/pkg/http"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+type UsageHandler struct {
+	reader usage.Reader
+}
+
+func NewUsageHandler(reader usage.Reader) *UsageHandler {
+	return &UsageHandler{
+		reader: reader,
+	}
+}
+
+// HandleListUsage returns the caller's usage for
+// ?granularity=minute|hour|day&from=RFC3339&to=RFC3339&limit=N&page_token=T&format=json|csv.
+func (h *UsageHandler) HandleListUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+	params := r.URL.Query()
+
+	granularity, err := usage.ParseGranularity(params.Get("granularity"))
+	if err != nil {
+		httplib.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	to := now.FromContext(ctx)
+	if v := params.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			httplib.WriteError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -1)
+	if v := params.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			httplib.WriteError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+			return
+		}
+	}
+	if !from.Before(to) {
+		httplib.WriteError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	var limit int
+	if v := params.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			httplib.WriteError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	page, err := h.reader.List(ctx, &usage.Query{
+		AccountId:   uint64(accountId),
+		Granularity: granularity,
+		From:        from,
+		To:          to,
+		PageToken:   params.Get("page_token"),
+		Limit:       limit,
+	})
+	if err != nil {
+		if errors.Is(err, usage.ErrInvalidPageToken) {
+			httplib.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("Failed to usage.Reader.List", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to list usage")
+		return
+	}
+
+	switch params.Get("format") {
+	case "", "json":
+		httplib.WriteJSON(w, http.StatusOK, map[string]any{
+			"granularity":     granularity,
+			"records":         page.Records,
+			"next_page_token": page.NextPageToken,
+		})
+	case "csv":
+		writeUsageCSV(w, page)
+	default:
+		httplib.WriteError(w, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+func writeUsageCSV(w http.ResponseWriter, page *usage.Page) {
+	w.Header().Set("Content-Type", "text/csv")
+	if page.NextPageToken != "" {
+		w.Header().Set("X-Next-Page-Token", page.NextPageToken)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"bucket", "usage"})
+	for _, rec := range page.Records {
+		cw.Write([]string{
+			rec.Bucket.Format(time.RFC3339),
+			strconv.FormatUint(rec.Usage, 10),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.Error("Failed to write csv", "error", err)
+	}
+}
+
+func (h *UsageHandler) HandleCurrentUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	period, err := h.reader.CurrentPeriod(ctx, uint64(accountId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httplib.WriteError(w, http.StatusNotFound, "no active subscription")
+			return
+		}
+		slog.Error("Failed to usage.Reader.CurrentPeriod", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to get current usage")
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, period)
+}
+
+func (h *UsageHandler) HandleFreeCredit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountId := ctx.Value(ctxkey.AccountId{}).(int64)
+
+	balance, err := h.reader.FreeCreditBalance(ctx, uint64(accountId))
+	if err != nil {
+		slog.Error("Failed to usage.Reader.FreeCreditBalance", "error", err)
+		httplib.WriteError(w, http.StatusInternalServerError, "failed to get free credit")
+		return
+	}
+
+	httplib.WriteJSON(w, http.StatusOK, map[string]int64{"remaining_free_credit": balance})
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types/ctxkey"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+// stubUsageReader answers for account 1 only and records the last query.
+type stubUsageReader struct {
+	query *usage.Query
+}
+
+func (r *stubUsageReader) List(ctx context.Context, q *usage.Query) (*usage.Page, error) {
+	r.query = q
+	if q.PageToken == "bad" {
+		return nil, fmt.Errorf("%w: %q", usage.ErrInvalidPageToken, q.PageToken)
+	}
+	return &usage.Page{
+		Records: []*usage.Record{
+			{Bucket: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), Usage: 10},
+			{Bucket: time.Date(2025, 7, 1, 1, 0, 0, 0, time.UTC), Usage: 20},
+		},
+		NextPageToken: "2025070101",
+	}, nil
+}
+
+func (r *stubUsageReader) CurrentPeriod(ctx context.Context, accountId uint64) (*usage.CurrentPeriod, error) {
+	if accountId != 1 {
+		return nil, sql.ErrNoRows
+	}
+	return &usage.CurrentPeriod{SubscriptionId: 3, Usage: 30}, nil
+}
+
+func (r *stubUsageReader) FreeCreditBalance(ctx context.Context, accountId uint64) (int64, error) {
+	return 500, nil
+}
+
+func serveUsage(handle http.HandlerFunc, accountId int64, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxkey.AccountId{}, accountId))
+	rec := httptest.NewRecorder()
+	handle(rec, req)
+	return rec
+}
+
+func TestUsageHandler_HandleListUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "json",
+			target:     "/api/v1/usage?granularity=hour&from=2025-07-01T00:00:00Z&to=2025-07-02T00:00:00Z&limit=2",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"granularity":"hour","next_page_token":"2025070101","records":[{"bucket":"2025-07-01T00:00:00Z","usage":10},{"bucket":"2025-07-01T01:00:00Z","usage":20}]}` + "\n",
+		},
+		{
+			name:       "csv",
+			target:     "/api/v1/usage?from=2025-07-01T00:00:00Z&to=2025-07-02T00:00:00Z&format=csv",
+			wantStatus: http.StatusOK,
+			wantBody:   "bucket,usage\n2025-07-01T00:00:00Z,10\n2025-07-01T01:00:00Z,20\n",
+		},
+		{
+			name:       "invalid granularity",
+			target:     "/api/v1/usage?granularity=week",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "from after to",
+			target:     "/api/v1/usage?from=2025-07-02T00:00:00Z&to=2025-07-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			target:     "/api/v1/usage?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid page token",
+			target:     "/api/v1/usage?page_token=bad",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid page token: \"bad\""}` + "\n",
+		},
+		{
+			name:       "invalid format",
+			target:     "/api/v1/usage?format=xml",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &stubUsageReader{}
+			rec := serveUsage(NewUsageHandler(reader).HandleListUsage, 1, tt.target)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, uint64(1), reader.query.AccountId)
+			}
+		})
+	}
+}
+
+func TestUsageHandler_HandleListUsage_csvNextPageToken(t *testing.T) {
+	t.Parallel()
+
+	rec := serveUsage(NewUsageHandler(&stubUsageReader{}).HandleListUsage, 1, "/api/v1/usage?format=csv")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "2025070101", rec.Header().Get("X-Next-Page-Token"))
+}
+
+func TestUsageHandler_HandleCurrentUsage(t *testing.T) {
+	t.Parallel()
+
+	h := NewUsageHandler(&stubUsageReader{})
+
+	rec := serveUsage(h.HandleCurrentUsage, 1, "/api/v1/usage/current")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var period usage.CurrentPeriod
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &period))
+	assert.Equal(t, uint64(3), period.SubscriptionId)
+	assert.Equal(t, uint64(30), period.Usage)
+
+	rec = serveUsage(h.HandleCurrentUsage, 2, "/api/v1/usage/current")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUsageHandler_HandleFreeCredit(t *testing.T) {
+	t.Parallel()
+
+	rec := serveUsage(NewUsageHandler(&stubUsageReader{}).HandleFreeCredit, 1, "/api/v1/usage/free-credit")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"remaining_free_credit":500}`+"\n", rec.Body.String())
+}
@@ -0,0 +1,309 @@
+package report
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/decimal128"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/compress"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, must be csv, ndjson or parquet", s)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/vnd.apache.parquet"
+}
+
+type ColumnType int
+
+const (
+	TypeUint64 ColumnType = iota
+	TypeString
+	// TypeDate is a time.Time of which only the date is written.
+	TypeDate
+	// TypeTimestamp is a time.Time written in UTC.
+	TypeTimestamp
+	// TypeDecimal is the string of a DECIMAL(20, 5) column, kept exact in every format.
+	TypeDecimal
+)
+
+const (
+	decimalPrecision = 20
+	decimalScale     = 5
+)
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Writer writes the rows of a report one at a time. Each value of a row has the Go type of its
+// column: uint64, string, time.Time or, for decimals, string.
+type Writer interface {
+	Write(row []any) error
+	// Close writes what is buffered, e.g. the Parquet footer. It does not close the underlying writer.
+	Close() error
+}
+
+// NewWriter returns a Writer of format streaming to w.
+func NewWriter(w io.Writer, format Format, columns []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatNDJSON:
+		return newNDJSONWriter(w, columns), nil
+	case FormatParquet:
+		return newParquetWriter(w, columns)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func checkRow(columns []Column, row []any) error {
+	if len(row) != len(columns) {
+		return fmt.Errorf("row has %d values for %d columns", len(row), len(columns))
+	}
+	for i, c := range columns {
+		var ok bool
+		switch c.Type {
+		case TypeUint64:
+			_, ok = row[i].(uint64)
+		case TypeString, TypeDecimal:
+			_, ok = row[i].(string)
+		case TypeDate, TypeTimestamp:
+			_, ok = row[i].(time.Time)
+		}
+		if !ok {
+			return fmt.Errorf("invalid value %T of column %s", row[i], c.Name)
+		}
+	}
+	return nil
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+	for i, c := range columns {
+		cw.record[i] = c.Name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(row []any) error {
+	if err := checkRow(cw.columns, row); err != nil {
+		return err
+	}
+	for i, c := range cw.columns {
+		switch c.Type {
+		case TypeUint64:
+			cw.record[i] = strconv.FormatUint(row[i].(uint64), 10)
+		case TypeString, TypeDecimal:
+			cw.record[i] = row[i].(string)
+		case TypeDate:
+			cw.record[i] = row[i].(time.Time).Format(time.DateOnly)
+		case TypeTimestamp:
+			cw.record[i] = row[i].(time.Time).UTC().Format(time.RFC3339)
+		}
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	keys    [][]byte
+	buf     []byte
+}
+
+func newNDJSONWriter(w io.Writer, columns []Column) *ndjsonWriter {
+	nw := &ndjsonWriter{w: bufio.NewWriter(w), columns: columns, keys: make([][]byte, len(columns))}
+	for i, c := range columns {
+		nw.keys[i] = strconv.AppendQuote(nil, c.Name)
+	}
+	return nw
+}
+
+// Write writes the row as an object with the keys in the order of the columns.
+func (nw *ndjsonWriter) Write(row []any) error {
+	if err := checkRow(nw.columns, row); err != nil {
+		return err
+	}
+	buf := append(nw.buf[:0], '{')
+	for i, c := range nw.columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, nw.keys[i]...)
+		buf = append(buf, ':')
+
+		var v any
+		switch c.Type {
+		case TypeUint64, TypeString:
+			v = row[i]
+		case TypeDecimal:
+			v = json.Number(row[i].(string))
+		case TypeDate:
+			v = row[i].(time.Time).Format(time.DateOnly)
+		case TypeTimestamp:
+			v = row[i].(time.Time).UTC()
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		buf = append(buf, b...)
+	}
+	buf = append(buf, '}', '\n')
+	nw.buf = buf
+	_, err := nw.w.Write(buf)
+	return err
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.w.Flush()
+}
+
+// parquetRowGroupRows bounds the rows held in memory, each batch is written as a row group.
+const parquetRowGroupRows = 64 * 1024
+
+type parquetWriter struct {
+	fw      *pqarrow.FileWriter
+	rb      *array.RecordBuilder
+	columns []Column
+	rows    int
+}
+
+func newParquetWriter(w io.Writer, columns []Column) (*parquetWriter, error) {
+	fields := make([]arrow.Field, len(columns))
+	for i, c := range columns {
+		fields[i] = arrow.Field{Name: c.Name, Type: arrowType(c.Type)}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithMaxRowGroupLength(parquetRowGroupRows),
+	)
+	// hide Close, the file writer closes its sink
+	sink := struct{ io.Writer }{w}
+	fw, err := pqarrow.NewFileWriter(schema, sink, props, pqarrow.NewArrowWriterProperties())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet file writer: %w", err)
+	}
+	return &parquetWriter{
+		fw:      fw,
+		rb:      array.NewRecordBuilder(memory.NewGoAllocator(), schema),
+		columns: columns,
+	}, nil
+}
+
+func arrowType(t ColumnType) arrow.DataType {
+	switch t {
+	case TypeUint64:
+		return arrow.PrimitiveTypes.Uint64
+	case TypeDate:
+		return arrow.FixedWidthTypes.Date32
+	case TypeTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_ms
+	case TypeDecimal:
+		return &arrow.Decimal128Type{Precision: decimalPrecision, Scale: decimalScale}
+	}
+	return arrow.BinaryTypes.String
+}
+
+func (pw *parquetWriter) Write(row []any) error {
+	if err := checkRow(pw.columns, row); err != nil {
+		return err
+	}
+	for i, c := range pw.columns {
+		switch c.Type {
+		case TypeUint64:
+			pw.rb.Field(i).(*array.Uint64Builder).Append(row[i].(uint64))
+		case TypeString:
+			pw.rb.Field(i).(*array.StringBuilder).Append(row[i].(string))
+		case TypeDate:
+			y, m, d := row[i].(time.Time).Date()
+			pw.rb.Field(i).(*array.Date32Builder).Append(arrow.Date32FromTime(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)))
+		case TypeTimestamp:
+			pw.rb.Field(i).(*array.TimestampBuilder).Append(arrow.Timestamp(row[i].(time.Time).UnixMilli()))
+		case TypeDecimal:
+			n, err := decimal128.FromString(row[i].(string), decimalPrecision, decimalScale)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", c.Name, err)
+			}
+			pw.rb.Field(i).(*array.Decimal128Builder).Append(n)
+		}
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupRows {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flush() error {
+	rec := pw.rb.NewRecord()
+	defer rec.Release()
+	pw.rows = 0
+	if rec.NumRows() == 0 {
+		return nil
+	}
+	if err := pw.fw.Write(rec); err != nil {
+		return fmt.Errorf("failed to write record to parquet: %w", err)
+	}
+	return nil
+}
+
+func (pw *parquetWriter) Close() error {
+	defer pw.rb.Release()
+	if err := pw.flush(); err != nil {
+		pw.fw.Close()
+		return err
+	}
+	if err := pw.fw.Close(); err != nil {
+		return fmt.Errorf("failed to close parquet writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = []Column{
+	{Name: "id", Type: TypeUint64},
+	{Name: "name", Type: TypeString},
+	{Name: "date", Type: TypeDate},
+	{Name: "at", Type: TypeTimestamp},
+	{Name: "price", Type: TypeDecimal},
+}
+
+func testRows() [][]any {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	return [][]any{
+		{uint64(1), "acme, inc.", time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo), time.Date(2025, 7, 1, 9, 0, 0, 0, tokyo), "20.00000"},
+		{uint64(2), `say "hi"`, time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 2, 12, 30, 0, 0, time.UTC), "0.12345"},
+	}
+}
+
+func writeRows(t *testing.T, format Format) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, testColumns)
+	require.NoError(t, err)
+	for _, row := range testRows() {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestWriter_CSV(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		"id,name,date,at,price\n"+
+			"1,\"acme, inc.\",2025-07-01,2025-07-01T00:00:00Z,20.00000\n"+
+			"2,\"say \"\"hi\"\"\",2025-07-02,2025-07-02T12:30:00Z,0.12345\n",
+		string(writeRows(t, FormatCSV)),
+	)
+}
+
+func TestWriter_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		`{"id":1,"name":"acme, inc.","date":"2025-07-01","at":"2025-07-01T00:00:00Z","price":20.00000}`+"\n"+
+			`{"id":2,"name":"say \"hi\"","date":"2025-07-02","at":"2025-07-02T12:30:00Z","price":0.12345}`+"\n",
+		string(writeRows(t, FormatNDJSON)),
+	)
+}
+
+func TestWriter_Parquet(t *testing.T) {
+	t.Parallel()
+
+	reader, err := file.NewParquetReader(bytes.NewReader(writeRows(t, FormatParquet)))
+	require.NoError(t, err)
+	defer reader.Close()
+	fileReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	table, err := fileReader.ReadTable(context.Background())
+	require.NoError(t, err)
+	defer table.Release()
+
+	require.Equal(t, int64(2), table.NumRows())
+	column := func(name string) arrow.Array {
+		indices := table.Schema().FieldIndices(name)
+		require.Len(t, indices, 1)
+		return table.Column(indices[0]).Data().Chunk(0)
+	}
+	ids := column("id").(*array.Uint64)
+	names := column("name").(*array.String)
+	dates := column("date").(*array.Date32)
+	ats := column("at").(*array.Timestamp)
+	prices := column("price").(*array.Decimal128)
+	for i, row := range testRows() {
+		assert.Equal(t, row[0], ids.Value(i))
+		assert.Equal(t, row[1], names.Value(i))
+		y, m, d := row[2].(time.Time).Date()
+		assert.Equal(t, time.Date(y, m, d, 0, 0, 0, 0, time.UTC), dates.Value(i).ToTime())
+		assert.Equal(t, row[3].(time.Time).UnixMilli(), int64(ats.Value(i)))
+		assert.Equal(t, row[4], prices.Value(i).ToString(decimalScale))
+	}
+}
+
+func TestWriter_InvalidRow(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []Format{FormatCSV, FormatNDJSON, FormatParquet} {
+		w, err := NewWriter(&bytes.Buffer{}, format, testColumns)
+		require.NoError(t, err)
+		assert.Error(t, w.Write([]any{uint64(1)}), format)
+		assert.Error(t, w.Write([]any{1, "name", time.Time{}, time.Time{}, "1"}), format)
+	}
+}
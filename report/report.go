@@ -0,0 +1,318 @@
+// Package report exports the usage and the invoices of a period for finance and customers.
+package report
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/usage"
+)
+
+type Kind string
+
+const (
+	// KindUsage is the usage and the requests of each account per day in the account's timezone.
+	KindUsage Kind = "usage"
+	// KindInvoices is the invoices created in the period.
+	KindInvoices Kind = "invoices"
+)
+
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(s); k {
+	case KindUsage, KindInvoices:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown report %q, must be usage or invoices", s)
+}
+
+var (
+	usageColumns = []Column{
+		{Name: "account_id", Type: TypeUint64},
+		{Name: "account_name", Type: TypeString},
+		{Name: "date", Type: TypeDate},
+		{Name: "usage", Type: TypeUint64},
+		{Name: "billable_requests", Type: TypeUint64},
+		{Name: "non_billable_requests", Type: TypeUint64},
+	}
+	invoiceColumns = []Column{
+		{Name: "invoice_id", Type: TypeUint64},
+		{Name: "account_id", Type: TypeUint64},
+		{Name: "account_name", Type: TypeString},
+		{Name: "subscription_id", Type: TypeUint64},
+		{Name: "period_from", Type: TypeTimestamp},
+		{Name: "period_to", Type: TypeTimestamp},
+		{Name: "total_usage", Type: TypeUint64},
+		{Name: "adjustment_usage", Type: TypeUint64},
+		{Name: "free_credit_discount", Type: TypeUint64},
+		{Name: "subtotal", Type: TypeDecimal},
+		{Name: "tax_rate", Type: TypeUint64},
+		{Name: "tax_amount", Type: TypeDecimal},
+		{Name: "total_price", Type: TypeDecimal},
+		{Name: "total_price_tax_included", Type: TypeDecimal},
+		{Name: "created_at", Type: TypeTimestamp},
+	}
+)
+
+func (k Kind) Columns() []Column {
+	if k == KindInvoices {
+		return invoiceColumns
+	}
+	return usageColumns
+}
+
+// Query selects the rows of a report.
+type Query struct {
+	Kind Kind
+	// From and To are the first and the last day of the period, inclusive, as dates in UTC.
+	// They are interpreted in the timezone of each account.
+	From, To time.Time
+	// AccountIds restricts the report to these accounts, all accounts when empty.
+	AccountIds []uint64
+}
+
+func (q *Query) Validate() error {
+	if q.From.IsZero() || q.To.IsZero() {
+		return fmt.Errorf("from and to are required")
+	}
+	if q.To.Before(q.From) {
+		return fmt.Errorf("to %s is before from %s", q.To.Format(time.DateOnly), q.From.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// window returns the UTC range containing the period in every timezone.
+func (q *Query) window() (time.Time, time.Time) {
+	return q.From.AddDate(0, 0, -1), q.To.AddDate(0, 0, 2)
+}
+
+// bounds returns the range of the period in loc.
+func (q *Query) bounds(loc *time.Location) (time.Time, time.Time, error) {
+	from, err := usage.GranularityDay.Parse(usage.GranularityDay.Key(q.From, time.UTC), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	last, err := usage.GranularityDay.Parse(usage.GranularityDay.Key(q.To, time.UTC), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return from, usage.NextDay(last), nil
+}
+
+type Exporter struct {
+	dbConn *sql.DB
+}
+
+func NewExporter(dbConn *sql.DB) *Exporter {
+	return &Exporter{
+		dbConn: dbConn,
+	}
+}
+
+type account struct {
+	name     string
+	loc      *time.Location
+	from, to time.Time
+}
+
+// Export writes the report of q as format to w. Rows are streamed from MySQL, so the
+// memory used does not depend on the size of the report.
+func (e *Exporter) Export(ctx context.Context, q *Query, format Format, w io.Writer) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	accounts, err := e.listAccounts(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	rw, err := NewWriter(w, format, q.Kind.Columns())
+	if err != nil {
+		return err
+	}
+	switch q.Kind {
+	case KindUsage:
+		err = e.exportUsage(ctx, q, accounts, rw)
+	case KindInvoices:
+		err = e.exportInvoices(ctx, q, accounts, rw)
+	default:
+		err = fmt.Errorf("unknown report %q", q.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+func accountCondition(column string, accountIds []uint64) (string, []any) {
+	if len(accountIds) == 0 {
+		return "", nil
+	}
+	args := make([]any, len(accountIds))
+	for i, id := range accountIds {
+		args[i] = id
+	}
+	return " AND " + column + " IN (?" + strings.Repeat(", ?", len(accountIds)-1) + ")", args
+}
+
+func (e *Exporter) listAccounts(ctx context.Context, q *Query) (map[uint64]*account, error) {
+	where, args := accountCondition("id", q.AccountIds)
+	rows, err := e.dbConn.QueryContext(ctx, "SELECT id, account_name, timezone FROM account WHERE 1 = 1"+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make(map[uint64]*account)
+	for rows.Next() {
+		var id uint64
+		var a account
+		var timezone sql.NullString
+		if err := rows.Scan(&id, &a.name, &timezone); err != nil {
+			return nil, err
+		}
+		if a.loc, err = usage.Location(timezone); err != nil {
+			return nil, fmt.Errorf("account %d: %w", id, err)
+		}
+		if a.from, a.to, err = q.bounds(a.loc); err != nil {
+			return nil, err
+		}
+		accounts[id] = &a
+	}
+	return accounts, rows.Err()
+}
+
+// usageDay is a row of the usage report.
+type usageDay struct {
+	accountId   uint64
+	date        time.Time
+	usage       uint64
+	billable    uint64
+	nonBillable uint64
+}
+
+// dayGrouper sums minutes ordered by account and minute into days.
+type dayGrouper struct {
+	current *usageDay
+}
+
+// add adds a minute of the account and returns the previous day when the minute starts a new one.
+func (g *dayGrouper) add(accountId uint64, loc *time.Location, minute time.Time, minuteUsage, billable, nonBillable uint64) *usageDay {
+	var done *usageDay
+	date := usage.StartOfDay(minute, loc)
+	if g.current != nil && (g.current.accountId != accountId || !g.current.date.Equal(date)) {
+		done, g.current = g.current, nil
+	}
+	if g.current == nil {
+		g.current = &usageDay{accountId: accountId, date: date}
+	}
+	g.current.usage += minuteUsage
+	g.current.billable += billable
+	g.current.nonBillable += nonBillable
+	return done
+}
+
+// flush returns the last day, nil when no minute was added since.
+func (g *dayGrouper) flush() *usageDay {
+	done := g.current
+	g.current = nil
+	return done
+}
+
+func (e *Exporter) exportUsage(ctx context.Context, q *Query, accounts map[uint64]*account, rw Writer) error {
+	from, to := q.window()
+	where, args := accountCondition("account_id", q.AccountIds)
+	rows, err := e.dbConn.QueryContext(
+		ctx,
+		"SELECT account_id, `minute`, `usage`, billable_requests, non_billable_requests FROM every_minute_api_usage "+
+			"WHERE `minute` >= ? AND `minute` < ?"+where+" "+
+			"ORDER BY account_id, `minute`",
+		append([]any{usage.GranularityMinute.Key(from, time.UTC), usage.GranularityMinute.Key(to, time.UTC)}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	write := func(day *usageDay) error {
+		if day == nil {
+			return nil
+		}
+		return rw.Write([]any{day.accountId, accounts[day.accountId].name, day.date, day.usage, day.billable, day.nonBillable})
+	}
+
+	var g dayGrouper
+	for rows.Next() {
+		var accountId, minuteUsage, billable, nonBillable uint64
+		var key string
+		if err := rows.Scan(&accountId, &key, &minuteUsage, &billable, &nonBillable); err != nil {
+			return err
+		}
+		a, ok := accounts[accountId]
+		if !ok {
+			continue
+		}
+		minute, err := usage.GranularityMinute.Parse(key, time.UTC)
+		if err != nil {
+			return err
+		}
+		if minute.Before(a.from) || !minute.Before(a.to) {
+			continue
+		}
+		if err := write(g.add(accountId, a.loc, minute, minuteUsage, billable, nonBillable)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return write(g.flush())
+}
+
+func (e *Exporter) exportInvoices(ctx context.Context, q *Query, accounts map[uint64]*account, rw Writer) error {
+	from, to := q.window()
+	where, args := accountCondition("i.account_id", q.AccountIds)
+	rows, err := e.dbConn.QueryContext(
+		ctx,
+		"SELECT i.id, i.account_id, i.subscription_id, s.from, s.estimated_to, i.total_usage, i.adjustment_usage, i.free_credit_discount, "+
+			"i.subtotal, i.tax_rate, i.tax_amount, i.total_price, i.total_price_tax_included, i.created_at "+
+			"FROM invoice i JOIN subscription s ON s.id = i.subscription_id "+
+			"WHERE i.created_at >= ? AND i.created_at < ?"+where+" "+
+			"ORDER BY i.created_at, i.id",
+		append([]any{from, to}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			invoiceId, accountId, subscriptionId                   uint64
+			periodFrom, periodTo, createdAt                        time.Time
+			totalUsage, adjustmentUsage, freeCreditDiscount        uint64
+			taxRate                                                uint64
+			subtotal, taxAmount, totalPrice, totalPriceTaxIncluded string
+		)
+		if err := rows.Scan(
+			&invoiceId, &accountId, &subscriptionId, &periodFrom, &periodTo, &totalUsage, &adjustmentUsage, &freeCreditDiscount,
+			&subtotal, &taxRate, &taxAmount, &totalPrice, &totalPriceTaxIncluded, &createdAt,
+		); err != nil {
+			return err
+		}
+		a, ok := accounts[accountId]
+		if !ok || createdAt.Before(a.from) || !createdAt.Before(a.to) {
+			continue
+		}
+		if err := rw.Write([]any{
+			invoiceId, accountId, a.name, subscriptionId, periodFrom, periodTo, totalUsage, adjustmentUsage, freeCreditDiscount,
+			subtotal, taxRate, taxAmount, totalPrice, totalPriceTaxIncluded, createdAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
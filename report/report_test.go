@@ -0,0 +1,61 @@
+package report
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_bounds(t *testing.T) {
+	t.Parallel()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	q := &Query{From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)}
+	from, to, err := q.bounds(newYork)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2025, 3, 1, 0, 0, 0, 0, newYork).Equal(from), from)
+	assert.True(t, time.Date(2025, 4, 1, 0, 0, 0, 0, newYork).Equal(to), to)
+
+	windowFrom, windowTo := q.window()
+	assert.False(t, from.Before(windowFrom))
+	assert.False(t, to.After(windowTo))
+}
+
+func TestDayGrouper(t *testing.T) {
+	t.Parallel()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	minutes := []struct {
+		accountId uint64
+		loc       *time.Location
+		minute    time.Time
+	}{
+		{1, tokyo, time.Date(2025, 6, 30, 14, 59, 0, 0, time.UTC)},
+		{1, tokyo, time.Date(2025, 6, 30, 15, 0, 0, 0, time.UTC)},
+		{1, tokyo, time.Date(2025, 7, 1, 14, 0, 0, 0, time.UTC)},
+		{2, time.UTC, time.Date(2025, 7, 1, 14, 0, 0, 0, time.UTC)},
+	}
+
+	var g dayGrouper
+	var got []*usageDay
+	for i, m := range minutes {
+		if day := g.add(m.accountId, m.loc, m.minute, uint64(i+1), 1, uint64(i)); day != nil {
+			got = append(got, day)
+		}
+	}
+	got = append(got, g.flush())
+	assert.Nil(t, g.flush())
+
+	assert.Equal(t, []*usageDay{
+		{accountId: 1, date: time.Date(2025, 6, 30, 0, 0, 0, 0, tokyo), usage: 1, billable: 1, nonBillable: 0},
+		{accountId: 1, date: time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo), usage: 5, billable: 2, nonBillable: 3},
+		{accountId: 2, date: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), usage: 4, billable: 1, nonBillable: 3},
+	}, got)
+}
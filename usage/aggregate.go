@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// AggregateByMinute sums the billable usage and counts the billable and non-billable
+// requests of logs per account, minute and meter.
+// Minutes are keyed in UTC; days are only cut in the account timezone when rolled up.
+func AggregateByMinute(logs []types.ApiAccessLog) []*dto.EveryMinuteAPIUsage {
+	type key struct {
+		accountId int64
+		minute    string
+		meter     string
+	}
+	rows := make(map[key]*dto.EveryMinuteAPIUsage)
+	var dst []*dto.EveryMinuteAPIUsage
+	for _, l := range logs {
+		k := key{accountId: l.AccountId, minute: GranularityMinute.Key(l.Timestamp, time.UTC), meter: l.Meter}
+		row, ok := rows[k]
+		if !ok {
+			row = &dto.EveryMinuteAPIUsage{AccountID: uint64(k.accountId), Meter: k.meter, Minute: k.minute}
+			rows[k] = row
+			dst = append(dst, row)
+		}
+		row.Usage += uint64(l.BillableUsage())
+		if l.NonBillable {
+			row.NonBillableRequests++
+		} else {
+			row.BillableRequests++
+		}
+	}
+	return dst
+}
@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestAggregateByMinute(t *testing.T) {
+	t.Parallel()
+
+	// Decoded archives are in UTC while the provider logs in its local zone.
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	got := AggregateByMinute([]types.ApiAccessLog{
+		{AccountId: 1, Timestamp: base},
+		{AccountId: 1, Timestamp: base.Add(10 * time.Second), Meter: "api1", Usage: 5},
+		{AccountId: 1, Timestamp: base.Add(20 * time.Second), Meter: "api1", Usage: 3, NonBillable: true},
+		{AccountId: 1, Timestamp: base.Add(time.Minute).In(time.FixedZone("JST", 9*60*60)), Meter: "api1", Usage: 2},
+		{AccountId: 2, Timestamp: base, Meter: "api1", Usage: 1, NonBillable: true},
+		{AccountId: 2, Timestamp: base, Meter: "api1", Usage: -7},
+	})
+
+	var rows []dto.EveryMinuteAPIUsage
+	for _, r := range got {
+		rows = append(rows, *r)
+	}
+	assert.Equal(t, []dto.EveryMinuteAPIUsage{
+		{AccountID: 1, Minute: "202507011000", Usage: 1, BillableRequests: 1},
+		{AccountID: 1, Meter: "api1", Minute: "202507011000", Usage: 5, BillableRequests: 1, NonBillableRequests: 1},
+		{AccountID: 1, Meter: "api1", Minute: "202507011001", Usage: 2, BillableRequests: 1},
+		{AccountID: 2, Meter: "api1", Minute: "202507011000", BillableRequests: 1, NonBillableRequests: 1},
+	}, rows)
+}
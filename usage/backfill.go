@@ -0,0 +1,593 @@
+package usage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/logstore"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+var (
+	ErrDayNotClosed = errors.New("day is not closed yet")
+	ErrPeriodClosed = errors.New("billing period is closed")
+)
+
+// Counts is the content of one bucket. Only minutes count requests.
+type Counts struct {
+	Usage               uint64 `json:"usage"`
+	BillableRequests    uint64 `json:"billable_requests,omitempty"`
+	NonBillableRequests uint64 `json:"non_billable_requests,omitempty"`
+}
+
+type BucketKey struct {
+	Granularity Granularity
+	AccountId   uint64
+	Meter       string
+	Bucket      string
+}
+
+// Buckets holds the rows of every_minute_api_usage, houry_api_usage and daily_api_usage.
+type Buckets map[BucketKey]Counts
+
+func minuteBuckets(logs []types.ApiAccessLog) Buckets {
+	buckets := make(Buckets)
+	for _, row := range AggregateByMinute(logs) {
+		buckets[BucketKey{GranularityMinute, row.AccountID, row.Meter, row.Minute}] = Counts{
+			Usage:               row.Usage,
+			BillableRequests:    row.BillableRequests,
+			NonBillableRequests: row.NonBillableRequests,
+		}
+	}
+	return buckets
+}
+
+// Recompute aggregates logs into minutes and rolls them up with RollUp.
+func Recompute(logs []types.ApiAccessLog, loc *time.Location) (Buckets, error) {
+	return RollUp(minuteBuckets(logs), loc)
+}
+
+// RollUp returns the minute buckets together with the UTC hours and the days in loc they sum up to.
+func RollUp(minutes Buckets, loc *time.Location) (Buckets, error) {
+	buckets := make(Buckets, len(minutes))
+	for k, c := range minutes {
+		if k.Granularity != GranularityMinute {
+			continue
+		}
+		t, err := GranularityMinute.Parse(k.Bucket, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minute %q: %w", k.Bucket, err)
+		}
+		buckets[k] = c
+		for _, g := range []Granularity{GranularityHour, GranularityDay} {
+			rk := BucketKey{g, k.AccountId, k.Meter, g.Key(t, loc)}
+			buckets[rk] = Counts{Usage: buckets[rk].Usage + c.Usage}
+		}
+	}
+	return buckets, nil
+}
+
+// dayUsage returns the usage of all meters of the account on date.
+func (b Buckets) dayUsage(accountId uint64, date string) uint64 {
+	var sum uint64
+	for k, c := range b {
+		if k.Granularity == GranularityDay && k.AccountId == accountId && k.Bucket == date {
+			sum += c.Usage
+		}
+	}
+	return sum
+}
+
+// Diff is a bucket whose stored counts differ from the recomputed ones.
+type Diff struct {
+	Granularity Granularity `json:"granularity"`
+	AccountId   uint64      `json:"account_id"`
+	Meter       string      `json:"meter"`
+	Bucket      string      `json:"bucket"`
+	Stored      Counts      `json:"stored"`
+	Recomputed  Counts      `json:"recomputed"`
+}
+
+// DiffBuckets returns the differing buckets ordered by granularity, account, bucket and meter.
+func DiffBuckets(stored, recomputed Buckets) []*Diff {
+	var diffs []*Diff
+	for k, c := range stored {
+		if c != recomputed[k] {
+			diffs = append(diffs, &Diff{Granularity: k.Granularity, AccountId: k.AccountId, Meter: k.Meter, Bucket: k.Bucket, Stored: c, Recomputed: recomputed[k]})
+		}
+	}
+	for k, c := range recomputed {
+		if _, ok := stored[k]; !ok {
+			diffs = append(diffs, &Diff{Granularity: k.Granularity, AccountId: k.AccountId, Meter: k.Meter, Bucket: k.Bucket, Recomputed: c})
+		}
+	}
+	sortDiffs(diffs)
+	return diffs
+}
+
+func sortDiffs(diffs []*Diff) {
+	order := map[Granularity]int{GranularityMinute: 0, GranularityHour: 1, GranularityDay: 2}
+	slices.SortFunc(diffs, func(a, b *Diff) int {
+		return cmp.Or(
+			cmp.Compare(order[a.Granularity], order[b.Granularity]),
+			cmp.Compare(a.AccountId, b.AccountId),
+			strings.Compare(a.Bucket, b.Bucket),
+			strings.Compare(a.Meter, b.Meter),
+		)
+	})
+}
+
+// accountDay is a day in the timezone of an account. The hours are widened to whole UTC
+// hours, which differ from the day for timezones such as Asia/Kolkata.
+type accountDay struct {
+	accountId        uint64
+	loc              *time.Location
+	from, to         time.Time
+	hourFrom, hourTo time.Time
+}
+
+func newAccountDay(accountId uint64, loc *time.Location, y int, m time.Month, d int) *accountDay {
+	from := startOfDate(y, m, d, loc)
+	to := NextDay(from)
+	hourTo := to.UTC().Truncate(time.Hour)
+	if hourTo.Before(to) {
+		hourTo = hourTo.Add(time.Hour)
+	}
+	return &accountDay{
+		accountId: accountId,
+		loc:       loc,
+		from:      from,
+		to:        to,
+		hourFrom:  from.UTC().Truncate(time.Hour),
+		hourTo:    hourTo,
+	}
+}
+
+// contains reports whether a backfill of the day rewrites the bucket k.
+func (a *accountDay) contains(k BucketKey) bool {
+	if k.AccountId != a.accountId {
+		return false
+	}
+	switch k.Granularity {
+	case GranularityMinute:
+		return k.Bucket >= GranularityMinute.Key(a.from, time.UTC) && k.Bucket < GranularityMinute.Key(a.to, time.UTC)
+	case GranularityHour:
+		return k.Bucket >= GranularityHour.Key(a.hourFrom, time.UTC) && k.Bucket < GranularityHour.Key(a.hourTo, time.UTC)
+	default:
+		return k.Bucket == GranularityDay.Key(a.from, a.loc)
+	}
+}
+
+// recompute replaces the stored minutes of the day with minutes and returns the buckets
+// the day rewrites. Hours crossing an edge of the day keep the stored minutes beyond it.
+func (a *accountDay) recompute(stored, minutes Buckets) (Buckets, error) {
+	merged := make(Buckets)
+	for k, c := range stored {
+		if k.Granularity == GranularityMinute && k.AccountId == a.accountId && !a.contains(k) {
+			merged[k] = c
+		}
+	}
+	for k, c := range minutes {
+		if a.contains(k) {
+			merged[k] = c
+		}
+	}
+	buckets, err := RollUp(merged, a.loc)
+	if err != nil {
+		return nil, err
+	}
+	maps.DeleteFunc(buckets, func(k BucketKey, _ Counts) bool { return !a.contains(k) })
+	return buckets, nil
+}
+
+// accountDays returns the day of date in the timezone of each account, all of which must have ended.
+func accountDays(ctx context.Context, date time.Time, locs map[uint64]*time.Location) ([]*accountDay, error) {
+	y, m, d := date.Date()
+	current := now.FromContext(ctx)
+
+	var days []*accountDay
+	for _, accountId := range slices.Sorted(maps.Keys(locs)) {
+		day := newAccountDay(accountId, locs[accountId], y, m, d)
+		if day.to.After(current) {
+			return nil, fmt.Errorf("%w: %s of account %d in %s", ErrDayNotClosed, date.Format(time.DateOnly), accountId, day.loc)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// Backfiller recomputes the usage tables of closed days from the access log archives.
+type Backfiller struct {
+	dbConn *sql.DB
+	source *logstore.Source
+}
+
+func NewBackfiller(dbConn *sql.DB, source *logstore.Source) *Backfiller {
+	return &Backfiller{
+		dbConn: dbConn,
+		source: source,
+	}
+}
+
+// Backfill compares the stored usage of date, a day in each account's timezone, with the
+// usage recomputed from the archives, for accountIds or all accounts when empty. With
+// overwrite the rows of the accounts that differ are replaced in one transaction, so
+// running it again finds no differences and writes nothing.
+func (b *Backfiller) Backfill(ctx context.Context, date time.Time, accountIds []uint64, overwrite bool) ([]*Diff, error) {
+	locs, err := accountLocations(ctx, b.dbConn, accountIds)
+	if err != nil {
+		return nil, err
+	}
+	days, err := accountDays(ctx, date, locs)
+	if err != nil {
+		return nil, err
+	}
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	logs, err := b.read(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+	minutes := byAccount(minuteBuckets(logs))
+
+	stored, err := b.load(ctx, days, accountIds)
+	if err != nil {
+		return nil, err
+	}
+	storedByAccount := byAccount(stored)
+
+	var diffs []*Diff
+	var changed []*accountDay
+	recomputed := make(Buckets)
+	for _, day := range days {
+		before := storedByAccount[day.accountId]
+		after, err := day.recompute(before, minutes[day.accountId])
+		if err != nil {
+			return nil, err
+		}
+		maps.DeleteFunc(before, func(k BucketKey, _ Counts) bool { return !day.contains(k) })
+		dayDiffs := DiffBuckets(before, after)
+		if len(dayDiffs) == 0 {
+			continue
+		}
+		diffs = append(diffs, dayDiffs...)
+		changed = append(changed, day)
+		maps.Copy(recomputed, after)
+	}
+	sortDiffs(diffs)
+
+	slog.Info("Recomputed usage", "date", date.Format(time.DateOnly), "accounts", len(days), "logs", len(logs), "diffs", len(diffs))
+	if !overwrite || len(changed) == 0 {
+		return diffs, nil
+	}
+	closed, err := b.closedPeriods(ctx, changed)
+	if err != nil {
+		return nil, err
+	}
+	if err := refuseClosed(date, changed, closed); err != nil {
+		return nil, err
+	}
+	if err := b.overwrite(ctx, changed, stored, recomputed); err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+func byAccount(buckets Buckets) map[uint64]Buckets {
+	grouped := make(map[uint64]Buckets)
+	for k, c := range buckets {
+		if grouped[k.AccountId] == nil {
+			grouped[k.AccountId] = make(Buckets)
+		}
+		grouped[k.AccountId][k] = c
+	}
+	return grouped
+}
+
+// period is a subscription period of an account.
+type period struct {
+	accountId uint64
+	from, to  time.Time
+}
+
+// closedPeriods returns the closed subscription periods overlapping the days.
+func (b *Backfiller) closedPeriods(ctx context.Context, days []*accountDay) ([]period, error) {
+	from, to := days[0].from, days[0].to
+	args := make([]any, 0, len(days)+2)
+	for _, day := range days {
+		from, to = minTime(from, day.from), maxTime(to, day.to)
+		args = append(args, day.accountId)
+	}
+	args = append(args, to, from)
+	rows, err := b.dbConn.QueryContext(
+		ctx,
+		"SELECT `account_id`, `from`, `estimated_to` FROM subscription "+
+			"WHERE `closed_at` IS NOT NULL AND account_id IN (?"+strings.Repeat(", ?", len(days)-1)+") AND `from` < ? AND `estimated_to` > ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []period
+	for rows.Next() {
+		var p period
+		if err := rows.Scan(&p.accountId, &p.from, &p.to); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// refuseClosed returns ErrPeriodClosed for the days of date that overlap a closed period.
+// The worker keeps late logs of closed periods out of the usage tables, adjusting or
+// rejecting them, but they stay in the archives, so overwriting an invoiced day would
+// bill adjusted usage twice and rejected usage after all.
+func refuseClosed(date time.Time, days []*accountDay, closed []period) error {
+	var errs []error
+	for _, day := range days {
+		if slices.ContainsFunc(closed, day.overlaps) {
+			errs = append(errs, fmt.Errorf("%w: %s of account %d", ErrPeriodClosed, date.Format(time.DateOnly), day.accountId))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// overlaps reports whether the day is in the period p of its account.
+func (a *accountDay) overlaps(p period) bool {
+	return p.accountId == a.accountId && p.from.Before(a.to) && a.from.Before(p.to)
+}
+
+// accountLocations returns the timezones of accountIds, or of all accounts when empty.
+func accountLocations(ctx context.Context, conn db.DBConnection, accountIds []uint64) (map[uint64]*time.Location, error) {
+	query := "SELECT id, timezone FROM account"
+	var args []any
+	if len(accountIds) > 0 {
+		query += " WHERE id IN (?" + strings.Repeat(", ?", len(accountIds)-1) + ")"
+		for _, id := range accountIds {
+			args = append(args, id)
+		}
+	}
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locs := make(map[uint64]*time.Location)
+	for rows.Next() {
+		var id uint64
+		var timezone sql.NullString
+		if err := rows.Scan(&id, &timezone); err != nil {
+			return nil, err
+		}
+		loc, err := Location(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("account %d: %w", id, err)
+		}
+		locs[id] = loc
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range accountIds {
+		if locs[id] == nil {
+			return nil, fmt.Errorf("account %d not found", id)
+		}
+	}
+	return locs, nil
+}
+
+// read returns the logs of the days in the archives. Logs redelivered after a failed
+// flush are stored twice and dropped by request id; logs written before request ids were
+// recorded cannot be told apart.
+func (b *Backfiller) read(ctx context.Context, days []*accountDay) ([]types.ApiAccessLog, error) {
+	type requestKey struct {
+		accountId int64
+		requestId string
+		timestamp time.Time
+	}
+	seen := make(map[requestKey]bool)
+
+	byId := make(map[uint64]*accountDay, len(days))
+	from, to := days[0].from, days[0].to
+	for _, day := range days {
+		byId[day.accountId] = day
+		from, to = minTime(from, day.from), maxTime(to, day.to)
+	}
+
+	var logs []types.ApiAccessLog
+	for p := logstore.PartitionOf(from); p.Hour().Before(to); p = logstore.PartitionOf(p.Hour().Add(time.Hour)) {
+		keys, err := b.source.Keys(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			objectLogs, err := b.source.Read(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range objectLogs {
+				day := byId[uint64(l.AccountId)]
+				if day == nil || l.Timestamp.Before(day.from) || !l.Timestamp.Before(day.to) {
+					continue
+				}
+				if l.RequestId != "" {
+					k := requestKey{l.AccountId, l.RequestId, l.Timestamp}
+					if seen[k] {
+						continue
+					}
+					seen[k] = true
+				}
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// load returns the stored rows the days cover, including the minutes of the hours crossing their edges.
+func (b *Backfiller) load(ctx context.Context, days []*accountDay, accountIds []uint64) (Buckets, error) {
+	from, to := days[0].hourFrom, days[0].hourTo
+	for _, day := range days {
+		from, to = minTime(from, day.hourFrom), maxTime(to, day.hourTo)
+	}
+	date := GranularityDay.Key(days[0].from, days[0].loc)
+	nextDate := GranularityDay.Key(NextDay(days[0].from), days[0].loc)
+
+	buckets := make(Buckets)
+	for _, g := range []Granularity{GranularityMinute, GranularityHour, GranularityDay} {
+		table, column, _ := g.table()
+		counts := "0, 0"
+		if g == GranularityMinute {
+			counts = "`billable_requests`, `non_billable_requests`"
+		}
+		lower, upper := g.Key(from, time.UTC), g.Key(to, time.UTC)
+		if g == GranularityDay {
+			lower, upper = date, nextDate
+		}
+		where, args := rangeCondition(column, lower, upper, accountIds)
+		rows, err := b.dbConn.QueryContext(
+			ctx,
+			fmt.Sprintf("SELECT `account_id`, `meter`, `%s`, `usage`, %s FROM %s WHERE %s", column, counts, table, where),
+			args...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var k BucketKey
+			var c Counts
+			if err := rows.Scan(&k.AccountId, &k.Meter, &k.Bucket, &c.Usage, &c.BillableRequests, &c.NonBillableRequests); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			k.Granularity = g
+			buckets[k] = c
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return buckets, nil
+}
+
+// overwrite replaces the rows the days rewrite with the recomputed ones.
+func (b *Backfiller) overwrite(ctx context.Context, days []*accountDay, stored, recomputed Buckets) error {
+	return db.RunInTxn(ctx, b.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, day := range days {
+			for _, g := range []Granularity{GranularityMinute, GranularityHour, GranularityDay} {
+				table, column, _ := g.table()
+				var lower, upper string
+				switch g {
+				case GranularityMinute:
+					lower, upper = g.Key(day.from, time.UTC), g.Key(day.to, time.UTC)
+				case GranularityHour:
+					lower, upper = g.Key(day.hourFrom, time.UTC), g.Key(day.hourTo, time.UTC)
+				default:
+					lower, upper = g.Key(day.from, day.loc), g.Key(day.to, day.loc)
+				}
+				where, args := rangeCondition(column, lower, upper, []uint64{day.accountId})
+				if _, err := txn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", table, where), args...); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, g := range []Granularity{GranularityMinute, GranularityHour, GranularityDay} {
+			table, column, _ := g.table()
+			columns := fmt.Sprintf("`account_id`, `meter`, `%s`, `usage`", column)
+			if g == GranularityMinute {
+				columns += ", `billable_requests`, `non_billable_requests`"
+			}
+			var rows [][]any
+			for k, c := range recomputed {
+				if k.Granularity != g {
+					continue
+				}
+				row := []any{k.AccountId, k.Meter, k.Bucket, c.Usage}
+				if g == GranularityMinute {
+					row = append(row, c.BillableRequests, c.NonBillableRequests)
+				}
+				rows = append(rows, row)
+			}
+			// Keeps each statement well below the placeholder limit of MySQL.
+			for chunk := range slices.Chunk(rows, 1000) {
+				var args []any
+				for _, row := range chunk {
+					args = append(args, row...)
+				}
+				if _, err := txn.ExecContext(
+					ctx,
+					fmt.Sprintf("INSERT INTO %s (%s) %s", table, columns, db.MakeValues(len(chunk[0]), len(chunk))),
+					args...,
+				); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, day := range days {
+			date := GranularityDay.Key(day.from, day.loc)
+			if err := audit.Record(ctx, &audit.Event{
+				Action:   "usage.backfill",
+				Entity:   "account",
+				EntityId: day.accountId,
+				Before:   map[string]any{"date": date, "timezone": day.loc.String(), "usage": stored.dayUsage(day.accountId, date)},
+				After:    map[string]any{"date": date, "timezone": day.loc.String(), "usage": recomputed.dayUsage(day.accountId, date)},
+				Reason:   "recomputed from access log archives",
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rangeCondition matches the buckets in [from, to) of accountIds, or of all accounts when empty.
+func rangeCondition(column, from, to string, accountIds []uint64) (string, []any) {
+	where := fmt.Sprintf("`%s` >= ? AND `%s` < ?", column, column)
+	args := []any{from, to}
+	if len(accountIds) > 0 {
+		where += " AND account_id IN (?" + strings.Repeat(", ?", len(accountIds)-1) + ")"
+		for _, id := range accountIds {
+			args = append(args, id)
+		}
+	}
+	return where, args
+}
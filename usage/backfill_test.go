@@ -0,0 +1,196 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/logstore"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestRecompute(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	got, err := Recompute([]types.ApiAccessLog{
+		{AccountId: 1, Timestamp: base},
+		{AccountId: 1, Timestamp: base.Add(time.Minute), Meter: "api1", Usage: 3},
+		{AccountId: 1, Timestamp: base.Add(5*time.Hour + 30*time.Minute), Meter: "api1", Usage: 2},
+		{AccountId: 1, Timestamp: base.Add(6 * time.Hour), Meter: "api1", Usage: 2, NonBillable: true},
+		{AccountId: 2, Timestamp: base, Meter: "api1", Usage: 4},
+	}, mustLoadLocation(t, "Asia/Tokyo"))
+	require.NoError(t, err)
+
+	assert.Equal(t, Buckets{
+		{GranularityMinute, 1, "", "202507011000"}:     {Usage: 1, BillableRequests: 1},
+		{GranularityMinute, 1, "api1", "202507011001"}: {Usage: 3, BillableRequests: 1},
+		{GranularityMinute, 1, "api1", "202507011530"}: {Usage: 2, BillableRequests: 1},
+		{GranularityMinute, 1, "api1", "202507011600"}: {NonBillableRequests: 1},
+		{GranularityMinute, 2, "api1", "202507011000"}: {Usage: 4, BillableRequests: 1},
+		{GranularityHour, 1, "", "2025070110"}:         {Usage: 1},
+		{GranularityHour, 1, "api1", "2025070110"}:     {Usage: 3},
+		{GranularityHour, 1, "api1", "2025070115"}:     {Usage: 2},
+		{GranularityHour, 1, "api1", "2025070116"}:     {},
+		{GranularityHour, 2, "api1", "2025070110"}:     {Usage: 4},
+		{GranularityDay, 1, "", "20250701"}:            {Usage: 1},
+		{GranularityDay, 1, "api1", "20250701"}:        {Usage: 3},
+		{GranularityDay, 1, "api1", "20250702"}:        {Usage: 2},
+		{GranularityDay, 2, "api1", "20250701"}:        {Usage: 4},
+	}, got)
+}
+
+func TestDiffBuckets(t *testing.T) {
+	t.Parallel()
+
+	stored := Buckets{
+		{GranularityDay, 1, "", "20250701"}:        {Usage: 5},
+		{GranularityMinute, 1, "", "202507011000"}: {Usage: 5, BillableRequests: 5},
+		{GranularityMinute, 2, "", "202507011000"}: {Usage: 1, BillableRequests: 1},
+		{GranularityMinute, 3, "", "202507011000"}: {Usage: 1, BillableRequests: 1},
+	}
+	recomputed := Buckets{
+		{GranularityDay, 1, "", "20250701"}:        {Usage: 6},
+		{GranularityMinute, 1, "", "202507011000"}: {Usage: 5, BillableRequests: 5},
+		{GranularityMinute, 1, "", "202507011001"}: {Usage: 1, BillableRequests: 1},
+		{GranularityMinute, 2, "", "202507011000"}: {Usage: 1, BillableRequests: 1, NonBillableRequests: 1},
+	}
+
+	assert.Equal(t, []*Diff{
+		{GranularityMinute, 1, "", "202507011001", Counts{}, Counts{Usage: 1, BillableRequests: 1}},
+		{GranularityMinute, 2, "", "202507011000", Counts{Usage: 1, BillableRequests: 1}, Counts{Usage: 1, BillableRequests: 1, NonBillableRequests: 1}},
+		{GranularityMinute, 3, "", "202507011000", Counts{Usage: 1, BillableRequests: 1}, Counts{}},
+		{GranularityDay, 1, "", "20250701", Counts{Usage: 5}, Counts{Usage: 6}},
+	}, DiffBuckets(stored, recomputed))
+	assert.Empty(t, DiffBuckets(recomputed, recomputed))
+}
+
+func TestAccountDays(t *testing.T) {
+	t.Parallel()
+
+	newYork := mustLoadLocation(t, "America/New_York")
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+	locs := map[uint64]*time.Location{1: time.UTC, 2: newYork, 3: kolkata}
+	date := time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	ctx := now.WithContext(context.Background(), time.Date(2025, 3, 10, 3, 59, 0, 0, time.UTC))
+	_, err := accountDays(ctx, date, locs)
+	assert.ErrorIs(t, err, ErrDayNotClosed, "the day has not ended in New York")
+
+	ctx = now.WithContext(context.Background(), time.Date(2025, 3, 10, 4, 0, 0, 0, time.UTC))
+	days, err := accountDays(ctx, date, locs)
+	require.NoError(t, err)
+	require.Len(t, days, 3)
+
+	assert.Equal(t, uint64(1), days[0].accountId)
+	assert.Equal(t, 24*time.Hour, days[0].to.Sub(days[0].from))
+
+	assert.True(t, time.Date(2025, 3, 9, 5, 0, 0, 0, time.UTC).Equal(days[1].from))
+	assert.Equal(t, 23*time.Hour, days[1].to.Sub(days[1].from), "spring forward")
+
+	assert.True(t, time.Date(2025, 3, 8, 18, 30, 0, 0, time.UTC).Equal(days[2].from))
+	assert.True(t, time.Date(2025, 3, 8, 18, 0, 0, 0, time.UTC).Equal(days[2].hourFrom))
+	assert.True(t, time.Date(2025, 3, 9, 19, 0, 0, 0, time.UTC).Equal(days[2].hourTo))
+}
+
+func TestAccountDay_recompute(t *testing.T) {
+	t.Parallel()
+
+	// The day starts and ends at half past a UTC hour.
+	day := newAccountDay(1, mustLoadLocation(t, "Asia/Kolkata"), 2025, 7, 1)
+	stored := Buckets{
+		{GranularityMinute, 1, "", "202506301829"}: {Usage: 1, BillableRequests: 1},
+		{GranularityMinute, 1, "", "202506301830"}: {Usage: 5, BillableRequests: 5},
+		{GranularityMinute, 1, "", "202507011900"}: {Usage: 2, BillableRequests: 2},
+		{GranularityHour, 1, "", "2025063018"}:     {Usage: 6},
+		{GranularityHour, 1, "", "2025070119"}:     {Usage: 2},
+		{GranularityDay, 1, "", "20250701"}:        {Usage: 5},
+	}
+	minutes := Buckets{
+		{GranularityMinute, 1, "", "202506301830"}: {Usage: 3, BillableRequests: 3},
+		{GranularityMinute, 1, "", "202507011829"}: {Usage: 4, BillableRequests: 4},
+	}
+
+	got, err := day.recompute(stored, minutes)
+	require.NoError(t, err)
+	assert.Equal(t, Buckets{
+		{GranularityMinute, 1, "", "202506301830"}: {Usage: 3, BillableRequests: 3},
+		{GranularityMinute, 1, "", "202507011829"}: {Usage: 4, BillableRequests: 4},
+		{GranularityHour, 1, "", "2025063018"}:     {Usage: 4},
+		{GranularityHour, 1, "", "2025070118"}:     {Usage: 4},
+		{GranularityDay, 1, "", "20250701"}:        {Usage: 7},
+	}, got, "edge hours keep the stored minutes of the neighbouring days")
+}
+
+func TestRefuseClosed(t *testing.T) {
+	t.Parallel()
+
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	date := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	days := []*accountDay{newAccountDay(1, time.UTC, 2025, 7, 1), newAccountDay(2, tokyo, 2025, 7, 1)}
+
+	// Tokyo's July begins at 15:00 UTC on June 30.
+	juneTokyo := period{accountId: 2, from: time.Date(2025, 6, 1, 0, 0, 0, 0, tokyo), to: time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo)}
+	julyUTC := period{accountId: 1, from: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)}
+	juneUTC := period{accountId: 1, from: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)}
+
+	assert.NoError(t, refuseClosed(date, days, nil))
+	assert.NoError(t, refuseClosed(date, days, []period{juneTokyo, juneUTC}), "the periods end when the days start")
+
+	err := refuseClosed(date, days, []period{juneTokyo, julyUTC})
+	assert.ErrorIs(t, err, ErrPeriodClosed)
+	assert.EqualError(t, err, "billing period is closed: 2025-07-01 of account 1")
+}
+
+func TestBackfiller_read(t *testing.T) {
+	t.Parallel()
+
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	days := []*accountDay{newAccountDay(1, time.UTC, 2025, 7, 1), newAccountDay(2, tokyo, 2025, 7, 1)}
+	from, to := days[0].from, days[0].to
+	dir := t.TempDir()
+	put := func(key string, logs ...types.ApiAccessLog) {
+		data, err := logstore.Encode(logs)
+		require.NoError(t, err)
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+
+	first := logstore.PartitionOf(from)
+	last := logstore.PartitionOf(to.Add(-time.Second))
+	put(first.ObjectKey("a"),
+		types.ApiAccessLog{AccountId: 1, RequestId: "r1", Timestamp: from},
+		types.ApiAccessLog{AccountId: 2, RequestId: "r2", Timestamp: from},
+		types.ApiAccessLog{AccountId: 1, Timestamp: from.Add(time.Second)},
+		types.ApiAccessLog{AccountId: 3, RequestId: "r6", Timestamp: from},
+	)
+	// A redelivered batch stores the same requests again under another key.
+	put(first.ObjectKey("b"),
+		types.ApiAccessLog{AccountId: 1, RequestId: "r1", Timestamp: from},
+		types.ApiAccessLog{AccountId: 1, RequestId: "r1", Timestamp: from.Add(time.Minute)},
+	)
+	put(last.ObjectKey("c"),
+		types.ApiAccessLog{AccountId: 1, RequestId: "r3", Timestamp: to.Add(-time.Second)},
+		types.ApiAccessLog{AccountId: 1, RequestId: "r4", Timestamp: to},
+		types.ApiAccessLog{AccountId: 2, RequestId: "r7", Timestamp: to.Add(-time.Second)},
+	)
+	// The day of Tokyo starts 9 hours before the day of UTC.
+	put(logstore.PartitionOf(days[1].from).ObjectKey("d"), types.ApiAccessLog{AccountId: 2, RequestId: "r8", Timestamp: days[1].from})
+	put(logstore.PartitionOf(to).ObjectKey("e"), types.ApiAccessLog{AccountId: 1, RequestId: "r5", Timestamp: to})
+
+	b := NewBackfiller(nil, logstore.NewDirSource(dir))
+	logs, err := b.read(context.Background(), days)
+	require.NoError(t, err)
+	var requestIds []string
+	for _, l := range logs {
+		requestIds = append(requestIds, l.RequestId)
+	}
+	assert.ElementsMatch(t, []string{"r1", "r2", "", "r1", "r3", "r8"}, requestIds)
+}
@@ -0,0 +1,224 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+)
+
+type Granularity string
+
+const (
+	GranularityMinute Granularity = "minute"
+	GranularityHour   Granularity = "hour"
+	GranularityDay    Granularity = "day"
+)
+
+var (
+	ErrInvalidGranularity = errors.New("invalid granularity")
+	ErrInvalidPageToken   = errors.New("invalid page token")
+)
+
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case GranularityMinute, GranularityHour, GranularityDay:
+		return g, nil
+	case "":
+		return GranularityHour, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidGranularity, s)
+}
+
+// table returns the aggregate table, its bucket column and the bucket key layout.
+func (g Granularity) table() (string, string, string) {
+	switch g {
+	case GranularityMinute:
+		return "every_minute_api_usage", "minute", "200601021504"
+	case GranularityHour:
+		return "houry_api_usage", "hour", "2006010215"
+	default:
+		return "daily_api_usage", "date", "20060102"
+	}
+}
+
+func (g Granularity) Layout() string {
+	_, _, layout := g.table()
+	return layout
+}
+
+type Record struct {
+	Bucket time.Time `json:"bucket"`
+	Usage  uint64    `json:"usage"`
+}
+
+type Page struct {
+	Records       []*Record `json:"records"`
+	NextPageToken string    `json:"next_page_token,omitempty"`
+}
+
+type Query struct {
+	AccountId   uint64
+	Granularity Granularity
+	From        time.Time
+	To          time.Time
+	PageToken   string
+	Limit       int
+}
+
+type CurrentPeriod struct {
+	SubscriptionId uint64    `json:"subscription_id"`
+	From           time.Time `json:"from"`
+	EstimatedTo    time.Time `json:"estimated_to"`
+	Usage          uint64    `json:"usage"`
+}
+
+type Reader interface {
+	List(ctx context.Context, q *Query) (*Page, error)
+	CurrentPeriod(ctx context.Context, accountId uint64) (*CurrentPeriod, error)
+	FreeCreditBalance(ctx context.Context, accountId uint64) (int64, error)
+}
+
+type reader struct {
+	dbConn *sql.DB
+}
+
+func NewReader(dbConn *sql.DB) Reader {
+	return &reader{
+		dbConn: dbConn,
+	}
+}
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+func (r *reader) List(ctx context.Context, q *Query) (*Page, error) {
+	table, column, _ := q.Granularity.table()
+
+	loc, err := AccountLocation(ctx, r.dbConn, q.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	fromOp, from, err := q.start(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// the buckets sum the usage of all meters
+	query := fmt.Sprintf(
+		"SELECT `%s`, SUM(`usage`) FROM %s WHERE account_id = ? AND `%s` %s ? AND `%s` < ? GROUP BY `%s` ORDER BY `%s` ASC LIMIT ?",
+		column, table, column, fromOp, column, column, column,
+	)
+	rows, err := r.dbConn.QueryContext(ctx, query, q.AccountId, from, q.Granularity.Key(q.To, loc), limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	var records []*Record
+	for rows.Next() {
+		var key string
+		var usage uint64
+		if err := rows.Scan(&key, &usage); err != nil {
+			return nil, err
+		}
+		bucket, err := q.Granularity.Parse(key, loc)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		records = append(records, &Record{
+			Bucket: bucket.In(loc),
+			Usage:  usage,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return paginate(records, keys, limit), nil
+}
+
+// start returns the operator and the key the page starts from.
+//
+// Bucket keys are fixed width strings, so lexical order equals time order
+// and the last key of a page doubles as the cursor for the next one.
+func (q *Query) start(loc *time.Location) (string, string, error) {
+	if q.PageToken == "" {
+		return ">=", q.Granularity.Key(q.From, loc), nil
+	}
+	if _, err := time.Parse(q.Granularity.Layout(), q.PageToken); err != nil {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidPageToken, q.PageToken)
+	}
+	return ">", q.PageToken, nil
+}
+
+// paginate cuts records, fetched with one extra row, to limit and sets the token of the next page.
+func paginate(records []*Record, keys []string, limit int) *Page {
+	page := &Page{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		page.NextPageToken = keys[limit-1]
+	}
+	return page
+}
+
+func (r *reader) CurrentPeriod(ctx context.Context, accountId uint64) (*CurrentPeriod, error) {
+	current := now.FromContext(ctx)
+
+	var period CurrentPeriod
+	if err := r.dbConn.QueryRowContext(
+		ctx,
+		"SELECT s.id, s.from, s.estimated_to FROM subscription s "+
+			"WHERE s.account_id = ? AND s.from <= ? AND s.estimated_to > ? "+
+			"ORDER BY s.from DESC LIMIT 1",
+		accountId,
+		current,
+		current,
+	).Scan(
+		&period.SubscriptionId,
+		&period.From,
+		&period.EstimatedTo,
+	); err != nil {
+		return nil, err
+	}
+
+	// every_minute_api_usage is written by the worker on each flush, so it is
+	// the freshest source for the period so far.
+	if err := r.dbConn.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(SUM(`usage`), 0) FROM every_minute_api_usage WHERE account_id = ? AND `minute` >= ? AND `minute` <= ?",
+		accountId,
+		GranularityMinute.Key(period.From, time.UTC),
+		GranularityMinute.Key(current, time.UTC),
+	).Scan(&period.Usage); err != nil {
+		return nil, err
+	}
+
+	return &period, nil
+}
+
+func (r *reader) FreeCreditBalance(ctx context.Context, accountId uint64) (int64, error) {
+	var balance int64
+	if err := r.dbConn.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(SUM(credit), 0) FROM account_free_credit_balance WHERE account_id = ?",
+		accountId,
+	).Scan(&balance); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
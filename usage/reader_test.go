@@ -0,0 +1,130 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGranularity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    Granularity
+		wantErr bool
+	}{
+		{in: "", want: GranularityHour},
+		{in: "minute", want: GranularityMinute},
+		{in: "hour", want: GranularityHour},
+		{in: "day", want: GranularityDay},
+		{in: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseGranularity(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidGranularity)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQuery_start(t *testing.T) {
+	t.Parallel()
+
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo)
+
+	tests := []struct {
+		name    string
+		q       Query
+		wantOp  string
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name:    "first page of hours",
+			q:       Query{Granularity: GranularityHour, From: from},
+			wantOp:  ">=",
+			wantKey: "2025063015",
+		},
+		{
+			name:    "first page of days",
+			q:       Query{Granularity: GranularityDay, From: from},
+			wantOp:  ">=",
+			wantKey: "20250701",
+		},
+		{
+			name:    "next page",
+			q:       Query{Granularity: GranularityHour, From: from, PageToken: "2025063020"},
+			wantOp:  ">",
+			wantKey: "2025063020",
+		},
+		{
+			name:    "token of another granularity",
+			q:       Query{Granularity: GranularityHour, From: from, PageToken: "20250701"},
+			wantErr: true,
+		},
+		{
+			name:    "garbage token",
+			q:       Query{Granularity: GranularityMinute, From: from, PageToken: "abc"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, key, err := tt.q.start(tokyo)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPageToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantOp, op)
+			assert.Equal(t, tt.wantKey, key)
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+
+	records := func(n int) ([]*Record, []string) {
+		var records []*Record
+		var keys []string
+		for i := range n {
+			bucket := time.Date(2025, 7, 1, i, 0, 0, 0, time.UTC)
+			records = append(records, &Record{Bucket: bucket, Usage: uint64(i)})
+			keys = append(keys, GranularityHour.Key(bucket, time.UTC))
+		}
+		return records, keys
+	}
+
+	tests := []struct {
+		name      string
+		n         int
+		limit     int
+		wantLen   int
+		wantToken string
+	}{
+		{name: "empty", n: 0, limit: 2, wantLen: 0},
+		{name: "last page", n: 2, limit: 2, wantLen: 2},
+		{name: "more pages", n: 3, limit: 2, wantLen: 2, wantToken: "2025070101"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, keys := records(tt.n)
+			page := paginate(records, keys, tt.limit)
+			assert.Len(t, page.Records, tt.wantLen)
+			assert.Equal(t, tt.wantToken, page.NextPageToken)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/audit"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+)
+
+const shiftKeysAction = "usage.shift_keys"
+
+// ShiftLocalKeys re-keys the every_minute_api_usage and houry_api_usage rows written
+// before `before`, when the first worker keying them in UTC started, from the local
+// minutes and hours of loc, the zone of the earlier workers, to UTC.
+//
+// Each table is shifted in one transaction recording a usage.shift_keys audit event, and
+// a table with such an event is skipped, so running it again changes nothing.
+// Rows created before and updated after `before` hold usage of both kinds of workers and
+// cannot be split; they are left as they are and returned, to be recomputed with
+// backfillUsage --overwrite.
+func ShiftLocalKeys(ctx context.Context, dbConn *sql.DB, loc *time.Location, before time.Time) (Buckets, error) {
+	mixed := make(Buckets)
+	for _, g := range []Granularity{GranularityMinute, GranularityHour} {
+		if err := db.RunInTxn(ctx, dbConn, func(ctx context.Context) error {
+			txn, err := db.GetTxn(ctx)
+			if err != nil {
+				return err
+			}
+			table, column, _ := g.table()
+
+			events, err := audit.List(ctx, txn, &audit.Filter{Action: shiftKeysAction, Entity: table, Limit: 1})
+			if err != nil {
+				return err
+			}
+			if len(events) > 0 {
+				slog.Info("Usage keys are already shifted", "table", table, "at", events[0].CreatedAt)
+				return nil
+			}
+
+			counts := "0, 0"
+			if g == GranularityMinute {
+				counts = "`billable_requests`, `non_billable_requests`"
+			}
+			rows, err := txn.QueryContext(
+				ctx,
+				fmt.Sprintf("SELECT `account_id`, `meter`, `%s`, `usage`, %s, `updated_at` >= ? FROM %s WHERE `created_at` < ? FOR UPDATE", column, counts, table),
+				before, before,
+			)
+			if err != nil {
+				return err
+			}
+			local := make(Buckets)
+			var numMixed int
+			for rows.Next() {
+				var k BucketKey
+				var c Counts
+				var updated bool
+				if err := rows.Scan(&k.AccountId, &k.Meter, &k.Bucket, &c.Usage, &c.BillableRequests, &c.NonBillableRequests, &updated); err != nil {
+					rows.Close()
+					return err
+				}
+				k.Granularity = g
+				if updated {
+					mixed[k] = c
+					numMixed++
+					continue
+				}
+				local[k] = c
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			utc, err := shiftBuckets(local, loc)
+			if err != nil {
+				return err
+			}
+			if _, err := txn.ExecContext(
+				ctx,
+				fmt.Sprintf("DELETE FROM %s WHERE `created_at` < ? AND `updated_at` < ?", table),
+				before, before,
+			); err != nil {
+				return err
+			}
+			if err := addBuckets(ctx, txn, g, utc); err != nil {
+				return err
+			}
+
+			slog.Info("Shifted usage keys to UTC", "table", table, "zone", loc.String(), "rows", len(local), "mixed", numMixed)
+			return audit.Record(ctx, &audit.Event{
+				Action: shiftKeysAction,
+				Entity: table,
+				Before: map[string]any{"zone": loc.String(), "before": before.UTC(), "rows": len(local)},
+				After:  map[string]any{"zone": "UTC", "rows": len(utc), "mixed": numMixed},
+				Reason: "minutes and hours are keyed in UTC",
+			})
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return mixed, nil
+}
+
+// shiftBuckets re-keys minute and hour buckets from local time in loc to UTC.
+// The repeated hour of a DST fall back has one local key, so it is kept in the first of the two UTC hours.
+func shiftBuckets(local Buckets, loc *time.Location) (Buckets, error) {
+	utc := make(Buckets, len(local))
+	for k, c := range local {
+		t, err := time.ParseInLocation(k.Granularity.Layout(), k.Bucket, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", k.Granularity, k.Bucket, err)
+		}
+		k.Bucket = k.Granularity.Key(t, time.UTC)
+		sum := utc[k]
+		utc[k] = Counts{
+			Usage:               sum.Usage + c.Usage,
+			BillableRequests:    sum.BillableRequests + c.BillableRequests,
+			NonBillableRequests: sum.NonBillableRequests + c.NonBillableRequests,
+		}
+	}
+	return utc, nil
+}
+
+// addBuckets adds the buckets of granularity g to their rows, which exist only when written by a UTC worker.
+func addBuckets(ctx context.Context, txn db.DBConnection, g Granularity, buckets Buckets) error {
+	table, column, _ := g.table()
+	columns := fmt.Sprintf("`account_id`, `meter`, `%s`, `usage`", column)
+	update := "`usage` = `usage` + VALUES(`usage`)"
+	if g == GranularityMinute {
+		columns += ", `billable_requests`, `non_billable_requests`"
+		update += ", `billable_requests` = `billable_requests` + VALUES(`billable_requests`), " +
+			"`non_billable_requests` = `non_billable_requests` + VALUES(`non_billable_requests`)"
+	}
+	// Keeps each statement well below the placeholder limit of MySQL.
+	for chunk := range slices.Chunk(slices.Collect(maps.Keys(buckets)), 1000) {
+		var args []any
+		for _, k := range chunk {
+			c := buckets[k]
+			args = append(args, k.AccountId, k.Meter, k.Bucket, c.Usage)
+			if g == GranularityMinute {
+				args = append(args, c.BillableRequests, c.NonBillableRequests)
+			}
+		}
+		if _, err := txn.ExecContext(
+			ctx,
+			fmt.Sprintf("INSERT INTO %s (%s) %s ON DUPLICATE KEY UPDATE %s, `updated_at` = NOW()", table, columns, db.MakeValues(len(args)/len(chunk), len(chunk)), update),
+			args...,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShiftBuckets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		zone  string
+		local Buckets
+		want  Buckets
+	}{
+		{
+			name: "east of UTC",
+			zone: "Asia/Tokyo",
+			local: Buckets{
+				{GranularityMinute, 1, "", "202507010830"}:     {Usage: 3, BillableRequests: 2, NonBillableRequests: 1},
+				{GranularityMinute, 1, "api1", "202507010830"}: {Usage: 4, BillableRequests: 4},
+				{GranularityHour, 1, "", "2025070108"}:         {Usage: 3},
+			},
+			want: Buckets{
+				{GranularityMinute, 1, "", "202506302330"}:     {Usage: 3, BillableRequests: 2, NonBillableRequests: 1},
+				{GranularityMinute, 1, "api1", "202506302330"}: {Usage: 4, BillableRequests: 4},
+				{GranularityHour, 1, "", "2025063023"}:         {Usage: 3},
+			},
+		},
+		{
+			name: "half hour offset",
+			zone: "Asia/Kolkata",
+			local: Buckets{
+				{GranularityMinute, 2, "", "202507010015"}: {Usage: 1, BillableRequests: 1},
+			},
+			want: Buckets{
+				{GranularityMinute, 2, "", "202506301845"}: {Usage: 1, BillableRequests: 1},
+			},
+		},
+		{
+			name: "DST",
+			zone: "America/New_York",
+			local: Buckets{
+				{GranularityHour, 1, "", "2025110100"}: {Usage: 1},
+				// both 01 hours of the fall back, kept in the first
+				{GranularityHour, 1, "", "2025110201"}: {Usage: 2},
+				{GranularityHour, 1, "", "2025110202"}: {Usage: 3},
+			},
+			want: Buckets{
+				{GranularityHour, 1, "", "2025110104"}: {Usage: 1},
+				{GranularityHour, 1, "", "2025110205"}: {Usage: 2},
+				{GranularityHour, 1, "", "2025110207"}: {Usage: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := shiftBuckets(tt.local, mustLoadLocation(t, tt.zone))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := shiftBuckets(Buckets{{GranularityMinute, 1, "", "2025070110"}: {}}, mustLoadLocation(t, "Asia/Tokyo"))
+	assert.Error(t, err)
+}
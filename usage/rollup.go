@@ -0,0 +1,100 @@
+package usage
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+)
+
+// Days returns the start of each day in loc that overlaps [from, to).
+func Days(from, to time.Time, loc *time.Location) []time.Time {
+	var days []time.Time
+	for day := StartOfDay(from, loc); day.Before(to); day = NextDay(day) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// RollUpDays writes the daily_api_usage of the days in loc that overlap [from, to) from
+// every_minute_api_usage, one row per meter. The rows of a day are replaced, so rolling it
+// up again picks up late minutes and backfills.
+func RollUpDays(ctx context.Context, conn dto.DB, accountId uint64, loc *time.Location, from, to time.Time) error {
+	for _, day := range Days(from, to, loc) {
+		date := GranularityDay.Key(day, loc)
+		if _, err := conn.ExecContext(
+			ctx,
+			"DELETE FROM daily_api_usage WHERE account_id = ? AND `date` = ?",
+			accountId,
+			date,
+		); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(
+			ctx,
+			"INSERT INTO daily_api_usage (`account_id`, `meter`, `date`, `usage`) "+
+				"SELECT ?, `meter`, ?, SUM(`usage`) FROM every_minute_api_usage WHERE account_id = ? AND `minute` >= ? AND `minute` < ? "+
+				"GROUP BY `meter`",
+			accountId,
+			date,
+			accountId,
+			GranularityMinute.Key(day, time.UTC),
+			GranularityMinute.Key(NextDay(day), time.UTC),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddRollUps adds the minutes upserted by a worker flush to their UTC hours in houry_api_usage
+// and their days in the account's timezone in daily_api_usage, so both include live usage.
+// RollUpDays replaces the days with the sum of their minutes when the period is invoiced.
+func AddRollUps(ctx context.Context, txn db.DBConnection, minutes []*dto.EveryMinuteAPIUsage) error {
+	if len(minutes) == 0 {
+		return nil
+	}
+	accountIds := make(map[uint64]struct{})
+	for _, m := range minutes {
+		accountIds[m.AccountID] = struct{}{}
+	}
+	locs, err := accountLocations(ctx, txn, slices.Sorted(maps.Keys(accountIds)))
+	if err != nil {
+		return err
+	}
+	rollUps, err := rollUpMinutes(minutes, locs)
+	if err != nil {
+		return err
+	}
+	for _, g := range []Granularity{GranularityHour, GranularityDay} {
+		if err := addBuckets(ctx, txn, g, rollUps[g]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollUpMinutes returns the hours and days the minutes sum up to by granularity, the days in the timezone of each account.
+func rollUpMinutes(minutes []*dto.EveryMinuteAPIUsage, locs map[uint64]*time.Location) (map[Granularity]Buckets, error) {
+	buckets := make(Buckets, len(minutes))
+	for _, m := range minutes {
+		k := BucketKey{GranularityMinute, m.AccountID, m.Meter, m.Minute}
+		buckets[k] = Counts{Usage: buckets[k].Usage + m.Usage}
+	}
+	rollUps := map[Granularity]Buckets{GranularityHour: {}, GranularityDay: {}}
+	for accountId, accountMinutes := range byAccount(buckets) {
+		rolled, err := RollUp(accountMinutes, locs[accountId])
+		if err != nil {
+			return nil, err
+		}
+		for k, c := range rolled {
+			if k.Granularity != GranularityMinute {
+				rollUps[k.Granularity][k] = c
+			}
+		}
+	}
+	return rollUps, nil
+}
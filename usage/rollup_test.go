@@ -0,0 +1,40 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+)
+
+func TestRollUpMinutes(t *testing.T) {
+	t.Parallel()
+
+	got, err := rollUpMinutes([]*dto.EveryMinuteAPIUsage{
+		{AccountID: 1, Minute: "202507011459", Usage: 1},
+		{AccountID: 1, Minute: "202507011500", Usage: 2},
+		{AccountID: 1, Meter: "api1", Minute: "202507011500", Usage: 3},
+		{AccountID: 2, Minute: "202507011500", Usage: 4},
+		{AccountID: 2, Minute: "202507011510", BillableRequests: 1},
+	}, map[uint64]*time.Location{1: mustLoadLocation(t, "Asia/Tokyo"), 2: time.UTC})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[Granularity]Buckets{
+		GranularityHour: {
+			{GranularityHour, 1, "", "2025070114"}:     {Usage: 1},
+			{GranularityHour, 1, "", "2025070115"}:     {Usage: 2},
+			{GranularityHour, 1, "api1", "2025070115"}: {Usage: 3},
+			{GranularityHour, 2, "", "2025070115"}:     {Usage: 4},
+		},
+		GranularityDay: {
+			// 15:00 UTC is midnight in Tokyo
+			{GranularityDay, 1, "", "20250701"}:     {Usage: 1},
+			{GranularityDay, 1, "", "20250702"}:     {Usage: 2},
+			{GranularityDay, 1, "api1", "20250702"}: {Usage: 3},
+			{GranularityDay, 2, "", "20250701"}:     {Usage: 4},
+		},
+	}, got)
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+)
+
+// Location returns the location of an account timezone, UTC when it is unset.
+func Location(timezone sql.NullString) (*time.Location, error) {
+	if !timezone.Valid || timezone.String == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone.String)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// AccountLocation returns the location of the account's timezone.
+func AccountLocation(ctx context.Context, conn dto.DB, accountId uint64) (*time.Location, error) {
+	account, err := dto.AccountByID(ctx, conn, accountId)
+	if err != nil {
+		return nil, err
+	}
+	return Location(account.Timezone)
+}
+
+// StartOfDay returns the first instant of the day of t in loc.
+// A day that begins in a DST gap, e.g. 2022-09-11 in America/Santiago, starts when the gap ends.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return startOfDate(y, m, d, loc)
+}
+
+// NextDay returns the start of the day after the one starting at start, 23 or 25 hours
+// later on DST transitions.
+func NextDay(start time.Time) time.Time {
+	y, m, d := start.Date()
+	return startOfDate(y, m, d+1, start.Location())
+}
+
+func startOfDate(y int, m time.Month, d int, loc *time.Location) time.Time {
+	y, m, d = time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	// time.Date may resolve a midnight that does not exist to the last hour of the day before.
+	if sy, sm, sd := start.Date(); sy != y || sm != m || sd != d {
+		_, start = start.ZoneBounds()
+	}
+	return start
+}
+
+// Key returns the bucket key of t. Minutes and hours are keyed in UTC, days in loc.
+func (g Granularity) Key(t time.Time, loc *time.Location) string {
+	if g == GranularityDay {
+		return t.In(loc).Format(g.Layout())
+	}
+	return t.UTC().Format(g.Layout())
+}
+
+// Parse returns the start of the bucket of key.
+func (g Granularity) Parse(key string, loc *time.Location) (time.Time, error) {
+	if g != GranularityDay {
+		return time.ParseInLocation(g.Layout(), key, time.UTC)
+	}
+	t, err := time.ParseInLocation(g.Layout(), key, time.UTC)
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := t.Date()
+	return startOfDate(y, m, d, loc), nil
+}
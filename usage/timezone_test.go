@@ -0,0 +1,130 @@
+package usage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestLocation(t *testing.T) {
+	t.Parallel()
+
+	loc, err := Location(sql.NullString{})
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	loc, err = Location(sql.NullString{String: "Asia/Tokyo", Valid: true})
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", loc.String())
+
+	_, err = Location(sql.NullString{String: "Mars/Olympus", Valid: true})
+	assert.Error(t, err)
+}
+
+func TestStartOfDay(t *testing.T) {
+	t.Parallel()
+
+	newYork := mustLoadLocation(t, "America/New_York")
+	santiago := mustLoadLocation(t, "America/Santiago")
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		loc       *time.Location
+		wantStart time.Time
+		wantHours float64
+	}{
+		{
+			name:      "utc",
+			t:         time.Date(2025, 7, 1, 15, 4, 5, 0, time.UTC),
+			loc:       time.UTC,
+			wantStart: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			wantHours: 24,
+		},
+		{
+			name:      "the local day differs from the utc day",
+			t:         time.Date(2025, 7, 1, 15, 30, 0, 0, time.UTC),
+			loc:       mustLoadLocation(t, "Asia/Tokyo"),
+			wantStart: time.Date(2025, 7, 1, 15, 0, 0, 0, time.UTC),
+			wantHours: 24,
+		},
+		{
+			name:      "spring forward",
+			t:         time.Date(2025, 3, 9, 12, 0, 0, 0, newYork),
+			loc:       newYork,
+			wantStart: time.Date(2025, 3, 9, 5, 0, 0, 0, time.UTC),
+			wantHours: 23,
+		},
+		{
+			name:      "fall back",
+			t:         time.Date(2025, 11, 2, 12, 0, 0, 0, newYork),
+			loc:       newYork,
+			wantStart: time.Date(2025, 11, 2, 4, 0, 0, 0, time.UTC),
+			wantHours: 25,
+		},
+		{
+			name:      "midnight does not exist",
+			t:         time.Date(2022, 9, 11, 12, 0, 0, 0, santiago),
+			loc:       santiago,
+			wantStart: time.Date(2022, 9, 11, 4, 0, 0, 0, time.UTC),
+			wantHours: 23,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := StartOfDay(tt.t, tt.loc)
+			assert.True(t, tt.wantStart.Equal(start), "start %s, want %s", start, tt.wantStart)
+			assert.Equal(t, tt.wantHours, NextDay(start).Sub(start).Hours())
+			assert.Equal(t, start, StartOfDay(start, tt.loc), "idempotent")
+		})
+	}
+}
+
+func TestGranularity_Key(t *testing.T) {
+	t.Parallel()
+
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	ts := time.Date(2025, 7, 1, 23, 30, 0, 0, tokyo)
+
+	assert.Equal(t, "202507011430", GranularityMinute.Key(ts, tokyo))
+	assert.Equal(t, "2025070114", GranularityHour.Key(ts, tokyo))
+	assert.Equal(t, "20250701", GranularityDay.Key(ts, tokyo))
+	assert.Equal(t, "20250702", GranularityDay.Key(ts.Add(time.Hour), tokyo))
+
+	for _, g := range []Granularity{GranularityMinute, GranularityHour, GranularityDay} {
+		bucket, err := g.Parse(g.Key(ts, tokyo), tokyo)
+		require.NoError(t, err)
+		assert.Equal(t, g.Key(ts, tokyo), g.Key(bucket, tokyo), g)
+	}
+	day, err := GranularityDay.Parse("20250701", tokyo)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo).Equal(day))
+}
+
+func TestDays(t *testing.T) {
+	t.Parallel()
+
+	newYork := mustLoadLocation(t, "America/New_York")
+	from := time.Date(2025, 3, 8, 12, 0, 0, 0, newYork)
+	to := time.Date(2025, 3, 10, 0, 0, 0, 0, newYork)
+
+	days := Days(from, to, newYork)
+	require.Len(t, days, 2)
+	assert.True(t, time.Date(2025, 3, 8, 0, 0, 0, 0, newYork).Equal(days[0]))
+	assert.True(t, time.Date(2025, 3, 9, 0, 0, 0, 0, newYork).Equal(days[1]))
+	assert.Empty(t, Days(to, to, newYork))
+}
@@ -3,41 +3,78 @@ package worker
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/apache/arrow/go/v17/arrow"
-	"github.com/apache/arrow/go/v17/arrow/array"
-	"github.com/apache/arrow/go/v17/arrow/memory"
-	"github.com/apache/arrow/go/v17/parquet"
-	"github.com/apache/arrow/go/v17/parquet/compress"
-	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/logstore"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+	"github.com/szks-repo/usage-based-billing-sample/usage"
 )
 
+// FlushListener is notified with the accounts whose aggregated usage was saved by a flush.
+// It is called from a goroutine of its own, never from the flush.
+type FlushListener interface {
+	OnFlushed(ctx context.Context, accountIds []uint64)
+}
+
 type AccessLogRecorder struct {
 	s3Client   *s3.Client
 	bucketName string
 
-	dbConn *sql.DB
+	dbConn         *sql.DB
+	latePolicy     LateUsagePolicy
+	dedupRetention time.Duration
+	notifier       *flushNotifier
 
-	logChan    chan types.ApiAccessLog
+	logChan    chan pendingLog
 	buffer     []types.ApiAccessLog
+	links      []trace.Link
+	acks       []AckFunc
 	bufferSize int
 	ticker     *time.Ticker
+	interval   time.Duration
+	lastLoop   atomic.Int64
+	lastFlush  atomic.Int64
+	buffered   atomic.Int64
 	mutex      sync.Mutex
 	wg         sync.WaitGroup
-	shutdown   chan struct{}
+	shutdown   chan context.Context
+}
+
+// AckFunc settles the message a log was consumed from once its flush finished.
+// ok is false when the log could not be written to both sinks.
+type AckFunc func(ok bool)
+
+// pendingLog carries the span of the consumed message to link it from the flush span.
+type pendingLog struct {
+	log  types.ApiAccessLog
+	link trace.Link
+	ack  AckFunc
 }
 
+// maxFlushLinks caps the links of a flush span, a flush may contain thousands of logs.
+const maxFlushLinks = 128
+
 // NewS3Uploader は新しいUploaderインスタンスを作成
 func NewAccessLogRecorder(
 	client *s3.Client,
@@ -45,40 +82,63 @@ func NewAccessLogRecorder(
 	bufferSize int,
 	interval time.Duration,
 	dbConn *sql.DB,
+	latePolicy LateUsagePolicy,
+	dedupRetention time.Duration,
+	listeners ...FlushListener,
 ) *AccessLogRecorder {
-	return &AccessLogRecorder{
-		s3Client:   client,
-		bucketName: bucket,
-		dbConn:     dbConn,
-		logChan:    make(chan types.ApiAccessLog, bufferSize*2),
-		buffer:     make([]types.ApiAccessLog, 0, bufferSize),
-		bufferSize: bufferSize,
-		ticker:     time.NewTicker(interval),
-		shutdown:   make(chan struct{}),
+	r := &AccessLogRecorder{
+		s3Client:       client,
+		bucketName:     bucket,
+		dbConn:         dbConn,
+		latePolicy:     latePolicy,
+		dedupRetention: dedupRetention,
+		notifier:       newFlushNotifier(listeners),
+		logChan:        make(chan pendingLog, bufferSize*2),
+		buffer:         make([]types.ApiAccessLog, 0, bufferSize),
+		acks:           make([]AckFunc, 0, bufferSize),
+		bufferSize:     bufferSize,
+		ticker:         time.NewTicker(interval),
+		interval:       interval,
+		shutdown:       make(chan context.Context, 1),
 	}
+	r.lastLoop.Store(time.Now().UnixNano())
+	r.lastFlush.Store(time.Now().UnixNano())
+	return r
 }
 
-func (u *AccessLogRecorder) Push(log types.ApiAccessLog) {
-	u.logChan <- log
+// Push buffers the log. ack is called after the flush containing the log.
+func (u *AccessLogRecorder) Push(ctx context.Context, log types.ApiAccessLog, ack AckFunc) {
+	u.logChan <- pendingLog{log: log, link: trace.LinkFromContext(ctx), ack: ack}
 }
 
+func (r *AccessLogRecorder) BufferSize() int {
+	return r.bufferSize
+}
+
+// Observe flushes the buffer when it is full or on every interval until Stop is called.
 func (r *AccessLogRecorder) Observe(ctx context.Context) {
+	go r.notifier.run(ctx)
+	pruneCtx, stopPrune := context.WithCancel(ctx)
+	go r.pruneProcessed(pruneCtx, r.dedupRetention, time.Hour)
+
+	r.wg.Add(1)
 	go func() {
+		defer r.wg.Done()
+		defer r.ticker.Stop()
+		defer stopPrune()
 		for {
+			r.lastLoop.Store(time.Now().UnixNano())
 			select {
-			case <-ctx.Done():
-				r.flush(context.WithoutCancel(ctx))
-				slog.Info("ctx.Done", "error", ctx.Err())
-				return
-			case <-r.shutdown:
-				r.flush(context.WithoutCancel(ctx))
-				slog.Info("S3 uploader shutting down.")
+			case stopCtx := <-r.shutdown:
+				r.drain()
+				r.flush(stopCtx)
+				if err := r.notifier.stop(stopCtx); err != nil {
+					slog.Warn("Flushed accounts not evaluated before shutdown", "error", err)
+				}
+				slog.Info("Access log recorder stopped")
 				return
 			case l := <-r.logChan:
-				r.mutex.Lock()
-				r.buffer = append(r.buffer, l)
-				r.mutex.Unlock()
-
+				r.append(l)
 				if len(r.buffer) >= r.bufferSize {
 					r.flush(ctx)
 				}
@@ -89,38 +149,152 @@ func (r *AccessLogRecorder) Observe(ctx context.Context) {
 	}()
 }
 
-func (r *AccessLogRecorder) Stop() {
-	close(r.shutdown)
-	r.wg.Wait()
+func (r *AccessLogRecorder) append(l pendingLog) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.buffer = append(r.buffer, l.log)
+	r.acks = append(r.acks, l.ack)
+	if len(r.links) < maxFlushLinks && l.link.SpanContext.IsValid() {
+		r.links = append(r.links, l.link)
+	}
+	bufferDepth.Set(float64(len(r.buffer)))
+	r.buffered.Store(int64(len(r.buffer)))
+}
+
+// drain moves the logs pushed before Stop into the buffer.
+func (r *AccessLogRecorder) drain() {
+	for {
+		select {
+		case l := <-r.logChan:
+			r.append(l)
+		default:
+			return
+		}
+	}
 }
 
-func (r *AccessLogRecorder) uploadToS3(ctx context.Context, logs []types.ApiAccessLog) {
-	slog.Info("Flushing logs to S3...", "numLogs", len(logs))
+// Stop drains the pushed logs and flushes them within ctx. Callers must stop
+// pushing before, logs pushed after Stop are never flushed nor acked.
+func (r *AccessLogRecorder) Stop(ctx context.Context) error {
+	r.shutdown <- ctx
 
-	// Parquetに変換
-	parquetData, err := r.convertToParquet(logs)
-	if err != nil {
-		slog.Error("Error converting to parquet", "error", err)
-		// 本番ではリトライ処理などを検討
-		return
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Liveness fails when the observe loop stopped iterating or logs stayed buffered
+// over several flush intervals, e.g. because a flush hangs.
+func (r *AccessLogRecorder) Liveness(ctx context.Context) error {
+	maxAge := 3 * r.interval
+	t := now.FromContext(ctx)
+	if age := t.Sub(time.Unix(0, r.lastLoop.Load())); age > maxAge {
+		return fmt.Errorf("observe loop stalled for %s", age.Truncate(time.Second))
+	}
+	if n := r.buffered.Load(); n > 0 {
+		if age := t.Sub(time.Unix(0, r.lastFlush.Load())); age > maxAge {
+			return fmt.Errorf("%d logs buffered, last flush %s ago", n, age.Truncate(time.Second))
+		}
 	}
+	return nil
+}
 
-	// S3にアップロード
-	// logs/YYYY/MM/DD/uuid.parquet のようなキーにする
-	key := fmt.Sprintf("logs/%s/%s.parquet", time.Now().Format("2006/01/02"), uuid.Must(uuid.NewV7()).String())
+// uploadToS3 uploads the logs of the batch, one object per hour partition, and
+// returns the manifest rows of the uploaded objects.
+func (r *AccessLogRecorder) uploadToS3(ctx context.Context, batchId string, logs []types.ApiAccessLog) (objects []*dto.AccessLogObject, err error) {
+	slog.Info("Flushing logs to S3...", "numLogs", len(logs), "batchId", batchId)
 
-	if _, err = r.s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: &r.bucketName,
-		Key:    &key,
-		Body:   bytes.NewReader(parquetData),
-	}); err != nil {
-		slog.Error("Error uploading to S3", "error", err)
-		return
+	ctx, span := tracing.Tracer().Start(ctx, "AccessLogRecorder.uploadToS3")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	// Parquetに変換し、時間単位のパーティションごとにアップロードする
+	// logs/dt=YYYY-MM-DD/hour=HH/uuid.parquet のようなキーにする
+	var uploaded int
+	for partition, partitionLogs := range logstore.SplitByPartition(logs) {
+		parquetData, err := logstore.Encode(partitionLogs)
+		if err != nil {
+			slog.Error("Error converting to parquet", "error", err)
+			s3UploadFailuresTotal.Inc()
+			return nil, err
+		}
+		uploaded += len(parquetData)
+
+		object, err := newAccessLogObject(partition.ObjectKey(uuid.Must(uuid.NewV7()).String()), batchId, partitionLogs, parquetData)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(parquetData)
+		if _, err = r.s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:         &r.bucketName,
+			Key:            &object.ObjectKey,
+			Body:           bytes.NewReader(parquetData),
+			ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		}); err != nil {
+			slog.Error("Error uploading to S3", "error", err)
+			s3UploadFailuresTotal.Inc()
+			return nil, err
+		}
+		s3UploadBytesTotal.Add(float64(len(parquetData)))
+		objects = append(objects, object)
+
+		slog.Info("Successfully uploaded", "key", object.ObjectKey)
+	}
+	span.SetAttributes(attribute.Int("s3.upload.bytes", uploaded))
+	return objects, nil
+}
+
+// newAccessLogObject returns the manifest row of an object holding logs encoded as data.
+func newAccessLogObject(key, batchId string, logs []types.ApiAccessLog, data []byte) (*dto.AccessLogObject, error) {
+	var accountIds []int64
+	minTs, maxTs := logs[0].Timestamp, logs[0].Timestamp
+	for _, l := range logs {
+		accountIds = append(accountIds, l.AccountId)
+		if l.Timestamp.Before(minTs) {
+			minTs = l.Timestamp
+		}
+		if l.Timestamp.After(maxTs) {
+			maxTs = l.Timestamp
+		}
+	}
+	slices.Sort(accountIds)
+	accountIdsJson, err := json.Marshal(slices.Compact(accountIds))
+	if err != nil {
+		return nil, err
 	}
 
-	slog.Info("Successfully uploaded", "key", key)
+	sum := sha256.Sum256(data)
+	return &dto.AccessLogObject{
+		ObjectKey:    key,
+		BatchID:      batchId,
+		RecordCount:  uint(len(logs)),
+		MinTimestamp: minTs.UTC(),
+		MaxTimestamp: maxTs.UTC(),
+		AccountIds:   accountIdsJson,
+		SizeBytes:    uint64(len(data)),
+		Checksum:     hex.EncodeToString(sum[:]),
+	}, nil
 }
 
+// flush writes the buffer to S3 and then, in one transaction, its usage and the
+// manifest rows of the uploaded objects to MySQL. Its messages are acked only when
+// both succeeded, otherwise they are requeued (at-least-once).
+//
+// Usage is therefore counted only for logs whose objects are in access_log_object:
+// objects without a row are from flushes whose transaction failed and whose logs were redelivered.
+// Logs redelivered after their transaction committed, e.g. because the ack was lost, are
+// stored again in S3 but counted once through processed_access_log.
 func (r *AccessLogRecorder) flush(ctx context.Context) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -129,121 +303,187 @@ func (r *AccessLogRecorder) flush(ctx context.Context) {
 		return
 	}
 
+	start := time.Now()
+	defer func() {
+		flushDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	// a flush batches many requests, so it is a new trace linked to the consumed messages
+	ctx, span := tracing.Tracer().Start(
+		ctx,
+		"AccessLogRecorder.flush",
+		trace.WithNewRoot(),
+		trace.WithLinks(r.links...),
+		trace.WithAttributes(attribute.Int("worker.flush.logs", len(r.buffer))),
+	)
+	defer span.End()
+
 	logsToUpload := make([]types.ApiAccessLog, len(r.buffer))
 	copy(logsToUpload, r.buffer)
+	acks := make([]AckFunc, len(r.acks))
+	copy(acks, r.acks)
 	r.buffer = r.buffer[:0]
-
-	var wg sync.WaitGroup
-	wg.Go(func() {
-		r.uploadToS3(ctx, logsToUpload)
-	})
-	wg.Go(func() {
-		r.saveAggregated(ctx, logsToUpload)
-	})
-	wg.Wait()
-}
-
-var parquetSchema = arrow.NewSchema(
-	[]arrow.Field{
-		{Name: "account_id", Type: arrow.PrimitiveTypes.Int64},
-		{Name: "client_ip", Type: arrow.BinaryTypes.String},
-		{Name: "method", Type: arrow.BinaryTypes.String},
-		{Name: "path", Type: arrow.BinaryTypes.String},
-		{Name: "status_code", Type: arrow.PrimitiveTypes.Int32},
-		{Name: "latency_ms", Type: arrow.PrimitiveTypes.Int64},
-		{Name: "user_agent", Type: arrow.BinaryTypes.String},
-		{Name: "timestamp", Type: arrow.FixedWidthTypes.Timestamp_ms},
-	},
-	nil, // metadata
-)
-
-func (a *AccessLogRecorder) convertToParquet(logs []types.ApiAccessLog) ([]byte, error) {
-	pool := memory.NewGoAllocator()
-	rb := array.NewRecordBuilder(pool, parquetSchema)
-	defer rb.Release()
-
-	for _, l := range logs {
-		rb.Field(0).(*array.Int64Builder).Append(l.AccountId)
-		rb.Field(1).(*array.StringBuilder).Append(l.ClientIP)
-		rb.Field(2).(*array.StringBuilder).Append(l.Method)
-		rb.Field(3).(*array.StringBuilder).Append(l.Path)
-		rb.Field(4).(*array.Int32Builder).Append(int32(l.StatusCode))
-		rb.Field(5).(*array.Int64Builder).Append(l.Latency)
-		rb.Field(6).(*array.StringBuilder).Append(l.UserAgent)
-		rb.Field(7).(*array.TimestampBuilder).Append(arrow.Timestamp(l.Timestamp.UnixMilli()))
-	}
-
-	rec := rb.NewRecord()
-	defer rec.Release()
-
-	var buf bytes.Buffer
-	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
-	writer, err := pqarrow.NewFileWriter(parquetSchema, &buf, props, pqarrow.NewArrowWriterProperties())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create parquet file writer: %w", err)
+	r.acks = r.acks[:0]
+	r.links = r.links[:0]
+	bufferDepth.Set(0)
+	r.buffered.Store(0)
+	defer r.lastFlush.Store(time.Now().UnixNano())
+
+	batchId := uuid.Must(uuid.NewV7()).String()
+	span.SetAttributes(attribute.String("worker.flush.batch_id", batchId))
+
+	objects, err := r.uploadToS3(ctx, batchId, logsToUpload)
+	if err == nil {
+		err = r.saveAggregated(ctx, batchId, logsToUpload, objects)
+	}
+	if err == nil {
+		r.notifyFlushed(logsToUpload)
 	}
 
-	if err := writer.Write(rec); err != nil {
-		writer.Close()
-		return nil, fmt.Errorf("failed to write record to parquet: %w", err)
+	ok := err == nil
+	if !ok {
+		span.SetStatus(codes.Error, "flush failed, requeueing")
+	}
+	for _, ack := range acks {
+		if ack != nil {
+			ack(ok)
+		}
 	}
+}
 
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close parquet writer: %w", err)
+// notifyFlushed queues the accounts of logs for the listeners.
+func (r *AccessLogRecorder) notifyFlushed(logs []types.ApiAccessLog) {
+	seen := make(map[int64]struct{})
+	var accountIds []uint64
+	for _, l := range logs {
+		if _, ok := seen[l.AccountId]; ok {
+			continue
+		}
+		seen[l.AccountId] = struct{}{}
+		accountIds = append(accountIds, uint64(l.AccountId))
 	}
 
-	return buf.Bytes(), nil
+	r.notifier.notify(accountIds)
 }
 
-func (r *AccessLogRecorder) saveAggregated(ctx context.Context, accessLogs []types.ApiAccessLog) error {
+// saveAggregated upserts the per-minute usage of accessLogs, adds it to its hours and
+// days, and inserts the manifest rows of the objects holding them in one transaction. Logs an earlier flush counted
+// are skipped, so a redelivered batch changes no usage. Logs of closed periods are
+// handled by the late usage policy.
+func (r *AccessLogRecorder) saveAggregated(ctx context.Context, batchId string, accessLogs []types.ApiAccessLog, objects []*dto.AccessLogObject) (err error) {
 	if len(accessLogs) == 0 {
 		return nil
 	}
 
-	groupByAccounts := make(map[int64][]types.ApiAccessLog)
-	for _, l := range accessLogs {
-		groupByAccounts[l.AccountId] = append(groupByAccounts[l.AccountId], l)
+	ctx, span := tracing.Tracer().Start(ctx, "AccessLogRecorder.saveAggregated")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	objectArgs := make([]any, 0, len(objects)*8)
+	for _, o := range objects {
+		objectArgs = append(objectArgs, o.ObjectKey, o.BatchID, o.RecordCount, o.MinTimestamp, o.MaxTimestamp, string(o.AccountIds), o.SizeBytes, o.Checksum)
 	}
 
-	var dst []*dto.EveryMinuteAPIUsage
-	for accountId, logs := range groupByAccounts {
-		minuteGroup := make(map[string]int)
-		for _, l := range logs {
-			minuteGroup[l.Timestamp.Format("200601021504")] += 1
+	var (
+		ra   int64
+		dst  []*dto.EveryMinuteAPIUsage
+		late []*dto.UsageAdjustment
+	)
+	if err := db.RunInTxn(ctx, r.dbConn, func(ctx context.Context) error {
+		txn, err := db.GetTxn(ctx)
+		if err != nil {
+			return err
 		}
-		for minute, usage := range minuteGroup {
-			dst = append(dst, &dto.EveryMinuteAPIUsage{
-				AccountID: uint64(accountId),
-				Minute:    minute,
-				Usage:     uint64(usage),
-			})
+
+		if accessLogs, err = filterProcessed(ctx, txn, batchId, accessLogs); err != nil {
+			return err
+		}
+		if len(accessLogs) == 0 {
+			return insertObjects(ctx, txn, objectArgs, len(objects))
 		}
-	}
 
-	if len(dst) == 0 {
-		return nil
-	}
+		periods, err := listClosedPeriods(ctx, txn, accessLogs)
+		if err != nil {
+			return err
+		}
+		var kept []types.ApiAccessLog
+		kept, late = splitLate(accessLogs, periods, r.latePolicy)
+		if r.latePolicy == LateUsageAdjust {
+			for _, adjustment := range late {
+				if adjustment.Usage == 0 {
+					continue
+				}
+				adjustment.BatchID = batchId
+				adjustment.CreatedAt = now.FromContext(ctx).UTC()
+				if err := adjustment.Insert(ctx, txn); err != nil {
+					return err
+				}
+			}
+		}
 
-	slog.Info("Upsert minute aggregate records", "num", len(dst))
+		dst = usage.AggregateByMinute(kept)
+		if len(dst) == 0 {
+			return insertObjects(ctx, txn, objectArgs, len(objects))
+		}
+		slog.Info("Upsert minute aggregate records", "num", len(dst))
 
-	args := make([]any, 0, len(dst)*3)
-	for _, v := range dst {
-		args = append(args, v.AccountID, v.Minute, v.Usage)
-	}
+		args := make([]any, 0, len(dst)*6)
+		for _, v := range dst {
+			args = append(args, v.AccountID, v.Meter, v.Minute, v.Usage, v.BillableRequests, v.NonBillableRequests)
+		}
+		result, err := txn.ExecContext(
+			ctx,
+			"INSERT INTO every_minute_api_usage (`account_id`, `meter`, `minute`, `usage`, `billable_requests`, `non_billable_requests`) "+db.MakeValues(6, len(dst))+" "+
+				"ON DUPLICATE KEY UPDATE "+
+				"`usage` = `usage` + VALUES(`usage`), "+
+				"`billable_requests` = `billable_requests` + VALUES(`billable_requests`), "+
+				"`non_billable_requests` = `non_billable_requests` + VALUES(`non_billable_requests`), "+
+				"`updated_at` = NOW()",
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+		ra, _ = result.RowsAffected()
+		if err := usage.AddRollUps(ctx, txn, dst); err != nil {
+			return err
+		}
 
-	result, err := r.dbConn.ExecContext(
-		ctx,
-		"INSERT INTO every_minute_api_usage (`account_id`, `minute`, `usage`) "+db.MakeValues(3, len(dst))+" "+
-			"ON DUPLICATE KEY UPDATE "+
-			"`usage` = `usage` + VALUES(`usage`), `updated_at` = NOW()",
-		args...,
-	)
-	if err != nil {
-		slog.Error("Failed to ExecContext", "error", err)
+		return insertObjects(ctx, txn, objectArgs, len(objects))
+	}); err != nil {
+		slog.Error("Failed to save aggregated usage", "error", err)
+		upsertFailuresTotal.Inc()
 		return err
 	}
-	ra, _ := result.RowsAffected()
-	slog.Info("Upsert every_minute_api_usage", "rowsAffected", ra)
+	observeLate(r.latePolicy, late)
+	for _, adjustment := range late {
+		slog.Warn("Late usage of a closed billing period",
+			"policy", r.latePolicy,
+			"accountId", adjustment.AccountID,
+			"subscriptionId", adjustment.SubscriptionID,
+			"meter", adjustment.Meter,
+			"requests", adjustment.Requests,
+			"usage", adjustment.Usage,
+		)
+	}
+	upsertedRowsTotal.Add(float64(len(dst)))
+	span.SetAttributes(attribute.Int("db.upserted_rows", len(dst)), attribute.Int("db.manifest_rows", len(objects)))
+	slog.Info("Upsert every_minute_api_usage", "rowsAffected", ra, "objects", len(objects))
 
 	return nil
 }
+
+// insertObjects inserts the manifest rows of the objects uploaded by a flush.
+func insertObjects(ctx context.Context, txn db.DBConnection, objectArgs []any, numObjects int) error {
+	if numObjects == 0 {
+		return nil
+	}
+	_, err := txn.ExecContext(
+		ctx,
+		"INSERT INTO access_log_object (`object_key`, `batch_id`, `record_count`, `min_timestamp`, `max_timestamp`, `account_ids`, `size_bytes`, `checksum`) "+
+			db.MakeValues(8, numObjects),
+		objectArgs...,
+	)
+	return err
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestNewAccessLogObject(t *testing.T) {
+	t.Parallel()
+
+	jst := time.FixedZone("JST", 9*60*60)
+	base := time.Date(2025, 7, 1, 19, 0, 0, 0, jst)
+	logs := []types.ApiAccessLog{
+		{AccountId: 3, Timestamp: base.Add(time.Second)},
+		{AccountId: 1, Timestamp: base.Add(3 * time.Second)},
+		{AccountId: 3, Timestamp: base},
+	}
+	data := []byte("parquet")
+
+	got, err := newAccessLogObject("logs/dt=2025-07-01/hour=10/id.parquet", "batch", logs, data)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(data)
+	assert.Equal(t, "logs/dt=2025-07-01/hour=10/id.parquet", got.ObjectKey)
+	assert.Equal(t, "batch", got.BatchID)
+	assert.Equal(t, uint(3), got.RecordCount)
+	assert.Equal(t, time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC), got.MinTimestamp)
+	assert.Equal(t, time.Date(2025, 7, 1, 10, 0, 3, 0, time.UTC), got.MaxTimestamp)
+	assert.JSONEq(t, `[1, 3]`, string(got.AccountIds))
+	assert.Equal(t, uint64(len(data)), got.SizeBytes)
+	assert.Equal(t, hex.EncodeToString(sum[:]), got.Checksum)
+}
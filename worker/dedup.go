@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/now"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// dedupChunk bounds the keys of one statement on processed_access_log.
+const dedupChunk = 1000
+
+// logKey identifies a log across redeliveries. Request ids may be sent by clients, so like
+// the backfill it is qualified by the account and the timestamp. Logs without a request id,
+// published before it was introduced, have no key and are always counted.
+func logKey(l *types.ApiAccessLog) []byte {
+	if l.RequestId == "" {
+		return nil
+	}
+	h := sha256.New()
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(l.AccountId)))
+	h.Write([]byte(l.RequestId))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(l.Timestamp.UnixNano())))
+	return h.Sum(nil)
+}
+
+// uniqueLogs drops the logs appearing twice in logs, e.g. a spooled copy next to its
+// redelivery, and returns the key of each remaining log.
+func uniqueLogs(logs []types.ApiAccessLog) ([]types.ApiAccessLog, [][]byte) {
+	seen := make(map[string]struct{}, len(logs))
+	unique := make([]types.ApiAccessLog, 0, len(logs))
+	keys := make([][]byte, 0, len(logs))
+	for _, l := range logs {
+		key := logKey(&l)
+		if key != nil {
+			if _, ok := seen[string(key)]; ok {
+				continue
+			}
+			seen[string(key)] = struct{}{}
+		}
+		unique = append(unique, l)
+		keys = append(keys, key)
+	}
+	return unique, keys
+}
+
+// dropProcessed returns the logs whose key is not in processed, and their keys.
+func dropProcessed(logs []types.ApiAccessLog, keys [][]byte, processed map[string]struct{}) ([]types.ApiAccessLog, [][]byte) {
+	if len(processed) == 0 {
+		return logs, keys
+	}
+	fresh := make([]types.ApiAccessLog, 0, len(logs))
+	freshKeys := make([][]byte, 0, len(keys))
+	for i, l := range logs {
+		if _, ok := processed[string(keys[i])]; ok && keys[i] != nil {
+			continue
+		}
+		fresh = append(fresh, l)
+		freshKeys = append(freshKeys, keys[i])
+	}
+	return fresh, freshKeys
+}
+
+// filterProcessed returns the logs no earlier flush counted and records them as processed by
+// batchId. It runs in the transaction of the usage upsert, so a redelivered log is skipped
+// exactly when its first flush committed. Two flushes of the same log racing each other both
+// insert its key; one of them fails on the primary key and its logs are redelivered.
+func filterProcessed(ctx context.Context, txn db.DBConnection, batchId string, logs []types.ApiAccessLog) ([]types.ApiAccessLog, error) {
+	logs, keys := uniqueLogs(logs)
+
+	var lookup []any
+	for _, key := range keys {
+		if key != nil {
+			lookup = append(lookup, key)
+		}
+	}
+	processed := make(map[string]struct{})
+	for chunk := range chunks(lookup, dedupChunk) {
+		rows, err := txn.QueryContext(
+			ctx,
+			"SELECT `log_key` FROM processed_access_log WHERE `log_key` IN (?"+strings.Repeat(", ?", len(chunk)-1)+")",
+			chunk...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key []byte
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			processed[string(key)] = struct{}{}
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	fresh, freshKeys := dropProcessed(logs, keys, processed)
+	createdAt := now.FromContext(ctx).UTC()
+	var args []any
+	for i, key := range freshKeys {
+		if key != nil {
+			args = append(args, key, fresh[i].AccountId, batchId, createdAt)
+		}
+	}
+	for chunk := range chunks(args, dedupChunk*4) {
+		if _, err := txn.ExecContext(
+			ctx,
+			"INSERT INTO processed_access_log (`log_key`, `account_id`, `batch_id`, `created_at`) "+db.MakeValues(4, len(chunk)/4),
+			chunk...,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if n := len(logs) - len(fresh); n > 0 {
+		duplicateAccessLogsTotal.Add(float64(n))
+		slog.Info("Skipped access logs counted by an earlier flush", "batchId", batchId, "logs", n)
+	}
+	return fresh, nil
+}
+
+// chunks yields s in slices of at most n elements.
+func chunks(s []any, n int) func(yield func([]any) bool) {
+	return func(yield func([]any) bool) {
+		for len(s) > 0 {
+			end := min(n, len(s))
+			if !yield(s[:end]) {
+				return
+			}
+			s = s[end:]
+		}
+	}
+}
+
+// pruneProcessed deletes the keys older than retention every interval until ctx is done.
+func (r *AccessLogRecorder) pruneProcessed(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := time.Now().Add(-retention).UTC()
+		var deleted int64
+		for {
+			result, err := r.dbConn.ExecContext(ctx, "DELETE FROM processed_access_log WHERE `created_at` < ? LIMIT ?", before, dedupChunk*10)
+			if err != nil {
+				slog.Error("Failed to prune processed_access_log", "error", err)
+				break
+			}
+			n, _ := result.RowsAffected()
+			deleted += n
+			if n < dedupChunk*10 {
+				break
+			}
+		}
+		if deleted > 0 {
+			slog.Info("Pruned processed_access_log", "rows", deleted, "before", before)
+		}
+	}
+}
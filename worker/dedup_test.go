@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestLogKey(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 123, time.UTC)
+	base := types.ApiAccessLog{AccountId: 1, RequestId: "req-1", Timestamp: ts}
+
+	assert.Len(t, logKey(&base), 32)
+	assert.Nil(t, logKey(&types.ApiAccessLog{AccountId: 1, Timestamp: ts}))
+
+	same := base
+	same.Timestamp = ts.In(time.FixedZone("JST", 9*60*60))
+	assert.Equal(t, logKey(&base), logKey(&same))
+
+	// a request id reused by a client is another log
+	for _, other := range []types.ApiAccessLog{
+		{AccountId: 2, RequestId: "req-1", Timestamp: ts},
+		{AccountId: 1, RequestId: "req-2", Timestamp: ts},
+		{AccountId: 1, RequestId: "req-1", Timestamp: ts.Add(time.Nanosecond)},
+	} {
+		assert.NotEqual(t, logKey(&base), logKey(&other))
+	}
+}
+
+func TestUniqueLogs(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	logs := []types.ApiAccessLog{
+		{AccountId: 1, RequestId: "a", Timestamp: ts},
+		{AccountId: 1, Timestamp: ts},
+		{AccountId: 1, RequestId: "a", Timestamp: ts},
+		{AccountId: 1, Timestamp: ts},
+		{AccountId: 1, RequestId: "b", Timestamp: ts},
+	}
+
+	unique, keys := uniqueLogs(logs)
+	assert.Equal(t, []types.ApiAccessLog{logs[0], logs[1], logs[3], logs[4]}, unique)
+	assert.Equal(t, [][]byte{logKey(&logs[0]), nil, nil, logKey(&logs[4])}, keys)
+}
+
+func TestDropProcessed(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	logs, keys := uniqueLogs([]types.ApiAccessLog{
+		{AccountId: 1, RequestId: "a", Timestamp: ts},
+		{AccountId: 1, Timestamp: ts},
+		{AccountId: 2, RequestId: "b", Timestamp: ts},
+	})
+
+	fresh, freshKeys := dropProcessed(logs, keys, nil)
+	assert.Equal(t, logs, fresh)
+	assert.Equal(t, keys, freshKeys)
+
+	fresh, freshKeys = dropProcessed(logs, keys, map[string]struct{}{string(keys[0]): {}})
+	assert.Equal(t, []types.ApiAccessLog{logs[1], logs[2]}, fresh)
+	assert.Equal(t, [][]byte{nil, keys[2]}, freshKeys)
+
+	fresh, _ = dropProcessed(logs, keys, map[string]struct{}{string(keys[0]): {}, string(keys[2]): {}})
+	assert.Equal(t, []types.ApiAccessLog{logs[1]}, fresh)
+}
+
+func TestChunks(t *testing.T) {
+	t.Parallel()
+
+	var got [][]any
+	for chunk := range chunks([]any{1, 2, 3, 4, 5}, 2) {
+		got = append(got, chunk)
+	}
+	assert.Equal(t, [][]any{{1, 2}, {3, 4}, {5}}, got)
+
+	for range chunks(nil, 2) {
+		t.Fatal("chunk of nothing")
+	}
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// LateUsagePolicy decides what a flush does with access logs of a billing period
+// that createDailyInvoice already closed.
+type LateUsagePolicy string
+
+const (
+	// LateUsageAdjust records the usage and bills it on the next invoice of the account.
+	LateUsageAdjust LateUsagePolicy = "adjust"
+	// LateUsageReject drops the usage. The logs stay archived in S3.
+	LateUsageReject LateUsagePolicy = "reject"
+)
+
+// closedPeriod is a subscription period whose invoice was created.
+type closedPeriod struct {
+	subscriptionId uint64
+	accountId      uint64
+	from, to       time.Time
+}
+
+func (p *closedPeriod) contains(l *types.ApiAccessLog) bool {
+	return uint64(l.AccountId) == p.accountId && !l.Timestamp.Before(p.from) && l.Timestamp.Before(p.to)
+}
+
+// listClosedPeriods returns the closed periods logs may belong to.
+//
+// The accounts are locked in share mode first: createInvoice locks its account for update
+// before it rolls up the usage and closes the period, so a flush either lands before the
+// invoice or sees the period closed.
+func listClosedPeriods(ctx context.Context, conn dto.DB, logs []types.ApiAccessLog) ([]*closedPeriod, error) {
+	seen := make(map[int64]struct{})
+	var args []any
+	minTs := logs[0].Timestamp
+	for _, l := range logs {
+		if l.Timestamp.Before(minTs) {
+			minTs = l.Timestamp
+		}
+		if _, ok := seen[l.AccountId]; ok {
+			continue
+		}
+		seen[l.AccountId] = struct{}{}
+		args = append(args, l.AccountId)
+	}
+	in := "(?" + strings.Repeat(", ?", len(args)-1) + ")"
+
+	lockRows, err := conn.QueryContext(ctx, "SELECT id FROM account WHERE id IN "+in+" ORDER BY id FOR SHARE", args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockRows.Close(); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(
+		ctx,
+		"SELECT s.id, s.account_id, s.from, s.estimated_to FROM subscription s "+
+			"WHERE s.account_id IN "+in+" AND s.closed_at IS NOT NULL AND s.estimated_to > ? FOR SHARE",
+		append(args, minTs.UTC())...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []*closedPeriod
+	for rows.Next() {
+		var p closedPeriod
+		if err := rows.Scan(&p.subscriptionId, &p.accountId, &p.from, &p.to); err != nil {
+			return nil, err
+		}
+		periods = append(periods, &p)
+	}
+	return periods, rows.Err()
+}
+
+// splitLate returns the logs whose usage is recorded and the billable usage of the logs of
+// closed periods, one adjustment per period and meter. Under LateUsageAdjust the late logs are kept so
+// that the usage history stays complete; the closed invoices are not recomputed from it.
+func splitLate(logs []types.ApiAccessLog, periods []*closedPeriod, policy LateUsagePolicy) ([]types.ApiAccessLog, []*dto.UsageAdjustment) {
+	if len(periods) == 0 {
+		return logs, nil
+	}
+
+	kept := make([]types.ApiAccessLog, 0, len(logs))
+	type key struct {
+		subscriptionId uint64
+		meter          string
+	}
+	byKey := make(map[key]*dto.UsageAdjustment)
+	var late []*dto.UsageAdjustment
+	for _, l := range logs {
+		var period *closedPeriod
+		for _, p := range periods {
+			if p.contains(&l) {
+				period = p
+				break
+			}
+		}
+		if period == nil || policy == LateUsageAdjust {
+			kept = append(kept, l)
+		}
+		if period == nil {
+			continue
+		}
+
+		k := key{subscriptionId: period.subscriptionId, meter: l.Meter}
+		adjustment, ok := byKey[k]
+		if !ok {
+			adjustment = &dto.UsageAdjustment{AccountID: period.accountId, SubscriptionID: period.subscriptionId, Meter: l.Meter}
+			byKey[k] = adjustment
+			late = append(late, adjustment)
+		}
+		adjustment.Usage += uint64(l.BillableUsage())
+		adjustment.Requests++
+	}
+	return kept, late
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+func TestSplitLate(t *testing.T) {
+	t.Parallel()
+
+	closedTo := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	periods := []*closedPeriod{
+		{subscriptionId: 10, accountId: 1, from: closedTo.AddDate(0, -1, 0), to: closedTo},
+	}
+	logs := []types.ApiAccessLog{
+		{AccountId: 1, Timestamp: closedTo.Add(-time.Hour), Meter: "api1", Usage: 3},
+		{AccountId: 1, Timestamp: closedTo.Add(-time.Minute), Meter: "api1", Usage: 2},
+		{AccountId: 1, Timestamp: closedTo.Add(-time.Minute), Meter: "api2", Usage: 4},
+		{AccountId: 1, Timestamp: closedTo.Add(-time.Second), Meter: "api1", Usage: 5, NonBillable: true},
+		{AccountId: 1, Timestamp: closedTo, Meter: "api1", Usage: 7},
+		{AccountId: 2, Timestamp: closedTo.Add(-time.Hour), Meter: "api1", Usage: 11},
+	}
+
+	tests := []struct {
+		name     string
+		periods  []*closedPeriod
+		policy   LateUsagePolicy
+		wantKept []types.ApiAccessLog
+		wantLate []*dto.UsageAdjustment
+	}{
+		{
+			name:     "no closed periods",
+			policy:   LateUsageReject,
+			wantKept: logs,
+		},
+		{
+			name:     "adjust keeps late logs",
+			periods:  periods,
+			policy:   LateUsageAdjust,
+			wantKept: logs,
+			wantLate: []*dto.UsageAdjustment{
+				{AccountID: 1, SubscriptionID: 10, Meter: "api1", Usage: 5, Requests: 3},
+				{AccountID: 1, SubscriptionID: 10, Meter: "api2", Usage: 4, Requests: 1},
+			},
+		},
+		{
+			name:     "reject drops late logs",
+			periods:  periods,
+			policy:   LateUsageReject,
+			wantKept: []types.ApiAccessLog{logs[4], logs[5]},
+			wantLate: []*dto.UsageAdjustment{
+				{AccountID: 1, SubscriptionID: 10, Meter: "api1", Usage: 5, Requests: 3},
+				{AccountID: 1, SubscriptionID: 10, Meter: "api2", Usage: 4, Requests: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			kept, late := splitLate(logs, tt.periods, tt.policy)
+			assert.Equal(t, tt.wantKept, kept)
+			assert.Equal(t, tt.wantLate, late)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/db/dto"
+)
+
+var (
+	bufferDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_buffer_depth",
+		Help: "Access logs buffered in AccessLogRecorder waiting for the next flush.",
+	})
+
+	flushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "worker_flush_duration_seconds",
+		Help:    "Duration of a flush, i.e. the S3 upload and the aggregated upsert.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	notifyPendingAccounts = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_notify_pending_accounts",
+		Help: "Flushed accounts waiting for the alert and budget evaluation.",
+	})
+
+	s3UploadBytesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_s3_upload_bytes_total",
+		Help: "Bytes of Parquet uploaded to S3.",
+	})
+
+	s3UploadFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_s3_upload_failures_total",
+		Help: "Flushes whose Parquet conversion or S3 upload failed.",
+	})
+
+	upsertedRowsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_upserted_rows_total",
+		Help: "every_minute_api_usage rows upserted by flushes.",
+	})
+
+	upsertFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_upsert_failures_total",
+		Help: "Flushes whose every_minute_api_usage upsert failed.",
+	})
+
+	duplicateAccessLogsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_duplicate_access_logs_total",
+		Help: "Redelivered access logs skipped because an earlier flush counted them.",
+	})
+
+	lateAccessLogsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_late_access_logs_total",
+		Help: "Access logs of closed billing periods by late usage policy.",
+	}, []string{"policy"})
+
+	lateUsageTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_late_usage_total",
+		Help: "Billable usage of closed billing periods by late usage policy, adjusted onto the next invoice or rejected.",
+	}, []string{"policy"})
+)
+
+func observeLate(policy LateUsagePolicy, late []*dto.UsageAdjustment) {
+	for _, adjustment := range late {
+		lateAccessLogsTotal.WithLabelValues(string(policy)).Add(float64(adjustment.Requests))
+		lateUsageTotal.WithLabelValues(string(policy)).Add(float64(adjustment.Usage))
+	}
+}
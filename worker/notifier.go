@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// flushNotifier hands the accounts saved by flushes to the listeners on its own goroutine.
+// Listeners preview invoices and publish alerts per account, which must not hold up the
+// observe loop. Accounts flushed while the listeners are busy are coalesced into the next
+// notification, so a slow listener delays evaluations instead of ingestion.
+type flushNotifier struct {
+	listeners []FlushListener
+
+	mutex   sync.Mutex
+	pending map[uint64]struct{}
+	wake    chan struct{}
+	quit    chan context.Context
+	done    chan struct{}
+}
+
+func newFlushNotifier(listeners []FlushListener) *flushNotifier {
+	return &flushNotifier{
+		listeners: listeners,
+		pending:   make(map[uint64]struct{}),
+		wake:      make(chan struct{}, 1),
+		quit:      make(chan context.Context, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// notify queues the accounts without blocking.
+func (n *flushNotifier) notify(accountIds []uint64) {
+	if len(n.listeners) == 0 || len(accountIds) == 0 {
+		return
+	}
+
+	n.mutex.Lock()
+	for _, id := range accountIds {
+		n.pending[id] = struct{}{}
+	}
+	notifyPendingAccounts.Set(float64(len(n.pending)))
+	n.mutex.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the queued accounts in ascending order and clears them.
+func (n *flushNotifier) take() []uint64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	accountIds := make([]uint64, 0, len(n.pending))
+	for id := range n.pending {
+		accountIds = append(accountIds, id)
+	}
+	clear(n.pending)
+	notifyPendingAccounts.Set(0)
+	slices.Sort(accountIds)
+	return accountIds
+}
+
+// run notifies the listeners until stop is called. The accounts queued by then are
+// notified with the context passed to stop.
+func (n *flushNotifier) run(ctx context.Context) {
+	defer close(n.done)
+	for {
+		select {
+		case <-n.wake:
+			n.dispatch(ctx)
+		case stopCtx := <-n.quit:
+			n.dispatch(stopCtx)
+			return
+		}
+	}
+}
+
+func (n *flushNotifier) dispatch(ctx context.Context) {
+	accountIds := n.take()
+	if len(accountIds) == 0 {
+		return
+	}
+	for _, l := range n.listeners {
+		l.OnFlushed(ctx, accountIds)
+	}
+}
+
+// stop makes run return after notifying the queued accounts, and waits for it within ctx.
+func (n *flushNotifier) stop(ctx context.Context) error {
+	n.quit <- ctx
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
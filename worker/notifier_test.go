@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingListener records its calls and blocks each one until release is closed.
+type blockingListener struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls [][]uint64
+}
+
+func (l *blockingListener) OnFlushed(ctx context.Context, accountIds []uint64) {
+	l.mu.Lock()
+	l.calls = append(l.calls, accountIds)
+	l.mu.Unlock()
+	l.started <- struct{}{}
+	<-l.release
+}
+
+func TestFlushNotifier(t *testing.T) {
+	t.Parallel()
+
+	l := &blockingListener{started: make(chan struct{}, 10), release: make(chan struct{})}
+	n := newFlushNotifier([]FlushListener{l})
+	go n.run(context.Background())
+
+	n.notify([]uint64{2, 1})
+	select {
+	case <-l.started:
+	case <-time.After(time.Second):
+		t.Fatal("listener not called")
+	}
+
+	// the listener is busy: notify must not block and the accounts are coalesced
+	done := make(chan struct{})
+	go func() {
+		n.notify([]uint64{3})
+		n.notify([]uint64{1, 3})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify blocked on a busy listener")
+	}
+
+	close(l.release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, n.stop(ctx))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	assert.Equal(t, [][]uint64{{1, 2}, {1, 3}}, l.calls)
+}
+
+func TestFlushNotifier_stopTimeout(t *testing.T) {
+	t.Parallel()
+
+	l := &blockingListener{started: make(chan struct{}, 10), release: make(chan struct{})}
+	defer close(l.release)
+	n := newFlushNotifier([]FlushListener{l})
+	go n.run(context.Background())
+
+	n.notify([]uint64{1})
+	<-l.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, n.stop(ctx), context.DeadlineExceeded)
+}
@@ -3,74 +3,114 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"sync/atomic"
+	"time"
 
-	"github.com/szks-repo/usage-based-billing-sample/pkg/rabbitmq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/tracing"
 	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
 )
 
+// Recorder buffers consumed access logs and acks them once they are flushed.
+type Recorder interface {
+	Observe(ctx context.Context)
+	Push(ctx context.Context, log types.ApiAccessLog, ack AckFunc)
+	Stop(ctx context.Context) error
+	Liveness(ctx context.Context) error
+	// BufferSize is the number of logs acked together by a flush.
+	BufferSize() int
+}
+
 type Worker struct {
-	mqConn   *rabbitmq.Conn
-	recorder *AccessLogRecorder
+	consumer  queue.UsageConsumer
+	recorder  Recorder
+	consuming atomic.Bool
 }
 
 func NewWorker(
-	mqConn *rabbitmq.Conn,
-	recorder *AccessLogRecorder,
+	consumer queue.UsageConsumer,
+	recorder Recorder,
 ) *Worker {
 	return &Worker{
-		mqConn:   mqConn,
+		consumer: consumer,
 		recorder: recorder,
 	}
 }
 
-func (w *Worker) Run(ctx context.Context) {
+// Run consumes until ctx is cancelled. It then cancels the consumer, flushes
+// every received log within shutdownTimeout and acks them before returning.
+func (w *Worker) Run(ctx context.Context, shutdownTimeout time.Duration) {
 	slog.Info("Worker started")
 
-	queue, err := w.mqConn.Channel.QueueDeclare(
-		"api1_queue", // name
-		true,         // durable
-		false,        // delete when unused
-		false,        // exclusive
-		false,        // no-wait
-		nil,          // arguments
-	)
-	if err != nil {
-		slog.Error("Failed to declare queue", "error", err)
-		return
+	w.recorder.Observe(context.WithoutCancel(ctx))
 
+	// Consume returns once ctx is cancelled and the deliveries already received were handled.
+	slog.Info("Worker is ready to consume messages", "queue", types.AccessLogQueue)
+	w.consuming.Store(true)
+	// acks are sent after the flush, so the prefetch must cover a full buffer
+	err := w.consumer.Consume(ctx, w.recorder.BufferSize(), func(d queue.Delivery) {
+		w.handle(ctx, d)
+	})
+	w.consuming.Store(false)
+	if err != nil {
+		slog.Error("Consumer stopped", "error", err)
 	}
-	slog.Info("Queue declared", "name", queue.Name)
 
-	msgs, err := w.mqConn.Channel.Consume(
-		queue.Name,
-		"",    // consumer tag
-		false, // auto-ack
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,   // args
-	)
-	if err != nil {
-		slog.Error("Failed to register consumer", "error", err)
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := w.recorder.Stop(stopCtx); err != nil {
+		slog.Error("Failed to flush access logs before shutdown, unacked messages will be redelivered", "error", err)
 		return
 	}
+	slog.Info("Worker stopped")
+}
 
-	w.recorder.Observe(ctx)
-	defer w.recorder.Stop()
+// Liveness fails when the consumer loop is not running and when the recorder is stuck.
+func (w *Worker) Liveness(ctx context.Context) error {
+	if !w.consuming.Load() {
+		return errors.New("consumer not running")
+	}
+	return w.recorder.Liveness(ctx)
+}
 
-	slog.Info("Worker is ready to consume messages", "queue", queue.Name)
-	for msg := range msgs {
-		slog.Info("Received message", "body", string(msg.Body))
+// handle continues the trace of the request the message was published from.
+func (w *Worker) handle(ctx context.Context, d queue.Delivery) {
+	slog.Info("Received message", "body", string(d.Body))
 
-		var accessLog types.ApiAccessLog
-		if err := json.Unmarshal(msg.Body, &accessLog); err != nil {
-			slog.Error("Failed to unmarshal message", "error", err)
-			msg.Nack(false, false)
-			continue
-		}
+	ctx, span := tracing.Tracer().Start(
+		tracing.ExtractHeaders(ctx, d.Headers),
+		types.AccessLogQueue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", types.AccessLogQueue),
+		),
+	)
+	defer span.End()
 
-		w.recorder.Push(accessLog)
-		msg.Ack(false)
+	var accessLog types.ApiAccessLog
+	if err := json.Unmarshal(d.Body, &accessLog); err != nil {
+		slog.Error("Failed to unmarshal message", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid message")
+		d.Nack(false)
+		return
 	}
+
+	w.recorder.Push(ctx, accessLog, func(ok bool) {
+		var err error
+		if ok {
+			err = d.Ack()
+		} else {
+			err = d.Nack(true)
+		}
+		if err != nil {
+			slog.Error("Failed to settle message", "key", d.Key, "error", err)
+		}
+	})
 }
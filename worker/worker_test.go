@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/szks-repo/usage-based-billing-sample/pkg/queue"
+	"github.com/szks-repo/usage-based-billing-sample/pkg/types"
+)
+
+// fakeRecorder flushes on Stop with the result of ok.
+type fakeRecorder struct {
+	ok bool
+
+	mu   sync.Mutex
+	logs []types.ApiAccessLog
+	acks []AckFunc
+}
+
+func (r *fakeRecorder) Observe(ctx context.Context) {}
+
+func (r *fakeRecorder) Push(ctx context.Context, log types.ApiAccessLog, ack AckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, log)
+	r.acks = append(r.acks, ack)
+}
+
+func (r *fakeRecorder) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ack := range r.acks {
+		ack(r.ok)
+	}
+	return nil
+}
+
+func (r *fakeRecorder) Liveness(ctx context.Context) error {
+	return nil
+}
+
+func (r *fakeRecorder) BufferSize() int {
+	return 10
+}
+
+func (r *fakeRecorder) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.logs)
+}
+
+func TestWorker_Run(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		flushOk   bool
+		wantQueue int
+	}{
+		{name: "flushed logs are acked", flushOk: true, wantQueue: 0},
+		{name: "failed flush requeues", flushOk: false, wantQueue: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := queue.NewMemory(10)
+			require.NoError(t, q.Publish(context.Background(),
+				queue.Message{Key: "1", Body: []byte(`{"account_id":1,"path":"/api/v1/one"}`)},
+				queue.Message{Key: "1", Body: []byte(`not json`)},
+				queue.Message{Key: "2", Body: []byte(`{"account_id":2,"path":"/api/v1/one"}`)},
+			))
+			recorder := &fakeRecorder{ok: tt.flushOk}
+			w := NewWorker(q, recorder)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				w.Run(ctx, time.Second)
+			}()
+
+			require.Eventually(t, func() bool { return recorder.received() == 2 }, time.Second, time.Millisecond)
+			assert.NoError(t, w.Liveness(context.Background()))
+			cancel()
+			<-done
+			assert.Error(t, w.Liveness(context.Background()), "consumer stopped")
+
+			assert.Equal(t, int64(1), recorder.logs[0].AccountId)
+			assert.Equal(t, int64(2), recorder.logs[1].AccountId)
+			assert.Equal(t, 3, q.Settled(), "invalid message is dropped")
+			assert.Eventually(t, func() bool { return q.Len() == tt.wantQueue }, time.Second, time.Millisecond)
+		})
+	}
+}